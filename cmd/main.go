@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"github.com/flyflow-devs/flyflow/internal/config"
 	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/migrations"
 	"github.com/flyflow-devs/flyflow/internal/server"
+	"github.com/flyflow-devs/flyflow/internal/telemetry"
+	"github.com/flyflow-devs/flyflow/internal/webhook"
 	"github.com/gorilla/mux"
 	"github.com/spf13/cobra"
 	"log"
@@ -11,8 +16,13 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"text/tabwriter"
+	"time"
 )
 
+// How long API requests in flight get to finish once calls have drained
+const shutdownTimeout = 30 * time.Second
+
 var rootCmd = &cobra.Command{
 	Use:   "voice-api",
 	Short: "Voice API",
@@ -21,14 +31,38 @@ var rootCmd = &cobra.Command{
 		if err != nil {
 			log.Printf("error loading config: %v", err)
 		}
-		db := server.InitDB(cfg, false)
+		db, err := server.InitDB(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
 		logger.InitLogger(cfg.Env)
 
+		shutdownTracing, err := telemetry.InitTracing(context.Background(), cfg)
+		if err != nil {
+			logger.S.Fatalf("error setting up tracing: %v", err)
+		}
+
 		s := server.NewServer(cfg, db)
 		logger.S.Info("Serving on port " + cfg.Port)
 
+		// Process background jobs in this process unless they're handled by dedicated worker processes
+		ctx, cancel := context.WithCancel(context.Background())
+		workerDone := make(chan struct{})
+		if cfg.EmbeddedWorker {
+			go func() {
+				server.NewWorker(cfg, db).Run(ctx)
+				close(workerDone)
+			}()
+		} else {
+			close(workerDone)
+		}
+
+		// Keep this instance's claim on its calls alive so other instances can route commands to them
+		go s.Registry.Run(ctx)
+
+		httpServer := &http.Server{Addr: ":" + cfg.Port, Handler: s.Router}
 		go func() {
-			if err := http.ListenAndServe(":"+cfg.Port, s.Router); err != nil && err != http.ErrServerClosed {
+			if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 				log.Fatalf("ListenAndServe(): %v", err)
 			}
 		}()
@@ -37,8 +71,89 @@ var rootCmd = &cobra.Command{
 		signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 		<-quit
 
-		// Wait for WebSocket connections to close
-		s.WG.Wait()
+		// Stop taking new calls and wait for the calls in progress, up to the drain timeout
+		logger.S.Infof("Draining, waiting up to %v for calls to finish", cfg.DrainTimeout)
+		s.Drain()
+		drained := make(chan struct{})
+		go func() {
+			s.WG.Wait()
+			close(drained)
+		}()
+		select {
+		case <-drained:
+		case <-time.After(cfg.DrainTimeout):
+			logger.S.Warn("Drain timed out with calls still in progress")
+		}
+
+		// Finish the API requests in flight, websockets were handled above
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancelShutdown()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			logger.S.Errorf("error shutting down server: %v", err)
+		}
+
+		// Let in-flight jobs finish, including the analysis of the calls that just ended
+		cancel()
+		<-workerDone
+
+		// Write the webhook events the calls and jobs emitted to the job queue
+		webhook.Flush()
+
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.S.Errorf("error flushing spans: %v", err)
+		}
+	},
+}
+
+var workerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "Process background jobs",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.NewConfig()
+		if err != nil {
+			log.Printf("error loading config: %v", err)
+		}
+		db, err := server.InitDB(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		logger.InitLogger(cfg.Env)
+
+		r := mux.NewRouter()
+
+		// Add a health check endpoint
+		r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("OK"))
+		})
+
+		// Webhook deliveries are counted by the process running the jobs
+		if cfg.MetricsEnabled {
+			r.Handle("/metrics", telemetry.Handler())
+		}
+
+		go func() {
+			if err := http.ListenAndServe(":"+cfg.Port, r); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("ListenAndServe(): %v", err)
+			}
+		}()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		workerDone := make(chan struct{})
+		go func() {
+			server.NewWorker(cfg, db).Run(ctx)
+			close(workerDone)
+		}()
+
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+		<-quit
+
+		// Stop claiming jobs and let in-flight jobs finish
+		logger.S.Info("Shutting down worker")
+		cancel()
+		<-workerDone
+		webhook.Flush()
 	},
 }
 
@@ -47,18 +162,31 @@ var dbCmd = &cobra.Command{
 	Short: "Database operations",
 }
 
-var autoMigrateCmd = &cobra.Command{
-	Use:   "automigrate",
-	Short: "Automatically migrate the database schema",
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply, revert and inspect the versioned schema migrations",
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply the pending migrations",
 	Run: func(cmd *cobra.Command, args []string) {
-		cfg, err := config.NewConfig()
+		migrator := newMigrator()
+		applied, err := migrator.Up()
+		for _, m := range applied {
+			logger.S.Infof("Applied migration %d_%s", m.Version, m.Name)
+		}
 		if err != nil {
-			log.Printf("error loading config: %v", err)
+			logger.S.Fatal(err)
 		}
+		logger.S.Infof("Database migration completed successfully, %d migrations applied.", len(applied))
 
-		server.InitDB(cfg, true)
-		logger.S.Info("Database migration completed successfully.")
-
+		// The migration job is deployed as a service, which has to keep answering health checks
+		serve, _ := cmd.Flags().GetBool("serve")
+		if !serve {
+			return
+		}
+		cfg, _ := config.NewConfig()
 		r := mux.NewRouter()
 
 		// Add a health check endpoint
@@ -72,6 +200,62 @@ var autoMigrateCmd = &cobra.Command{
 	},
 }
 
+var migrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Revert the most recently applied migrations",
+	Run: func(cmd *cobra.Command, args []string) {
+		steps, _ := cmd.Flags().GetInt("steps")
+		migrator := newMigrator()
+		reverted, err := migrator.Down(steps)
+		for _, m := range reverted {
+			logger.S.Infof("Reverted migration %d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			logger.S.Fatal(err)
+		}
+		logger.S.Infof("%d migrations reverted.", len(reverted))
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "List the migrations and whether they've been applied",
+	Run: func(cmd *cobra.Command, args []string) {
+		statuses, err := newMigrator().Status()
+		if err != nil {
+			logger.S.Fatal(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		w.Flush()
+	},
+}
+
+func newMigrator() *migrations.Migrator {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		log.Printf("error loading config: %v", err)
+	}
+
+	db, err := server.InitDB(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		logger.S.Fatal(err)
+	}
+	return migrator
+}
+
 func init() {
 	cfg, err := config.NewConfig()
 	if err != nil {
@@ -79,8 +263,12 @@ func init() {
 	}
 	logger.InitLogger(cfg.Env)
 
-	dbCmd.AddCommand(autoMigrateCmd)
+	migrateUpCmd.Flags().Bool("serve", false, "Serve a health check endpoint after migrating")
+	migrateDownCmd.Flags().Int("steps", 1, "Number of migrations to revert")
+	migrateCmd.AddCommand(migrateUpCmd, migrateDownCmd, migrateStatusCmd)
+	dbCmd.AddCommand(migrateCmd)
 	rootCmd.AddCommand(dbCmd)
+	rootCmd.AddCommand(workerCmd)
 }
 
 func main() {
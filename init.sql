@@ -1 +1,2 @@
-CREATE DATABASE flyflow;
\ No newline at end of file
+-- Only creates the database, the schema comes from the migrations: go run cmd/main.go db migrate up
+CREATE DATABASE flyflow;
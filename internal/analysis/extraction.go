@@ -0,0 +1,106 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/sashabaranov/go-openai"
+)
+
+const extractionFunction = "record_extracted_data"
+
+// extractionAttempts is how many times the model gets to produce data that passes validation
+const extractionAttempts = 2
+
+// Extract runs the extraction schema against a call transcript and returns the validated result
+func Extract(ctx context.Context, client *openai.Client, model string, schema map[string]interface{}, transcript []openai.ChatCompletionMessage) (map[string]interface{}, error) {
+	conversation, err := json.Marshal(conversationMessages(transcript))
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role: openai.ChatMessageRoleSystem,
+			Content: `
+				You extract structured data from phone call transcripts.
+
+				INSTRUCTIONS
+				- Read the transcript and call the record_extracted_data function with the data described by its parameters
+				- Only use information stated in the conversation, never guess
+				- Leave out optional fields that weren't discussed
+			`,
+		},
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: string(conversation),
+		},
+	}
+
+	tool := openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name:        extractionFunction,
+			Description: "Record the data extracted from the call",
+			Parameters:  schema,
+		},
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < extractionAttempts; attempt++ {
+		resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+			Model:    model,
+			Messages: messages,
+			Tools:    []openai.Tool{tool},
+			ToolChoice: openai.ToolChoice{
+				Type:     openai.ToolTypeFunction,
+				Function: openai.ToolFunction{Name: extractionFunction},
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error extracting data: %w", err)
+		}
+		if len(resp.Choices) == 0 || len(resp.Choices[0].Message.ToolCalls) == 0 {
+			return nil, fmt.Errorf("no extraction returned by the model")
+		}
+
+		toolCall := resp.Choices[0].Message.ToolCalls[0]
+
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &data); err != nil {
+			lastErr = fmt.Errorf("extraction is not valid json: %w", err)
+		} else if err := Validate(schema, data); err != nil {
+			lastErr = fmt.Errorf("extraction does not match schema: %w", err)
+		} else {
+			return data, nil
+		}
+
+		logger.S.Warnf("extraction attempt %d failed: %v", attempt+1, lastErr)
+
+		// Show the model what was wrong and let it try again
+		messages = append(messages,
+			resp.Choices[0].Message,
+			openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				ToolCallID: toolCall.ID,
+				Content:    lastErr.Error(),
+			},
+		)
+	}
+
+	return nil, lastErr
+}
+
+// conversationMessages drops the system prompt so only what was said on the call is analyzed
+func conversationMessages(transcript []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	messages := make([]openai.ChatCompletionMessage, 0, len(transcript))
+	for _, message := range transcript {
+		if message.Role == openai.ChatMessageRoleSystem {
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
@@ -0,0 +1,100 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/sashabaranov/go-openai"
+)
+
+// newExtractionClient returns a client whose model calls the extraction function with each of replies in turn
+func newExtractionClient(t *testing.T, replies ...string) (*openai.Client, *[]openai.ChatCompletionRequest) {
+	t.Helper()
+	if logger.S == nil {
+		logger.InitLogger("test")
+	}
+
+	var requests []openai.ChatCompletionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		requests = append(requests, req)
+		if len(requests) > len(replies) {
+			http.Error(w, "no more replies", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{Choices: []openai.ChatCompletionChoice{{
+			Message: openai.ChatCompletionMessage{
+				Role: openai.ChatMessageRoleAssistant,
+				ToolCalls: []openai.ToolCall{{
+					ID:       "call_1",
+					Type:     openai.ToolTypeFunction,
+					Function: openai.FunctionCall{Name: extractionFunction, Arguments: replies[len(requests)-1]},
+				}},
+			},
+		}}})
+	}))
+	t.Cleanup(server.Close)
+
+	config := openai.DefaultConfig("test")
+	config.BaseURL = server.URL
+	return openai.NewClientWithConfig(config), &requests
+}
+
+func TestExtractRetriesInvalidData(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"party_size": map[string]interface{}{"type": "integer"}},
+		"required":   []interface{}{"party_size"},
+	}
+	transcript := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "A table for four please"}}
+
+	tests := []struct {
+		name    string
+		replies []string
+		want    float64
+		wantErr string
+	}{
+		{name: "valid", replies: []string{`{"party_size": 4}`}, want: 4},
+		{name: "fixed after a schema error", replies: []string{`{"party_size": "four"}`, `{"party_size": 4}`}, want: 4},
+		{name: "fixed after invalid json", replies: []string{`{"party_size":`, `{"party_size": 4}`}, want: 4},
+		{name: "never valid", replies: []string{`{}`, `{"party_size": "four"}`}, wantErr: "extraction does not match schema"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, requests := newExtractionClient(t, tt.replies...)
+			data, err := Extract(context.Background(), client, "gpt-4o", schema, transcript)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if data["party_size"] != tt.want {
+				t.Errorf("got data %v", data)
+			}
+
+			if len(*requests) != len(tt.replies) {
+				t.Fatalf("model was asked %d times, want %d", len(*requests), len(tt.replies))
+			}
+
+			// A retry shows the model what was wrong with its last answer
+			if len(*requests) > 1 {
+				retry := (*requests)[1].Messages
+				feedback := retry[len(retry)-1]
+				if feedback.Role != openai.ChatMessageRoleTool || feedback.ToolCallID != "call_1" || !strings.Contains(feedback.Content, "extraction") {
+					t.Errorf("got retry feedback %+v", feedback)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/jobs"
+	"github.com/flyflow-devs/flyflow/internal/llm"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/webhook"
+	"gorm.io/gorm"
+)
+
+const JobType = "post_call_analysis"
+
+type jobPayload struct {
+	CallId uint `json:"call_id"`
+
+	// Stages left to run, empty means every stage configured for the agent
+	Stages []string `json:"stages,omitempty"`
+}
+
+// analysisColumns are the call columns written by the pipeline, saving only these keeps the job from
+// overwriting changes made to the call while the analysis ran
+var analysisColumns = []string{"sentiment", "sentiment_timeline", "summary", "disposition", "action_items", "extracted_data", "analysis_status"}
+
+// Enqueue schedules post-call analysis for a finished call
+func Enqueue(db *gorm.DB, agent *models.Agent, call *models.Call) error {
+	_, err := jobs.Enqueue(db, JobType, jobPayload{CallId: call.ID}, jobs.ForUser(agent.UserId), jobs.ForCall(call.ID))
+	return err
+}
+
+// Model returns the model used to analyze the agent's calls
+func Model(cfg *config.Config, agent *models.Agent) string {
+	if agent.Analysis.Model != "" {
+		return agent.Analysis.Model
+	}
+	return cfg.AnalysisModel
+}
+
+// NewJobHandler returns the job handler that runs the pipeline and emits the call_ended event once
+// the analysis has completed or run out of retries
+func NewJobHandler(cfg *config.Config, db *gorm.DB) jobs.Handler {
+	return newJobHandler(db, func(agent *models.Agent) *Pipeline {
+		client, model := llm.NewClient(cfg, Model(cfg, agent))
+		return NewPipeline(client, model, agent)
+	})
+}
+
+func newJobHandler(db *gorm.DB, pipelineFor func(agent *models.Agent) *Pipeline) jobs.Handler {
+	return func(ctx context.Context, job *models.Job) error {
+		var payload jobPayload
+		if err := jobs.DecodePayload(job, &payload); err != nil {
+			return err
+		}
+
+		var call models.Call
+		if err := db.First(&call, payload.CallId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.S.Warnf("call %d was deleted before it was analyzed", payload.CallId)
+				return nil
+			}
+			return err
+		}
+
+		var agent models.Agent
+		if err := db.First(&agent, call.AgentId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.S.Warnf("agent %d was deleted before call %d was analyzed", call.AgentId, call.ID)
+				return nil
+			}
+			return err
+		}
+
+		pipeline := pipelineFor(&agent)
+		if len(payload.Stages) > 0 {
+			pipeline.Only(payload.Stages)
+		}
+
+		errs := pipeline.Run(ctx, &agent, &call)
+
+		if len(errs) > 0 && !jobs.IsFinalAttempt(job) {
+			// Keep what succeeded and only retry the stages that failed
+			call.AnalysisStatus = models.AnalysisStatusPending
+			if err := db.Model(&call).Select(analysisColumns).Updates(&call).Error; err != nil {
+				return err
+			}
+
+			payload.Stages = failedStages(errs)
+			if err := jobs.SetPayload(job, payload); err != nil {
+				return err
+			}
+			return fmt.Errorf("analysis stages failed: %s", strings.Join(payload.Stages, ", "))
+		}
+
+		call.AnalysisStatus = models.AnalysisStatusCompleted
+		if len(errs) > 0 {
+			call.AnalysisStatus = models.AnalysisStatusFailed
+		}
+		if err := db.Model(&call).Select(analysisColumns).Updates(&call).Error; err != nil {
+			return err
+		}
+
+		webhook.EmitEvent(db, &agent, "call_ended", &call, map[string]interface{}{
+			"summary":            call.Summary,
+			"disposition":        call.Disposition,
+			"action_items":       call.ActionItems,
+			"sentiment":          call.Sentiment,
+			"sentiment_timeline": call.SentimentTimeline,
+			"extracted_data":     call.ExtractedData,
+			"analysis_status":    call.AnalysisStatus,
+		})
+
+		return nil
+	}
+}
+
+func failedStages(errs map[string]error) []string {
+	names := make([]string, 0, len(errs))
+	for name := range errs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,129 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/migrations"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/glebarez/sqlite"
+	"github.com/sashabaranov/go-openai"
+	"gorm.io/gorm"
+)
+
+// fakeStage fails its first failures runs and counts how often it ran
+type fakeStage struct {
+	name     string
+	failures int
+	runs     int
+	run      func(call *models.Call)
+}
+
+func (s *fakeStage) Name() string { return s.name }
+
+func (s *fakeStage) Run(ctx context.Context, client *openai.Client, model string, agent *models.Agent, call *models.Call) error {
+	s.runs++
+	if s.runs <= s.failures {
+		return errors.New("model is overloaded")
+	}
+	s.run(call)
+	return nil
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	if logger.S == nil {
+		logger.InitLogger("test")
+	}
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "analysis.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestJobRetriesOnlyTheFailedStages(t *testing.T) {
+	tests := []struct {
+		name            string
+		summaryFailures int
+		maxAttempts     int
+		wantStatus      string
+		wantSummaryRuns int
+	}{
+		{name: "recovers on retry", summaryFailures: 1, maxAttempts: 3, wantStatus: models.AnalysisStatusCompleted, wantSummaryRuns: 2},
+		{name: "runs out of attempts", summaryFailures: 5, maxAttempts: 2, wantStatus: models.AnalysisStatusFailed, wantSummaryRuns: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			agent := models.Agent{Name: "Support", PhoneNumber: "+15550000000"}
+			if err := db.Create(&agent).Error; err != nil {
+				t.Fatal(err)
+			}
+			call := models.Call{AgentId: agent.ID}
+			if err := db.Create(&call).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			sentiment := &fakeStage{name: "sentiment", run: func(call *models.Call) { call.Sentiment = 8 }}
+			summary := &fakeStage{name: "summary", failures: tt.summaryFailures, run: func(call *models.Call) { call.Summary = "Booked a table" }}
+			handler := newJobHandler(db, func(agent *models.Agent) *Pipeline {
+				return &Pipeline{stages: []Stage{sentiment, summary}}
+			})
+
+			job := &models.Job{Payload: map[string]interface{}{"call_id": float64(call.ID)}, MaxAttempts: tt.maxAttempts}
+			var err error
+			for job.Attempts = 1; job.Attempts <= job.MaxAttempts; job.Attempts++ {
+				if err = handler(context.Background(), job); err == nil {
+					break
+				}
+
+				// What succeeded is kept while the failed stages wait for the retry
+				var stored models.Call
+				if err := db.First(&stored, call.ID).Error; err != nil {
+					t.Fatal(err)
+				}
+				if stored.Sentiment != 8 || stored.AnalysisStatus != models.AnalysisStatusPending {
+					t.Fatalf("after attempt %d the call has sentiment %d and status %q", job.Attempts, stored.Sentiment, stored.AnalysisStatus)
+				}
+				if stages := job.Payload["stages"]; len(stages.([]interface{})) != 1 || stages.([]interface{})[0] != "summary" {
+					t.Fatalf("retrying stages %v, want summary", stages)
+				}
+			}
+			if err != nil {
+				t.Fatalf("job failed: %v", err)
+			}
+
+			if sentiment.runs != 1 || summary.runs != tt.wantSummaryRuns {
+				t.Errorf("sentiment ran %d times and summary %d times, want 1 and %d", sentiment.runs, summary.runs, tt.wantSummaryRuns)
+			}
+			var stored models.Call
+			if err := db.First(&stored, call.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored.AnalysisStatus != tt.wantStatus || stored.Sentiment != 8 {
+				t.Errorf("got status %q with sentiment %d, want %q with 8", stored.AnalysisStatus, stored.Sentiment, tt.wantStatus)
+			}
+			if tt.wantStatus == models.AnalysisStatusCompleted && stored.Summary != "Booked a table" {
+				t.Errorf("got summary %q", stored.Summary)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Stage is one step of post-call analysis. Stages write their results onto the call.
+type Stage interface {
+	Name() string
+	Run(ctx context.Context, client *openai.Client, model string, agent *models.Agent, call *models.Call) error
+}
+
+// Pipeline runs the post-call analysis stages configured for an agent
+type Pipeline struct {
+	client *openai.Client
+	model  string
+	stages []Stage
+}
+
+// NewPipeline builds the pipeline for an agent. Sentiment always runs, the other stages are enabled through
+// the agent's analysis settings and extraction schema. Caller memory needs summaries so it enables the summary.
+func NewPipeline(client *openai.Client, model string, agent *models.Agent) *Pipeline {
+	stages := []Stage{sentimentStage{}}
+	if agent.Analysis.Summary || agent.CallerMemory {
+		stages = append(stages, summaryStage{})
+	}
+	if len(agent.Analysis.Dispositions) > 0 {
+		stages = append(stages, dispositionStage{})
+	}
+	if agent.Analysis.ActionItems {
+		stages = append(stages, actionItemsStage{})
+	}
+	if agent.ExtractionSchema != nil {
+		stages = append(stages, extractionStage{})
+	}
+
+	return &Pipeline{
+		client: client,
+		model:  model,
+		stages: stages,
+	}
+}
+
+// Only limits the pipeline to the named stages, e.g. to retry the stages that failed
+func (p *Pipeline) Only(names []string) {
+	keep := map[string]bool{}
+	for _, name := range names {
+		keep[name] = true
+	}
+
+	var stages []Stage
+	for _, stage := range p.stages {
+		if keep[stage.Name()] {
+			stages = append(stages, stage)
+		}
+	}
+	p.stages = stages
+}
+
+// Stages returns the names of the stages in the pipeline
+func (p *Pipeline) Stages() []string {
+	names := make([]string, 0, len(p.stages))
+	for _, stage := range p.stages {
+		names = append(names, stage.Name())
+	}
+	return names
+}
+
+// Run runs every stage concurrently. A failing stage doesn't stop the others, the errors of every
+// failed stage are returned keyed by stage name.
+func (p *Pipeline) Run(ctx context.Context, agent *models.Agent, call *models.Call) map[string]error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := map[string]error{}
+
+	for _, stage := range p.stages {
+		wg.Add(1)
+		go func(stage Stage) {
+			defer wg.Done()
+			if err := stage.Run(ctx, p.client, p.model, agent, call); err != nil {
+				logger.S.Errorf("post-call analysis stage %s failed for call %v: %v", stage.Name(), call.ID, err)
+				mu.Lock()
+				errs[stage.Name()] = err
+				mu.Unlock()
+			}
+		}(stage)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// jsonCompletion asks the model to analyze the conversation and decodes its JSON reply into out
+func jsonCompletion(ctx context.Context, client *openai.Client, model string, instructions string, transcript []openai.ChatCompletionMessage, out interface{}) error {
+	conversation, err := json.Marshal(conversationMessages(transcript))
+	if err != nil {
+		return err
+	}
+	return jsonCompletionWithContent(ctx, client, model, instructions, string(conversation), out)
+}
+
+func jsonCompletionWithContent(ctx context.Context, client *openai.Client, model string, instructions string, conversation string, out interface{}) error {
+	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: instructions},
+			{Role: openai.ChatMessageRoleUser, Content: conversation},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.Choices) == 0 {
+		return fmt.Errorf("no response returned by the model")
+	}
+
+	content := resp.Choices[0].Message.Content
+	if err := json.Unmarshal([]byte(content), out); err != nil {
+		return fmt.Errorf("error unmarshalling response %q: %w", content, err)
+	}
+	return nil
+}
+
+type sentimentStage struct{}
+
+func (sentimentStage) Name() string { return "sentiment" }
+
+func (sentimentStage) Run(ctx context.Context, client *openai.Client, model string, agent *models.Agent, call *models.Call) error {
+	type utterance struct {
+		Index   int    `json:"index"`
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+
+	var utterances []utterance
+	userUtterances := map[int]bool{}
+	for i, message := range call.Transcript {
+		if message.Role == openai.ChatMessageRoleSystem {
+			continue
+		}
+		utterances = append(utterances, utterance{Index: i, Role: message.Role, Content: message.Content})
+		if message.Role == openai.ChatMessageRoleUser {
+			userUtterances[i] = true
+		}
+	}
+
+	conversation, err := json.Marshal(utterances)
+	if err != nil {
+		return err
+	}
+
+	var response struct {
+		Sentiment  uint `json:"sentiment"`
+		Utterances []struct {
+			Index     int  `json:"index"`
+			Sentiment uint `json:"sentiment"`
+		} `json:"utterances"`
+	}
+
+	err = jsonCompletionWithContent(ctx, client, model, `
+		You are an expert at scoring sentiment from conversations.
+
+		INSTRUCTIONS
+		- Score the sentiment of the conversation 1-10
+		- Also score the sentiment of every message with the role user 1-10, identified by its index
+		- Return json and ONLY json (no markup etc) in the format {"sentiment": <score uint 1-10>, "utterances": [{"index": <index>, "sentiment": <score uint 1-10>}, ...]}
+		- Bias your scores towards a positive sentiment and only score negative if the transcript is truly negative. Even transcripts that are not explicitly positive should be scored as positive
+
+		SENTIMENT SCORES
+		1-3 Negative
+		3-7 Neutral
+		7-10 Positive
+	`, string(conversation), &response)
+	if err != nil {
+		return err
+	}
+	if response.Sentiment < 1 || response.Sentiment > 10 {
+		return fmt.Errorf("sentiment %d is out of range", response.Sentiment)
+	}
+
+	timeline := []models.UtteranceSentiment{}
+	for _, scored := range response.Utterances {
+		if !userUtterances[scored.Index] || scored.Sentiment < 1 || scored.Sentiment > 10 {
+			continue
+		}
+		timeline = append(timeline, models.UtteranceSentiment{
+			TranscriptIndex: scored.Index,
+			Sentiment:       scored.Sentiment,
+		})
+	}
+	sort.Slice(timeline, func(i, j int) bool { return timeline[i].TranscriptIndex < timeline[j].TranscriptIndex })
+
+	call.Sentiment = response.Sentiment
+	call.SentimentTimeline = timeline
+	return nil
+}
+
+type summaryStage struct{}
+
+func (summaryStage) Name() string { return "summary" }
+
+func (summaryStage) Run(ctx context.Context, client *openai.Client, model string, agent *models.Agent, call *models.Call) error {
+	summary, err := Summarize(ctx, client, model, call.Transcript, agent.Analysis.SummaryInstructions)
+	if err != nil {
+		return err
+	}
+
+	call.Summary = summary
+	return nil
+}
+
+// Summarize writes a short summary of a conversation, additionalInstructions are appended to the prompt when set
+func Summarize(ctx context.Context, client *openai.Client, model string, transcript []openai.ChatCompletionMessage, additionalInstructions string) (string, error) {
+	var response struct {
+		Summary string `json:"summary"`
+	}
+
+	instructions := `
+		You summarize phone calls for the CRM of the business that made or received the call.
+
+		INSTRUCTIONS
+		- Write a short summary (2-4 sentences) of what the caller wanted and what was agreed
+		- Mention names, dates, times and amounts that were discussed
+		- Return json and ONLY json in the format {"summary": "<summary>"}
+	`
+	if additionalInstructions != "" {
+		instructions += "\nADDITIONAL INSTRUCTIONS\n" + additionalInstructions
+	}
+
+	if err := jsonCompletion(ctx, client, model, instructions, transcript, &response); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(response.Summary), nil
+}
+
+type dispositionStage struct{}
+
+func (dispositionStage) Name() string { return "disposition" }
+
+func (dispositionStage) Run(ctx context.Context, client *openai.Client, model string, agent *models.Agent, call *models.Call) error {
+	var response struct {
+		Disposition string `json:"disposition"`
+	}
+
+	instructions := fmt.Sprintf(`
+		You classify the outcome of phone calls.
+
+		INSTRUCTIONS
+		- Pick the single disposition that best describes the outcome of the call
+		- The disposition must be exactly one of: %s
+		- Return json and ONLY json in the format {"disposition": "<disposition>"}
+	`, strings.Join(agent.Analysis.Dispositions, ", "))
+
+	if err := jsonCompletion(ctx, client, model, instructions, call.Transcript, &response); err != nil {
+		return err
+	}
+
+	for _, disposition := range agent.Analysis.Dispositions {
+		if strings.EqualFold(disposition, strings.TrimSpace(response.Disposition)) {
+			call.Disposition = disposition
+			return nil
+		}
+	}
+	return fmt.Errorf("disposition %q is not one of the agent's dispositions", response.Disposition)
+}
+
+type actionItemsStage struct{}
+
+func (actionItemsStage) Name() string { return "action_items" }
+
+func (actionItemsStage) Run(ctx context.Context, client *openai.Client, model string, agent *models.Agent, call *models.Call) error {
+	var response struct {
+		ActionItems []string `json:"action_items"`
+	}
+
+	err := jsonCompletion(ctx, client, model, `
+		You find the follow ups promised during phone calls.
+
+		INSTRUCTIONS
+		- List every concrete follow up the business agreed to or the caller asked for, e.g. "Email the quote to jane@example.com"
+		- Each action item is one short imperative sentence
+		- Return an empty list if there are none
+		- Return json and ONLY json in the format {"action_items": ["<action item>", ...]}
+	`, call.Transcript, &response)
+	if err != nil {
+		return err
+	}
+
+	call.ActionItems = response.ActionItems
+	if call.ActionItems == nil {
+		call.ActionItems = []string{}
+	}
+	return nil
+}
+
+type extractionStage struct{}
+
+func (extractionStage) Name() string { return "extraction" }
+
+func (extractionStage) Run(ctx context.Context, client *openai.Client, model string, agent *models.Agent, call *models.Call) error {
+	data, err := Extract(ctx, client, model, agent.ExtractionSchema, call.Transcript)
+	if err != nil {
+		return err
+	}
+
+	call.ExtractedData = data
+	return nil
+}
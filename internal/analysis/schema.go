@@ -0,0 +1,194 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ValidateSchema checks that an extraction schema is a JSON Schema object definition this package can validate against
+func ValidateSchema(schema map[string]interface{}) error {
+	if schemaType, _ := schema["type"].(string); schemaType != "object" {
+		return fmt.Errorf("extraction schema must have type object")
+	}
+	if _, ok := schema["properties"].(map[string]interface{}); !ok {
+		return fmt.Errorf("extraction schema must define properties")
+	}
+	return checkSchema(schema, "$")
+}
+
+func checkSchema(schema map[string]interface{}, path string) error {
+	switch t := schema["type"].(type) {
+	case nil:
+	case string:
+		if !knownType(t) {
+			return fmt.Errorf("%s: unknown type %q", path, t)
+		}
+	case []interface{}:
+		for _, item := range t {
+			if name, ok := item.(string); !ok || !knownType(name) {
+				return fmt.Errorf("%s: unknown type %v", path, item)
+			}
+		}
+	default:
+		return fmt.Errorf("%s: type must be a string or list of strings", path)
+	}
+
+	if properties, ok := schema["properties"]; ok {
+		props, ok := properties.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: properties must be an object", path)
+		}
+		for name, property := range props {
+			propertySchema, ok := property.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s.%s: property schema must be an object", path, name)
+			}
+			if err := checkSchema(propertySchema, path+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if items, ok := schema["items"]; ok {
+		itemSchema, ok := items.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: items must be an object", path)
+		}
+		if err := checkSchema(itemSchema, path+"[]"); err != nil {
+			return err
+		}
+	}
+
+	if required, ok := schema["required"]; ok {
+		if _, ok := required.([]interface{}); !ok {
+			return fmt.Errorf("%s: required must be a list of property names", path)
+		}
+	}
+
+	if enum, ok := schema["enum"]; ok {
+		if _, ok := enum.([]interface{}); !ok {
+			return fmt.Errorf("%s: enum must be a list", path)
+		}
+	}
+
+	return nil
+}
+
+func knownType(name string) bool {
+	switch name {
+	case "object", "array", "string", "number", "integer", "boolean", "null":
+		return true
+	}
+	return false
+}
+
+// Validate checks a decoded JSON value against the subset of JSON Schema used for extraction:
+// type, properties, required, additionalProperties, items and enum
+func Validate(schema map[string]interface{}, value interface{}) error {
+	var problems []string
+	validate(schema, value, "$", &problems)
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func validate(schema map[string]interface{}, value interface{}, path string, problems *[]string) {
+	if !matchesType(schema["type"], value) {
+		*problems = append(*problems, fmt.Sprintf("%s: expected %v, got %s", path, schema["type"], jsonType(value)))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, option := range enum {
+			if reflect.DeepEqual(option, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*problems = append(*problems, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				if _, ok := v[fmt.Sprint(name)]; !ok {
+					*problems = append(*problems, fmt.Sprintf("%s.%v: is required", path, name))
+				}
+			}
+		}
+		for name, property := range v {
+			propertySchema, ok := properties[name].(map[string]interface{})
+			if !ok {
+				if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+					*problems = append(*problems, fmt.Sprintf("%s.%s: is not allowed", path, name))
+				}
+				continue
+			}
+			validate(propertySchema, property, path+"."+name, problems)
+		}
+	case []interface{}:
+		if itemSchema, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validate(itemSchema, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	}
+}
+
+func matchesType(schemaType interface{}, value interface{}) bool {
+	switch t := schemaType.(type) {
+	case nil:
+		return true
+	case string:
+		return isType(t, value)
+	case []interface{}:
+		for _, name := range t {
+			if isType(fmt.Sprint(name), value) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+func isType(name string, value interface{}) bool {
+	switch name {
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return jsonType(value) == name
+	}
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", value)
+}
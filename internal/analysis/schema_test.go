@@ -0,0 +1,120 @@
+package analysis
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func decodeJSON(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("error decoding %s: %v", s, err)
+	}
+	return v
+}
+
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{name: "object", schema: `{"type": "object", "properties": {"name": {"type": "string"}}}`},
+		{name: "nested", schema: `{"type": "object", "properties": {"items": {"type": "array", "items": {"type": "object", "properties": {"sku": {"type": ["string", "null"]}}}}}}`},
+		{name: "required and enum", schema: `{"type": "object", "properties": {"size": {"enum": ["s", "m"]}}, "required": ["size"]}`},
+		{name: "not an object", schema: `{"type": "array", "properties": {}}`, wantErr: "must have type object"},
+		{name: "no properties", schema: `{"type": "object"}`, wantErr: "must define properties"},
+		{name: "unknown type", schema: `{"type": "object", "properties": {"age": {"type": "int"}}}`, wantErr: `$.age: unknown type "int"`},
+		{name: "unknown type in list", schema: `{"type": "object", "properties": {"age": {"type": ["integer", "date"]}}}`, wantErr: "$.age: unknown type date"},
+		{name: "type isn't a string", schema: `{"type": "object", "properties": {"age": {"type": 1}}}`, wantErr: "type must be a string"},
+		{name: "property isn't a schema", schema: `{"type": "object", "properties": {"age": "integer"}}`, wantErr: "$.age: property schema must be an object"},
+		{name: "nested properties aren't an object", schema: `{"type": "object", "properties": {"address": {"type": "object", "properties": []}}}`, wantErr: "$.address: properties must be an object"},
+		{name: "items aren't a schema", schema: `{"type": "object", "properties": {"tags": {"type": "array", "items": "string"}}}`, wantErr: "$.tags: items must be an object"},
+		{name: "bad item schema", schema: `{"type": "object", "properties": {"tags": {"type": "array", "items": {"type": "text"}}}}`, wantErr: `$.tags[]: unknown type "text"`},
+		{name: "required isn't a list", schema: `{"type": "object", "properties": {}, "required": "name"}`, wantErr: "required must be a list"},
+		{name: "enum isn't a list", schema: `{"type": "object", "properties": {"size": {"enum": "s"}}}`, wantErr: "$.size: enum must be a list"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSchema(decodeJSON(t, tt.schema).(map[string]interface{}))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("got error %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	schema := decodeJSON(t, `{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string"},
+			"party_size": {"type": "integer"},
+			"deposit": {"type": "number"},
+			"vip": {"type": "boolean"},
+			"notes": {"type": ["string", "null"]},
+			"seating": {"type": "string", "enum": ["indoor", "outdoor"]},
+			"contact": {
+				"type": "object",
+				"properties": {"email": {"type": "string"}, "phone": {"type": "string"}},
+				"required": ["phone"],
+				"additionalProperties": false
+			},
+			"dishes": {"type": "array", "items": {"type": "object", "properties": {"dish": {"type": "string"}}, "required": ["dish"]}}
+		},
+		"required": ["name", "party_size"]
+	}`).(map[string]interface{})
+
+	tests := []struct {
+		name  string
+		value string
+		// Problems the error lists, none when the value is valid
+		want []string
+	}{
+		{name: "minimal", value: `{"name": "Ana", "party_size": 4}`},
+		{
+			name:  "everything",
+			value: `{"name": "Ana", "party_size": 4, "deposit": 12.5, "vip": true, "notes": null, "seating": "outdoor", "contact": {"phone": "+14155550100"}, "dishes": [{"dish": "paella"}], "source": "web"}`,
+		},
+		{name: "not an object", value: `["Ana"]`, want: []string{"$: expected object, got array"}},
+		{name: "missing required", value: `{"name": "Ana"}`, want: []string{"$.party_size: is required"}},
+		{name: "wrong types", value: `{"name": 7, "party_size": "four", "vip": "yes"}`, want: []string{
+			"$.name: expected string, got number", "$.party_size: expected integer, got string", "$.vip: expected boolean, got string",
+		}},
+		{name: "fractional integer", value: `{"name": "Ana", "party_size": 4.5}`, want: []string{"$.party_size: expected integer, got number"}},
+		{name: "not in enum", value: `{"name": "Ana", "party_size": 4, "seating": "roof"}`, want: []string{"$.seating: roof is not one of [indoor outdoor]"}},
+		{name: "nested object", value: `{"name": "Ana", "party_size": 4, "contact": {"email": "ana@example.com", "fax": "1"}}`, want: []string{
+			"$.contact.fax: is not allowed", "$.contact.phone: is required",
+		}},
+		{name: "array items", value: `{"name": "Ana", "party_size": 4, "dishes": [{"dish": "paella"}, {"dish": 3}, {}]}`, want: []string{
+			"$.dishes[1].dish: expected string, got number", "$.dishes[2].dish: is required",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(schema, decodeJSON(t, tt.value))
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("got error %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("got no error, want %v", tt.want)
+			}
+			if got := strings.Split(err.Error(), "; "); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got problems %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,16 +4,22 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/flyflow-devs/flyflow/internal/analysis"
+	"github.com/flyflow-devs/flyflow/internal/endpointing"
 	"github.com/flyflow-devs/flyflow/internal/languages"
+	"github.com/flyflow-devs/flyflow/internal/llm"
 	"github.com/flyflow-devs/flyflow/internal/logger"
 	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/prompts"
 	"github.com/flyflow-devs/flyflow/internal/slack"
+	"github.com/flyflow-devs/flyflow/internal/voicemail"
 	"github.com/flyflow-devs/flyflow/internal/voices"
 	"github.com/twilio/twilio-go"
 	openapi "github.com/twilio/twilio-go/rest/api/v2010"
 	"gorm.io/gorm"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -63,6 +69,144 @@ func (a *API) UpsertAgent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, err := time.LoadLocation(agentReq.Timezone); err != nil {
+		http.Error(w, "Invalid request payload, timezone must be a valid IANA timezone such as America/New_York", http.StatusBadRequest)
+		return
+	}
+
+	if err := prompts.ValidateVariables(agentReq.Variables); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request payload, %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if agentReq.Flow != nil {
+		if err := agentReq.Flow.Validate(agentReq.Tools); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request payload, %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if agentReq.ExtractionSchema != nil {
+		if err := analysis.ValidateSchema(agentReq.ExtractionSchema); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request payload, %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if agentReq.Analysis.Model != "" && !llm.Valid(agentReq.Analysis.Model) {
+		http.Error(w, "Invalid request payload, analysis model must be gpt-4o, gpt-4o-mini, flyflow-voice or unset", http.StatusBadRequest)
+		return
+	}
+
+	for _, disposition := range agentReq.Analysis.Dispositions {
+		if strings.TrimSpace(disposition) == "" {
+			http.Error(w, "Invalid request payload, dispositions can't be empty", http.StatusBadRequest)
+			return
+		}
+	}
+
+	idle := agentReq.IdlePolicy
+	if idle.ReminderAfterSeconds < 0 || idle.MaxReminders < 0 || idle.MaxCallSeconds < 0 {
+		http.Error(w, "Invalid request payload, idle policy values can't be negative", http.StatusBadRequest)
+		return
+	}
+
+	if agentReq.CallerMemoryCalls < 0 {
+		http.Error(w, "Invalid request payload, caller_memory_calls can't be negative", http.StatusBadRequest)
+		return
+	}
+
+	if !endpointing.Valid(agentReq.Endpointer) {
+		http.Error(w, "Invalid request payload, endpointer must be llm, classifier, silence or unset", http.StatusBadRequest)
+		return
+	}
+
+	if agentReq.SilenceWindowMs > endpointing.MaxSilenceWindowMs {
+		http.Error(w, fmt.Sprintf("Invalid request payload, silence_window_ms can't be more than %d", endpointing.MaxSilenceWindowMs), http.StatusBadRequest)
+		return
+	}
+
+	bargeIn := agentReq.BargeIn
+	if bargeIn.Mode != "" && bargeIn.Mode != models.BargeInModeSmart && bargeIn.Mode != models.BargeInModeAlways && bargeIn.Mode != models.BargeInModeNever {
+		http.Error(w, "Invalid request payload, barge_in mode must be smart, always, never or unset", http.StatusBadRequest)
+		return
+	}
+	if bargeIn.Detection != "" && bargeIn.Detection != models.BargeInDetectionVAD && bargeIn.Detection != models.BargeInDetectionTranscript {
+		http.Error(w, "Invalid request payload, barge_in detection must be vad, transcript or unset", http.StatusBadRequest)
+		return
+	}
+	if bargeIn.MinSpeechMs < 0 || bargeIn.EnergyThreshold < 0 || (bargeIn.VADMode != nil && (*bargeIn.VADMode < 0 || *bargeIn.VADMode > 3)) {
+		http.Error(w, "Invalid request payload, barge_in min_speech_ms and energy_threshold can't be negative and vad_mode must be between 0 and 3", http.StatusBadRequest)
+		return
+	}
+
+	// Make sure every agent a handoff action passes calls to belongs to the user
+	for i := range agentReq.Actions {
+		action := &agentReq.Actions[i]
+		if action.Name != "handoff" {
+			continue
+		}
+		action.AgentIds = uniqueIds(action.AgentIds)
+		if len(action.AgentIds) == 0 {
+			http.Error(w, "Invalid request payload, handoff actions need agent_ids", http.StatusBadRequest)
+			return
+		}
+		var count int64
+		if err := a.DB.Model(&models.Agent{}).Where("id IN ? AND user_id = ?", action.AgentIds, user.ID).Count(&count).Error; err != nil {
+			logger.S.Error(err)
+			http.Error(w, "Failed to validate handoff agents", http.StatusInternalServerError)
+			return
+		}
+		if count != int64(len(action.AgentIds)) {
+			http.Error(w, "Invalid request payload, handoff agent not found", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if agentReq.Voicemail != nil && (agentReq.Voicemail.MaxLengthSeconds < 0 || agentReq.Voicemail.MaxLengthSeconds > voicemail.MaxLengthLimit) {
+		http.Error(w, fmt.Sprintf("Invalid request payload, voicemail max_length_seconds must be between 0 and %d", voicemail.MaxLengthLimit), http.StatusBadRequest)
+		return
+	}
+
+	if agentReq.Routing != nil {
+		if err := agentReq.Routing.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request payload, %v", err), http.StatusBadRequest)
+			return
+		}
+
+		// Make sure every agent that calls are routed to belongs to the user
+		for _, rule := range agentReq.Routing.Rules {
+			if rule.Target.Type != models.RouteToAgent || rule.Target.AgentId == 0 {
+				continue
+			}
+			var count int64
+			if err := a.DB.Model(&models.Agent{}).Where("id = ? AND user_id = ?", rule.Target.AgentId, user.ID).Count(&count).Error; err != nil {
+				logger.S.Error(err)
+				http.Error(w, "Failed to validate routing", http.StatusInternalServerError)
+				return
+			}
+			if count == 0 {
+				http.Error(w, fmt.Sprintf("Invalid request payload, routing rule %q routes to an agent that was not found", rule.Name), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	// Make sure every attached knowledge base belongs to the user
+	agentReq.KnowledgeBaseIds = uniqueIds(agentReq.KnowledgeBaseIds)
+	if len(agentReq.KnowledgeBaseIds) > 0 {
+		var count int64
+		if err := a.DB.Model(&models.KnowledgeBase{}).Where("id IN ? AND user_id = ?", agentReq.KnowledgeBaseIds, user.ID).Count(&count).Error; err != nil {
+			logger.S.Error(err)
+			http.Error(w, "Failed to validate knowledge bases", http.StatusInternalServerError)
+			return
+		}
+		if count != int64(len(agentReq.KnowledgeBaseIds)) {
+			http.Error(w, "Invalid request payload, knowledge base not found", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Find the existing agent based on the user ID and agent name
 	var existingAgent models.Agent
 	result := a.DB.Where("user_id = ? AND name = ?", user.ID, agentReq.Name).First(&existingAgent)
@@ -82,14 +226,30 @@ func (a *API) UpsertAgent(w http.ResponseWriter, r *http.Request) {
 				FillerWords:         agentReq.FillerWords,
 				Actions:             agentReq.Actions,
 				VoicemailNumber:     agentReq.VoicemailNumber,
+				Voicemail:           agentReq.Voicemail,
 				Chunking:            agentReq.Chunking,
 				Endpointing:         agentReq.Endpointing,
 				VoiceOptimization:   agentReq.VoiceOptimization,
 				FillerWordsWhitelist: agentReq.FillerWordsWhitelist,
 				SmartEndpointingThreshold: agentReq.SmartEndpointingThreshold,
+				Endpointer: agentReq.Endpointer,
+				SilenceWindowMs: agentReq.SilenceWindowMs,
+				SpeculativeTTS: agentReq.SpeculativeTTS,
 				Multilingual: agentReq.Multilingual,
 				Language: agentReq.Language,
 				ComplianceChecks: agentReq.ComplianceChecks,
+				Timezone: agentReq.Timezone,
+				Variables: agentReq.Variables,
+				KnowledgeBaseIds: agentReq.KnowledgeBaseIds,
+				Flow: agentReq.Flow,
+				ExtractionSchema: agentReq.ExtractionSchema,
+				Analysis: agentReq.Analysis,
+				Routing: agentReq.Routing,
+				HandoffMessage: agentReq.HandoffMessage,
+				IdlePolicy: agentReq.IdlePolicy,
+				BargeIn: agentReq.BargeIn,
+				CallerMemory: agentReq.CallerMemory,
+				CallerMemoryCalls: agentReq.CallerMemoryCalls,
 			}
 
 			// Create a new Twilio client
@@ -150,14 +310,30 @@ func (a *API) UpsertAgent(w http.ResponseWriter, r *http.Request) {
 		existingAgent.FillerWords = agentReq.FillerWords
 		existingAgent.Actions = agentReq.Actions
 		existingAgent.VoicemailNumber = agentReq.VoicemailNumber
+		existingAgent.Voicemail = agentReq.Voicemail
 		existingAgent.Chunking = agentReq.Chunking
 		existingAgent.Endpointing = agentReq.Endpointing
 		existingAgent.VoiceOptimization = agentReq.VoiceOptimization
 		existingAgent.FillerWordsWhitelist = agentReq.FillerWordsWhitelist
 		existingAgent.SmartEndpointingThreshold = agentReq.SmartEndpointingThreshold
+		existingAgent.Endpointer = agentReq.Endpointer
+		existingAgent.SilenceWindowMs = agentReq.SilenceWindowMs
+		existingAgent.SpeculativeTTS = agentReq.SpeculativeTTS
 		existingAgent.Multilingual = agentReq.Multilingual
 		existingAgent.Language = agentReq.Language
 		existingAgent.ComplianceChecks = agentReq.ComplianceChecks
+		existingAgent.Timezone = agentReq.Timezone
+		existingAgent.Variables = agentReq.Variables
+		existingAgent.KnowledgeBaseIds = agentReq.KnowledgeBaseIds
+		existingAgent.Flow = agentReq.Flow
+		existingAgent.ExtractionSchema = agentReq.ExtractionSchema
+		existingAgent.Analysis = agentReq.Analysis
+		existingAgent.Routing = agentReq.Routing
+		existingAgent.HandoffMessage = agentReq.HandoffMessage
+		existingAgent.IdlePolicy = agentReq.IdlePolicy
+		existingAgent.BargeIn = agentReq.BargeIn
+		existingAgent.CallerMemory = agentReq.CallerMemory
+		existingAgent.CallerMemoryCalls = agentReq.CallerMemoryCalls
 
 		// Save the updated agent in the database
 		result = a.DB.Save(&existingAgent)
@@ -269,7 +445,12 @@ func (a *API) ListAgents(w http.ResponseWriter, r *http.Request) {
 
 	// Apply cursor if provided
 	if cursor != "" {
-		query = query.Where("created_at < ?", cursor)
+		cursorTime, err := parseCursor(cursor)
+		if err != nil {
+			http.Error(w, "Invalid cursor parameter", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("created_at < ?", cursorTime)
 	}
 
 	// Retrieve the agents from the database
@@ -304,4 +485,19 @@ func (a *API) ListAgents(w http.ResponseWriter, r *http.Request) {
 
 	// Return the response
 	json.NewEncoder(w).Encode(response)
-}
\ No newline at end of file
+}
+// uniqueIds drops repeated ids, keeping the first occurrence of each
+func uniqueIds(ids []uint) []uint {
+	if len(ids) == 0 {
+		return ids
+	}
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -2,10 +2,15 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/logger"
 	"github.com/flyflow-devs/flyflow/internal/models"
+	"gorm.io/gorm"
 )
 
 type AnalyticsResponse struct {
@@ -13,8 +18,20 @@ type AnalyticsResponse struct {
 	TotalCalls       int64     `json:"total_calls"`
 	TotalMinutes     float64 `json:"total_minutes"`
 	AverageSentiment float64 `json:"average_sentiment"`
+
+	// Latency is the percentiles of each stage of the agent's turns this month
+	Latency map[string]LatencyPercentiles `json:"latency"`
+}
+
+type LatencyPercentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
 }
 
+// latencyStages are the turn latency stages reported by analytics, each is the <stage>_ms field of TurnLatency
+var latencyStages = []string{"stt_finalization", "endpointing", "llm_first_token", "llm_total", "tts_first_byte", "network_write", "total"}
+
 func (a *API) GetAnalytics(w http.ResponseWriter, r *http.Request) {
 	// Validate the API key
 	user, err := a.ValidateAuth(r)
@@ -28,7 +45,7 @@ func (a *API) GetAnalytics(w http.ResponseWriter, r *http.Request) {
 	// Get in-progress calls
 	if err := a.DB.Model(&models.Call{}).
 		Joins("JOIN agents ON calls.agent_id = agents.id").
-		Where("agents.user_id = ? AND calls.in_progress = True", user.ID).
+		Where("agents.user_id = ? AND calls.in_progress = ?", user.ID, true).
 		Count(&response.InProgressCalls).Error; err != nil {
 		http.Error(w, "Failed to get in-progress calls", http.StatusInternalServerError)
 		return
@@ -70,10 +87,74 @@ func (a *API) GetAnalytics(w http.ResponseWriter, r *http.Request) {
 	}
 	response.AverageSentiment = result.AvgSentiment
 
+	response.Latency, err = latencyPercentiles(a.DB, user.ID, startOfMonth)
+	if err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to get latencies", http.StatusInternalServerError)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// latencyPercentiles works out the nearest rank percentiles of each stage of the user's turns since the
+// start date in the database, turns are stored as JSON on their calls. Stages that didn't run in a turn are
+// left out rather than counted as instant.
+func latencyPercentiles(db *gorm.DB, userId uint, since time.Time) (map[string]LatencyPercentiles, error) {
+	stages := make([]string, 0, len(latencyStages))
+	for _, stage := range latencyStages {
+		stages = append(stages, fmt.Sprintf("SELECT '%s' AS stage, '%s_ms' AS field", stage, stage))
+	}
+	stageTable := "(" + strings.Join(stages, " UNION ALL ") + ") AS s"
+
+	turns := `SELECT s.stage, (t.value ->> s.field)::float8 AS ms
+		FROM calls JOIN agents ON calls.agent_id = agents.id
+		CROSS JOIN LATERAL jsonb_array_elements(CASE WHEN calls.turn_latencies LIKE '[%' THEN calls.turn_latencies::jsonb ELSE '[]'::jsonb END) AS t(value)
+		CROSS JOIN ` + stageTable + `
+		WHERE agents.user_id = ? AND calls.created_at >= ?`
+	if db.Dialector.Name() == config.DBDriverSQLite {
+		turns = `SELECT s.stage, CAST(json_extract(t.value, '$.' || s.field) AS REAL) AS ms
+		FROM calls JOIN agents ON calls.agent_id = agents.id
+		JOIN json_each(CASE WHEN calls.turn_latencies LIKE '[%' THEN calls.turn_latencies ELSE '[]' END) AS t
+		CROSS JOIN ` + stageTable + `
+		WHERE agents.user_id = ? AND calls.created_at >= ?`
+	}
+
+	// The nearest rank of percentile p out of n values is ceil(p * n / 100)
+	query := `WITH turns AS (` + turns + `),
+		ranked AS (
+			SELECT stage, ms,
+				ROW_NUMBER() OVER (PARTITION BY stage ORDER BY ms) AS turn_rank,
+				COUNT(*) OVER (PARTITION BY stage) AS turn_count
+			FROM turns WHERE ms > 0
+		)
+		SELECT stage,
+			MAX(CASE WHEN turn_rank = (turn_count * 50 + 99) / 100 THEN ms END) AS p50,
+			MAX(CASE WHEN turn_rank = (turn_count * 90 + 99) / 100 THEN ms END) AS p90,
+			MAX(CASE WHEN turn_rank = (turn_count * 99 + 99) / 100 THEN ms END) AS p99
+		FROM ranked GROUP BY stage`
+
+	var rows []struct {
+		Stage string
+		P50   float64
+		P90   float64
+		P99   float64
+	}
+	if err := db.Raw(query, userId, since).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	percentiles := make(map[string]LatencyPercentiles, len(latencyStages))
+	for _, stage := range latencyStages {
+		percentiles[stage] = LatencyPercentiles{}
+	}
+	for _, row := range rows {
+		percentiles[row.Stage] = LatencyPercentiles{P50: row.P50, P90: row.P90, P99: row.P99}
+	}
+	return percentiles, nil
+}
+
 func getStartOfMonth(date time.Time) time.Time {
 	year, month, day := date.Date()
 	return time.Date(year, month, day, 0, 0, 0, 0, date.Location())
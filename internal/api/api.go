@@ -1,8 +1,11 @@
 package api
 
 import (
+	"time"
+
 	"github.com/flyflow-devs/flyflow/internal/classifier"
 	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/ownership"
 	"gorm.io/gorm"
 )
 
@@ -10,12 +13,18 @@ type API struct {
 	Cfg *config.Config
 	DB *gorm.DB
 	Classifier *classifier.Classifier
+	Registry *ownership.Registry
 }
 
-func NewAPI(cfg *config.Config, db *gorm.DB) *API {
+func NewAPI(cfg *config.Config, db *gorm.DB, registry *ownership.Registry) *API {
 	return &API{
 		Cfg: cfg,
 		DB: db,
 		Classifier: classifier.NewClassifier(),
+		Registry: registry,
 	}
-}
\ No newline at end of file
+}
+// parseCursor parses a timestamp cursor so it's compared as a time rather than as text, which SQLite would do
+func parseCursor(cursor string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, cursor)
+}
@@ -137,7 +137,12 @@ func (a *API) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
 		Limit(limitInt + 1)
 
 	if cursor != "" {
-		query = query.Where("created_at < ?", cursor)
+		cursorTime, err := parseCursor(cursor)
+		if err != nil {
+			http.Error(w, "Invalid cursor parameter", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("created_at < ?", cursorTime)
 	}
 
 	var apiKeys []models.APIKey
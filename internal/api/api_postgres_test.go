@@ -0,0 +1,13 @@
+//go:build postgres
+
+package api_test
+
+import (
+	"testing"
+
+	"github.com/flyflow-devs/flyflow/internal/apitest"
+)
+
+func TestPostgresAnalyticsLatencyPercentiles(t *testing.T) {
+	testAnalyticsLatencyPercentiles(t, apitest.NewWithConfig(t, apitest.PostgresConfig(t)))
+}
@@ -0,0 +1,297 @@
+package api_test
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/api"
+	"github.com/flyflow-devs/flyflow/internal/apitest"
+	"github.com/flyflow-devs/flyflow/internal/models"
+)
+
+type listCallsResponse struct {
+	NumItems int           `json:"num_items"`
+	Cursor   string        `json:"cursor"`
+	Calls    []models.Call `json:"calls"`
+}
+
+// createCalls adds an agent for the user with calls a minute apart, the last call is the newest
+func createCalls(t *testing.T, h *apitest.Harness, user *models.User, calls ...models.Call) []models.Call {
+	t.Helper()
+	agent := models.Agent{UserId: user.ID, Name: "Support", PhoneNumber: "+15550000000"}
+	if err := h.DB.Create(&agent).Error; err != nil {
+		t.Fatalf("error creating agent: %v", err)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	for i := range calls {
+		calls[i].AgentId = agent.ID
+		calls[i].CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		if err := h.DB.Create(&calls[i]).Error; err != nil {
+			t.Fatalf("error creating call: %v", err)
+		}
+	}
+	return calls
+}
+
+func TestGetCallOnlyReturnsTheUsersCalls(t *testing.T) {
+	h := apitest.New(t)
+	user, apiKey := h.CreateUser(t, "owner@example.com")
+	_, otherKey := h.CreateUser(t, "other@example.com")
+	calls := createCalls(t, h, user, models.Call{ClientNumber: "+15551111111"})
+
+	var call models.Call
+	apitest.Decode(t, h.Do(t, http.MethodGet, "/v1/call?id="+itoa(calls[0].ID), apiKey, nil), http.StatusOK, &call)
+	if call.ClientNumber != "+15551111111" {
+		t.Errorf("got client number %q, want +15551111111", call.ClientNumber)
+	}
+
+	apitest.Decode(t, h.Do(t, http.MethodGet, "/v1/call?id="+itoa(calls[0].ID), otherKey, nil), http.StatusNotFound, nil)
+	apitest.Decode(t, h.Do(t, http.MethodGet, "/v1/call?id="+itoa(calls[0].ID), "", nil), http.StatusUnauthorized, nil)
+}
+
+func TestSetCallContext(t *testing.T) {
+	h := apitest.New(t)
+	user, apiKey := h.CreateUser(t, "owner@example.com")
+	calls := createCalls(t, h, user, models.Call{})
+
+	body := map[string]interface{}{"id": calls[0].ID, "context": "The caller's order shipped yesterday"}
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/v1/call/context", apiKey, body), http.StatusOK, nil)
+
+	var call models.Call
+	if err := h.DB.First(&call, calls[0].ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if call.Context != "The caller's order shipped yesterday" {
+		t.Errorf("got context %q", call.Context)
+	}
+}
+
+func TestListCallsPagesAndFilters(t *testing.T) {
+	h := apitest.New(t)
+	user, apiKey := h.CreateUser(t, "owner@example.com")
+	createCalls(t, h, user,
+		models.Call{Disposition: "resolved", ExtractedData: map[string]interface{}{"order": map[string]interface{}{"count": 2}, "refund": true}},
+		models.Call{Disposition: "escalated", ExtractedData: map[string]interface{}{"order": map[string]interface{}{"count": 3}, "refund": false}},
+		models.Call{Disposition: "resolved", ExtractedData: map[string]interface{}{"order": map[string]interface{}{"count": 2}, "refund": false}},
+	)
+
+	var page listCallsResponse
+	apitest.Decode(t, h.Do(t, http.MethodGet, "/v1/calls?limit=2", apiKey, nil), http.StatusOK, &page)
+	if page.NumItems != 2 || page.Cursor == "" {
+		t.Fatalf("got %d calls and cursor %q, want 2 calls and a cursor", page.NumItems, page.Cursor)
+	}
+	cursor := page.Cursor
+	page = listCallsResponse{}
+	apitest.Decode(t, h.Do(t, http.MethodGet, "/v1/calls?limit=2&cursor="+url.QueryEscape(cursor), apiKey, nil), http.StatusOK, &page)
+	if page.NumItems != 1 || page.Cursor != "" {
+		t.Fatalf("got %d calls and cursor %q on the last page, want 1 call and no cursor", page.NumItems, page.Cursor)
+	}
+
+	filters := map[string]int{
+		"disposition=resolved":                           2,
+		"extracted.order.count=2":                        2,
+		"extracted.refund=true":                          1,
+		"extracted.refund=false&extracted.order.count=3": 1,
+	}
+	for filter, want := range filters {
+		page = listCallsResponse{}
+		apitest.Decode(t, h.Do(t, http.MethodGet, "/v1/calls?"+filter, apiKey, nil), http.StatusOK, &page)
+		if page.NumItems != want {
+			t.Errorf("%s matched %d calls, want %d", filter, page.NumItems, want)
+		}
+	}
+
+	apitest.Decode(t, h.Do(t, http.MethodGet, "/v1/calls?cursor=yesterday", apiKey, nil), http.StatusBadRequest, nil)
+}
+
+func TestAnalyticsCountsCalls(t *testing.T) {
+	h := apitest.New(t)
+	user, apiKey := h.CreateUser(t, "owner@example.com")
+	createCalls(t, h, user,
+		models.Call{InProgress: true},
+		models.Call{TimeSeconds: 90},
+		models.Call{TimeSeconds: 30},
+	)
+
+	var analytics api.AnalyticsResponse
+	apitest.Decode(t, h.Do(t, http.MethodGet, "/v1/analytics", apiKey, nil), http.StatusOK, &analytics)
+	if analytics.InProgressCalls != 1 {
+		t.Errorf("got %d calls in progress, want 1", analytics.InProgressCalls)
+	}
+	if analytics.TotalCalls != 3 {
+		t.Errorf("got %d calls, want 3", analytics.TotalCalls)
+	}
+}
+
+func TestAnalyticsLatencyPercentiles(t *testing.T) {
+	testAnalyticsLatencyPercentiles(t, apitest.New(t))
+}
+
+// The percentiles are worked out in SQL, each dialect has its own query
+func testAnalyticsLatencyPercentiles(t *testing.T, h *apitest.Harness) {
+	user, apiKey := h.CreateUser(t, "owner@example.com")
+	other, _ := h.CreateUser(t, "other@example.com")
+
+	// Analytics count from the day the user signed up, keep the test calls of the last hour inside it
+	if err := h.DB.Model(user).Update("created_at", time.Now().AddDate(0, 0, -2)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 100 turns with totals of 1 to 100ms over four calls, endpointing only ran in every other turn
+	var calls []models.Call
+	for i := 0; i < 4; i++ {
+		var call models.Call
+		for j := 1; j <= 25; j++ {
+			turn := models.TurnLatency{TotalMs: float64(i*25 + j)}
+			if j%2 == 0 {
+				turn.EndpointingMs = 10
+			}
+			call.TurnLatencies = append(call.TurnLatencies, turn)
+		}
+		calls = append(calls, call)
+	}
+	calls = append(calls, models.Call{InProgress: true})
+	createCalls(t, h, user, calls...)
+	createCalls(t, h, other, models.Call{TurnLatencies: []models.TurnLatency{{TotalMs: 5000}}})
+
+	var analytics api.AnalyticsResponse
+	apitest.Decode(t, h.Do(t, http.MethodGet, "/v1/analytics", apiKey, nil), http.StatusOK, &analytics)
+	if got, want := analytics.Latency["total"], (api.LatencyPercentiles{P50: 50, P90: 90, P99: 99}); got != want {
+		t.Errorf("got total latency %+v, want %+v", got, want)
+	}
+	if got, want := analytics.Latency["endpointing"], (api.LatencyPercentiles{P50: 10, P90: 10, P99: 10}); got != want {
+		t.Errorf("got endpointing latency %+v, want %+v", got, want)
+	}
+	if got := analytics.Latency["llm_first_token"]; got != (api.LatencyPercentiles{}) {
+		t.Errorf("got llm first token latency %+v for a stage that never ran", got)
+	}
+}
+
+func itoa(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func TestUpdateAgentCallerMemory(t *testing.T) {
+	h := apitest.New(t)
+	user, apiKey := h.CreateUser(t, "owner@example.com")
+	agent := models.Agent{UserId: user.ID, Name: "Support", PhoneNumber: "+15550000000"}
+	if err := h.DB.Create(&agent).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	body := map[string]interface{}{"name": "Support", "caller_memory": true, "caller_memory_calls": 3}
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/v1/agent", apiKey, body), http.StatusOK, nil)
+	if err := h.DB.First(&agent, agent.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !agent.CallerMemory || agent.CallerMemoryCalls != 3 {
+		t.Errorf("got caller memory %v with %d calls, want true with 3", agent.CallerMemory, agent.CallerMemoryCalls)
+	}
+
+	body["caller_memory_calls"] = -1
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/v1/agent", apiKey, body), http.StatusBadRequest, nil)
+}
+
+func TestUpsertAgentRejectsFlowTransitionsOnMissingTools(t *testing.T) {
+	h := apitest.New(t)
+	user, apiKey := h.CreateUser(t, "owner@example.com")
+	agent := models.Agent{UserId: user.ID, Name: "Host", PhoneNumber: "+15550000000"}
+	if err := h.DB.Create(&agent).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	flow := map[string]interface{}{
+		"nodes": []map[string]interface{}{
+			{"name": "greeting", "transitions": []map[string]interface{}{{"target": "booking", "tool_call": "book_table"}}},
+			{"name": "booking"},
+		},
+	}
+	body := map[string]interface{}{"name": "Host", "system_prompt": "You take reservations", "flow": flow}
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/v1/agent", apiKey, body), http.StatusBadRequest, nil)
+
+	body["tools"] = []map[string]interface{}{{
+		"type":     "function",
+		"function": map[string]interface{}{"name": "book_table", "description": "Book a table", "parameters": map[string]interface{}{"type": "object"}},
+	}}
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/v1/agent", apiKey, body), http.StatusOK, nil)
+}
+
+func TestUpdateAgentSilenceWindow(t *testing.T) {
+	h := apitest.New(t)
+	user, apiKey := h.CreateUser(t, "owner@example.com")
+	agent := models.Agent{UserId: user.ID, Name: "Support", PhoneNumber: "+15550000000"}
+	if err := h.DB.Create(&agent).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	body := map[string]interface{}{"name": "Support", "endpointer": "silence", "silence_window_ms": 800}
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/v1/agent", apiKey, body), http.StatusOK, nil)
+	if err := h.DB.First(&agent, agent.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if agent.SilenceWindowMs != 800 {
+		t.Errorf("got silence window %d ms, want 800", agent.SilenceWindowMs)
+	}
+
+	body["silence_window_ms"] = 6000
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/v1/agent", apiKey, body), http.StatusBadRequest, nil)
+}
+
+func TestUpsertAgentDedupesHandoffAgents(t *testing.T) {
+	h := apitest.New(t)
+	user, apiKey := h.CreateUser(t, "owner@example.com")
+	agents := []models.Agent{
+		{UserId: user.ID, Name: "Front Desk", PhoneNumber: "+15550000000"},
+		{UserId: user.ID, Name: "Billing", PhoneNumber: "+15550000001"},
+	}
+	if err := h.DB.Create(&agents).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	actions := []map[string]interface{}{{"name": "handoff", "agent_ids": []uint{agents[1].ID, agents[1].ID}}}
+	body := map[string]interface{}{"name": "Front Desk", "actions": actions}
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/v1/agent", apiKey, body), http.StatusOK, nil)
+
+	var agent models.Agent
+	if err := h.DB.First(&agent, agents[0].ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(agent.Actions) != 1 || len(agent.Actions[0].AgentIds) != 1 || agent.Actions[0].AgentIds[0] != agents[1].ID {
+		t.Errorf("got actions %+v, want one handoff to agent %d", agent.Actions, agents[1].ID)
+	}
+}
+
+func TestDeleteKnowledgeBaseDetachesItFromAgents(t *testing.T) {
+	h := apitest.New(t)
+	user, apiKey := h.CreateUser(t, "owner@example.com")
+	kbs := []models.KnowledgeBase{{UserId: user.ID, Name: "Menu"}, {UserId: user.ID, Name: "Hours"}}
+	if err := h.DB.Create(&kbs).Error; err != nil {
+		t.Fatal(err)
+	}
+	agent := models.Agent{UserId: user.ID, Name: "Host", PhoneNumber: "+15550000000"}
+	if err := h.DB.Create(&agent).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Repeated ids are stored once
+	body := map[string]interface{}{"name": "Host", "knowledge_base_ids": []uint{kbs[0].ID, kbs[1].ID, kbs[0].ID}}
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/v1/agent", apiKey, body), http.StatusOK, nil)
+	if err := h.DB.First(&agent, agent.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(agent.KnowledgeBaseIds) != 2 {
+		t.Fatalf("got knowledge bases %v, want %d and %d", agent.KnowledgeBaseIds, kbs[0].ID, kbs[1].ID)
+	}
+
+	apitest.Decode(t, h.Do(t, http.MethodDelete, "/v1/knowledge-base?id="+itoa(kbs[0].ID), apiKey, nil), http.StatusNoContent, nil)
+	if err := h.DB.First(&agent, agent.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(agent.KnowledgeBaseIds) != 1 || agent.KnowledgeBaseIds[0] != kbs[1].ID {
+		t.Errorf("got knowledge bases %v after the delete, want %d", agent.KnowledgeBaseIds, kbs[1].ID)
+	}
+}
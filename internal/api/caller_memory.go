@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/memory"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"gorm.io/gorm"
+)
+
+// GetCallerMemory returns what an agent remembers about a caller
+func (a *API) GetCallerMemory(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	agentID := r.URL.Query().Get("agent_id")
+	clientNumber := r.URL.Query().Get("client_number")
+	if agentID == "" || clientNumber == "" {
+		http.Error(w, "Agent ID and client number are required", http.StatusBadRequest)
+		return
+	}
+
+	var agent models.Agent
+	result := a.DB.Where("id = ? AND user_id = ?", agentID, user.ID).First(&agent)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			http.Error(w, "Agent not found", http.StatusNotFound)
+		} else {
+			logger.S.Error(result.Error)
+			http.Error(w, "Failed to retrieve agent", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	profile, err := memory.Load(a.DB, &agent, clientNumber, 0)
+	if err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to retrieve caller memory", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(profile)
+}
+
+// DeleteCallerMemory erases a caller from the user's calls, e.g. for privacy requests. Without an
+// agent_id the caller is erased from the calls of every agent.
+func (a *API) DeleteCallerMemory(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	clientNumber := r.URL.Query().Get("client_number")
+	if clientNumber == "" {
+		http.Error(w, "Client number is required", http.StatusBadRequest)
+		return
+	}
+
+	var agentID uint64
+	if value := r.URL.Query().Get("agent_id"); value != "" {
+		agentID, err = strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid agent_id parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	erasure, err := memory.Erase(a.DB, user.ID, uint(agentID), clientNumber)
+	if err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to erase caller memory", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(erasure)
+}
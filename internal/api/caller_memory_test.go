@@ -0,0 +1,95 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/flyflow-devs/flyflow/internal/apitest"
+	"github.com/flyflow-devs/flyflow/internal/jobs"
+	"github.com/flyflow-devs/flyflow/internal/memory"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/recordings"
+)
+
+func TestDeleteCallerMemoryErasesEverythingAboutTheCaller(t *testing.T) {
+	h := apitest.New(t)
+	user, apiKey := h.CreateUser(t, "owner@example.com")
+	calls := createCalls(t, h, user,
+		models.Call{ClientNumber: "+15551111111", Summary: "Asked about a refund", RecordingSid: "RE123", RecordingPath: "/recordings/RE123.mp3"},
+		models.Call{ClientNumber: "+15552222222", Summary: "Booked a table"},
+	)
+
+	decision := models.EndpointingDecision{CallId: calls[0].ID, Transcript: "I want my money back"}
+	if err := h.DB.Create(&decision).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jobs.Enqueue(h.DB, "webhook", map[string]string{"summary": "Asked about a refund"}, jobs.ForCall(calls[0].ID)); err != nil {
+		t.Fatal(err)
+	}
+	callback := models.ScheduledCall{SourceCallId: calls[0].ID, Context: "Call back about the refund"}
+	if err := h.DB.Create(&callback).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Calls scheduled to the caller through the API and campaign contacts with their number hold what's known about them too
+	otherUser, _ := h.CreateUser(t, "other@example.com")
+	scheduled := []models.ScheduledCall{
+		{UserId: user.ID, To: "+15551111111", Context: "Renewal offer", Variables: map[string]string{"name": "Ana"}},
+		{UserId: user.ID, To: "+15552222222", Context: "Booking reminder"},
+		{UserId: otherUser.ID, To: "+15551111111", Context: "Another business"},
+	}
+	if err := h.DB.Create(&scheduled).Error; err != nil {
+		t.Fatal(err)
+	}
+	campaign := models.Campaign{UserId: user.ID, Name: "Renewals"}
+	if err := h.DB.Create(&campaign).Error; err != nil {
+		t.Fatal(err)
+	}
+	contact := models.CampaignContact{CampaignId: campaign.ID, PhoneNumber: "+15551111111", Variables: map[string]string{"name": "Ana"}}
+	if err := h.DB.Create(&contact).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var erasure memory.Erasure
+	apitest.Decode(t, h.Do(t, http.MethodDelete, "/v1/caller-memory?client_number=%2B15551111111", apiKey, nil), http.StatusOK, &erasure)
+	want := memory.Erasure{Calls: 1, EndpointingDecisions: 1, Jobs: 1, ScheduledCalls: 2, CampaignContacts: 1, RecordingsDeleting: 1}
+	if erasure != want {
+		t.Errorf("got %+v, want %+v", erasure, want)
+	}
+
+	var call models.Call
+	if err := h.DB.First(&call, calls[0].ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if call.ClientNumber != "" || call.Summary != "" || call.RecordingSid != "" || call.RecordingPath != "" {
+		t.Errorf("call still has caller data: %+v", call)
+	}
+	var other models.Call
+	if err := h.DB.First(&other, calls[1].ID).Error; err != nil || other.Summary != "Booked a table" {
+		t.Errorf("another caller's call was erased")
+	}
+	if err := h.DB.First(&callback, callback.ID).Error; err != nil || callback.Context != "" {
+		t.Errorf("callback context %q wasn't erased", callback.Context)
+	}
+	wantContext := []string{"", "Booking reminder", "Another business"}
+	for i := range scheduled {
+		if err := h.DB.First(&scheduled[i], scheduled[i].ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if scheduled[i].Context != wantContext[i] || (i == 0 && len(scheduled[i].Variables) > 0) {
+			t.Errorf("scheduled call to %s has context %q and variables %v, want context %q", scheduled[i].To, scheduled[i].Context, scheduled[i].Variables, wantContext[i])
+		}
+	}
+	if err := h.DB.First(&contact, contact.ID).Error; err != nil || len(contact.Variables) > 0 {
+		t.Errorf("campaign contact variables %v weren't erased", contact.Variables)
+	}
+
+	// Only the recording deletion is left in the queue
+	var queued []models.Job
+	if err := h.DB.Find(&queued).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 1 || queued[0].Type != recordings.DeleteJobType {
+		t.Fatalf("got jobs %+v, want one %s job", queued, recordings.DeleteJobType)
+	}
+}
@@ -3,17 +3,25 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gorilla/mux"
 	"gorm.io/gorm"
 	"io"
 	"net/http"
+	"os"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/flyflow-devs/flyflow/internal/compliance"
+	"github.com/flyflow-devs/flyflow/internal/config"
 	"github.com/flyflow-devs/flyflow/internal/logger"
 	"github.com/flyflow-devs/flyflow/internal/models"
-	"github.com/twilio/twilio-go"
-	openapi "github.com/twilio/twilio-go/rest/api/v2010"
+	"github.com/flyflow-devs/flyflow/internal/ownership"
+	"github.com/flyflow-devs/flyflow/internal/outbound"
+	"github.com/flyflow-devs/flyflow/internal/prompts"
+	"github.com/flyflow-devs/flyflow/internal/recordings"
 )
 
 func (a *API) CreateCall(w http.ResponseWriter, r *http.Request) {
@@ -30,7 +38,14 @@ func (a *API) CreateCall(w http.ResponseWriter, r *http.Request) {
 		To      string `json:"to"`
 		Context string `json:"context"`
 
+		Variables map[string]string `json:"variables"`
+
 		UserSpeaksFirst bool `json:"user_speaks_first"`
+
+		// Timezone is the recipient's timezone, used to enforce calling hours
+		Timezone string `json:"timezone"`
+
+		ScheduledAt *time.Time `json:"scheduled_at"`
 	}
 	err = json.NewDecoder(r.Body).Decode(&callReq)
 	if err != nil {
@@ -52,38 +67,87 @@ func (a *API) CreateCall(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create a new Twilio client
-	client := twilio.NewRestClientWithParams(twilio.ClientParams{
-		Username: a.Cfg.TwilioAccountSid,
-		Password: a.Cfg.TwilioAccountAuthToken,
-	})
-
-	// Make the phone call using the Twilio API
-	params := &openapi.CreateCallParams{}
-	params.SetTo(callReq.To)
-	params.SetFrom(callReq.From)
-	params.SetUrl(a.Cfg.TwilioMLUrl)
-	resp, err := client.Api.CreateCall(params)
-	if err != nil {
-		logger.S.Error(err)
-		http.Error(w, "Failed to make phone call", http.StatusInternalServerError)
+	to, ok := compliance.NormalizePhoneNumber(callReq.To)
+	if !ok {
+		http.Error(w, "Invalid request payload, 'to' must be a phone number in E.164 format, e.g. +14155550100", http.StatusBadRequest)
 		return
 	}
 
-	// Create a new Call object
-	call := &models.Call{
-		AgentId:    agent.ID,
-		Context:    callReq.Context,
-		Sid:        *resp.Sid,
-		StartedAt:  time.Now(),
+	req := outbound.Request{
+		To:              to,
+		Context:         callReq.Context,
+		Variables:       callReq.Variables,
 		UserSpeaksFirst: callReq.UserSpeaksFirst,
+		Timezone:        callReq.Timezone,
 	}
 
-	// Save the Call object in the database
-	result = a.DB.Create(call)
-	if result.Error != nil {
-		logger.S.Error(result.Error)
-		http.Error(w, "Failed to create call record", http.StatusInternalServerError)
+	if callReq.Timezone != "" {
+		if _, err := time.LoadLocation(callReq.Timezone); err != nil {
+			http.Error(w, "Invalid request payload, timezone must be a valid IANA timezone such as America/New_York", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scheduled calls are placed by the job worker
+	if callReq.ScheduledAt != nil && callReq.ScheduledAt.After(time.Now()) {
+		if err := outbound.Validate(&agent, req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request payload, %v", err), http.StatusBadRequest)
+			return
+		}
+
+		// Calling hours are checked when the call is placed, the do not call list is checked right away as well
+		listed, err := compliance.IsOnDoNotCallList(a.DB, user.ID, req.To)
+		if err != nil {
+			logger.S.Error(err)
+			http.Error(w, "Failed to check the do not call list", http.StatusInternalServerError)
+			return
+		}
+		if listed {
+			http.Error(w, (&compliance.DoNotCallError{PhoneNumber: req.To}).Error(), http.StatusForbidden)
+			return
+		}
+
+		scheduled := &models.ScheduledCall{
+			UserId:          user.ID,
+			AgentId:         agent.ID,
+			To:              req.To,
+			Context:         req.Context,
+			Variables:       req.Variables,
+			UserSpeaksFirst: req.UserSpeaksFirst,
+			Timezone:        req.Timezone,
+			ScheduledAt:     *callReq.ScheduledAt,
+			Source:          models.ScheduledCallSourceAPI,
+		}
+		if err := outbound.Schedule(a.DB, scheduled); err != nil {
+			logger.S.Error(err)
+			http.Error(w, "Failed to schedule phone call", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(scheduled)
+		return
+	}
+
+	// Make the phone call
+	call, err := outbound.Place(a.Cfg, a.DB, &agent, req)
+	if err != nil {
+		var missing *prompts.MissingVariablesError
+		var dnc *compliance.DoNotCallError
+		var hours *compliance.CallingHoursError
+		if errors.As(err, &missing) {
+			http.Error(w, fmt.Sprintf("Invalid request payload, %v", err), http.StatusBadRequest)
+		} else if errors.As(err, &dnc) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+		} else if errors.As(err, &hours) {
+			http.Error(w, err.Error()+", pass scheduled_at to call then", http.StatusUnprocessableEntity)
+		} else if call != nil {
+			logger.S.Error(err)
+			http.Error(w, "Failed to create call record", http.StatusInternalServerError)
+		} else {
+			logger.S.Error(err)
+			http.Error(w, "Failed to make phone call", http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -157,13 +221,23 @@ func (a *API) SetCallContext(w http.ResponseWriter, r *http.Request) {
 
 	// Update the call context
 	call.Context = contextReq.Context
-	result = a.DB.Save(&call)
+	result = a.DB.Model(&call).Update("context", call.Context)
 	if result.Error != nil {
 		logger.S.Error(result.Error)
 		http.Error(w, "Failed to update call context", http.StatusInternalServerError)
 		return
 	}
 
+	// Pass the context to the orchestrator running the call, which may be on another instance
+	if call.InProgress {
+		err = a.Registry.Dispatch(r.Context(), call.ID, ownership.Command{Type: ownership.CommandSetContext, Context: call.Context})
+		if err != nil && !errors.Is(err, ownership.ErrNotLive) {
+			logger.S.Errorf("error sending context to call %v: %v", call.ID, err)
+			http.Error(w, "Failed to update the context of the call in progress", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
 	// Return the updated call object
 	json.NewEncoder(w).Encode(call)
 }
@@ -185,6 +259,8 @@ func (a *API) ListCalls(w http.ResponseWriter, r *http.Request) {
 	}
 	agentID := queryParams.Get("agent_id")
 	clientNumber := queryParams.Get("client_number")
+	disposition := queryParams.Get("disposition")
+	campaignID := queryParams.Get("campaign_id")
 
 	// Convert limit to integer
 	limitInt, err := strconv.Atoi(limit)
@@ -201,7 +277,12 @@ func (a *API) ListCalls(w http.ResponseWriter, r *http.Request) {
 
 	// Apply cursor if provided
 	if cursor != "" {
-		query = query.Where("created_at < ?", cursor)
+		cursorTime, err := parseCursor(cursor)
+		if err != nil {
+			http.Error(w, "Invalid cursor parameter", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("created_at < ?", cursorTime)
 	}
 
 	// Apply agent_id filter if provided
@@ -214,6 +295,34 @@ func (a *API) ListCalls(w http.ResponseWriter, r *http.Request) {
 		query = query.Where("client_number = ?", clientNumber)
 	}
 
+	// Apply disposition filter if provided
+	if disposition != "" {
+		query = query.Where("disposition = ?", disposition)
+	}
+
+	// Apply campaign_id filter if provided
+	if campaignID != "" {
+		query = query.Where("campaign_id = ?", campaignID)
+	}
+
+	// Apply type filter if provided, e.g. type=voicemail
+	if callType := queryParams.Get("type"); callType != "" {
+		query = query.Where("type = ?", callType)
+	}
+
+	// Apply extracted data filters, e.g. extracted.appointment.confirmed=true
+	for key, values := range queryParams {
+		if !strings.HasPrefix(key, extractedFilterPrefix) {
+			continue
+		}
+		expression, err := extractedDataExpression(a.DB.Dialector.Name(), strings.TrimPrefix(key, extractedFilterPrefix))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid filter %s: %v", key, err), http.StatusBadRequest)
+			return
+		}
+		query = query.Where(expression+" = ?", values[0])
+	}
+
 	// Retrieve the calls from the database
 	var calls []models.Call
 	result := query.Find(&calls)
@@ -248,6 +357,37 @@ func (a *API) ListCalls(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+const extractedFilterPrefix = "extracted."
+
+var extractedFieldPattern = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// extractedDataExpression builds the SQL expression selecting a dotted path inside calls.extracted_data as text
+func extractedDataExpression(dialect string, path string) (string, error) {
+	fields := strings.Split(path, ".")
+	for _, field := range fields {
+		if !extractedFieldPattern.MatchString(field) {
+			return "", errors.New("field names may only contain letters, numbers and underscores")
+		}
+	}
+
+	// SQLite's ->> returns numbers and booleans as SQL values, convert them to the text Postgres returns
+	if dialect == config.DBDriverSQLite {
+		jsonPath := "'$." + path + "'"
+		return "IIF(json_type(extracted_data, " + jsonPath + ") IN ('true', 'false'), json_type(extracted_data, " + jsonPath + "), " +
+			"CAST(extracted_data ->> " + jsonPath + " AS TEXT))", nil
+	}
+
+	expression := "extracted_data"
+	for i, field := range fields {
+		if i == len(fields)-1 {
+			expression += " ->> '" + field + "'"
+		} else {
+			expression += " -> '" + field + "'"
+		}
+	}
+	return expression, nil
+}
+
 func (a *API) GetRecording(w http.ResponseWriter, r *http.Request) {
 	// Validate the API key
 	user, err := a.ValidateAuth(r)
@@ -277,9 +417,21 @@ func (a *API) GetRecording(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Serve the archived copy when there is one
+	if call.RecordingPath != "" {
+		file, err := os.Open(call.RecordingPath)
+		if err == nil {
+			defer file.Close()
+			w.Header().Set("Content-Type", "audio/mpeg")
+			w.WriteHeader(http.StatusOK)
+			io.Copy(w, file)
+			return
+		}
+		logger.S.Errorf("error opening archived recording for call %v, falling back to twilio, RECORDING_ARCHIVE_DIR has to be shared with the workers: %v", call.ID, err)
+	}
+
 	// Fetch the recording media file from Twilio
-	recordingURI := "https://api.twilio.com/2010-04-01/Accounts/" + a.Cfg.TwilioAccountSid + "/Recordings/" + call.RecordingSid + ".mp3"
-	resp, err := downloadRecording(recordingURI, a.Cfg.TwilioAccountSid, a.Cfg.TwilioAccountAuthToken)
+	resp, err := recordings.Download(r.Context(), a.Cfg, call.RecordingSid)
 	if err != nil {
 		logger.S.Error(err)
 		http.Error(w, "Failed to download recording", http.StatusInternalServerError)
@@ -293,17 +445,6 @@ func (a *API) GetRecording(w http.ResponseWriter, r *http.Request) {
 	io.Copy(w, resp.Body)
 }
 
-// downloadRecording is a helper function to download a recording from Twilio
-func downloadRecording(url, username, password string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.SetBasicAuth(username, password)
-	client := &http.Client{}
-	return client.Do(req)
-}
-
 func (a *API) DeleteCall(w http.ResponseWriter, r *http.Request) {
 	// Validate the API key
 	user, err := a.ValidateAuth(r)
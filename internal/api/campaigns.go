@@ -0,0 +1,396 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/flyflow-devs/flyflow/internal/campaigns"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"gorm.io/gorm"
+)
+
+// maxContactsSize is the largest contact list that can be uploaded at once
+const maxContactsSize = 10 << 20
+
+func (a *API) UpsertCampaign(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse the request body
+	var campaignReq models.Campaign
+	if err := json.NewDecoder(r.Body).Decode(&campaignReq); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if campaignReq.MaxConcurrentCalls == 0 {
+		campaignReq.MaxConcurrentCalls = 1
+	}
+	if err := campaigns.Validate(&campaignReq); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request payload, %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Validate that the agent belongs to the user
+	var agent models.Agent
+	result := a.DB.Where("id = ? AND user_id = ?", campaignReq.AgentId, user.ID).First(&agent)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			http.Error(w, "Invalid request payload, agent not found", http.StatusBadRequest)
+		} else {
+			logger.S.Error(result.Error)
+			http.Error(w, "Failed to retrieve agent", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Find the existing campaign based on the user ID and name
+	var campaign models.Campaign
+	result = a.DB.Where("user_id = ? AND name = ?", user.ID, campaignReq.Name).First(&campaign)
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		logger.S.Error(result.Error)
+		http.Error(w, "Failed to retrieve campaign", http.StatusInternalServerError)
+		return
+	}
+
+	campaign.UserId = user.ID
+	campaign.AgentId = agent.ID
+	campaign.Name = campaignReq.Name
+	campaign.MaxConcurrentCalls = campaignReq.MaxConcurrentCalls
+	campaign.Timezone = campaignReq.Timezone
+	campaign.CallingWindow = campaignReq.CallingWindow
+	campaign.Retry = campaignReq.Retry
+	if campaign.Status == "" {
+		campaign.Status = models.CampaignStatusDraft
+	}
+
+	if err := a.DB.Save(&campaign).Error; err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to save campaign", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(campaign)
+}
+
+func (a *API) GetCampaign(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	campaign, ok := a.findCampaign(w, user, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+
+	statuses, outcomes, err := campaigns.Progress(a.DB, campaign.ID)
+	if err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to retrieve campaign progress", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		models.Campaign
+		Contacts map[string]int64 `json:"contacts"`
+		Outcomes map[string]int64 `json:"outcomes"`
+	}{
+		Campaign: *campaign,
+		Contacts: statuses,
+		Outcomes: outcomes,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (a *API) ListCampaigns(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var campaignList []models.Campaign
+	if err := a.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Find(&campaignList).Error; err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to retrieve campaigns", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(campaignList)
+}
+
+func (a *API) DeleteCampaign(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	campaign, ok := a.findCampaign(w, user, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+
+	if campaign.Status == models.CampaignStatusRunning {
+		http.Error(w, "Campaign is running, pause or cancel it before deleting it", http.StatusConflict)
+		return
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("campaign_id = ?", campaign.ID).Delete(&models.CampaignContact{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(campaign).Error
+	})
+	if err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to delete campaign", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UploadCampaignContacts adds contacts to a campaign. Contacts can be sent as a JSON body, as a CSV body
+// or as a multipart form with a CSV "file" field.
+func (a *API) UploadCampaignContacts(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var contactsReq struct {
+		CampaignId uint                `json:"campaign_id"`
+		Contacts   []campaigns.Contact `json:"contacts"`
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxContactsSize)
+	contentType := r.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "multipart/form-data"):
+		if err := r.ParseMultipartForm(maxContactsSize); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Invalid request payload, file is required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		fmt.Sscan(r.FormValue("campaign_id"), &contactsReq.CampaignId)
+		contactsReq.Contacts, err = campaigns.ParseCSV(file)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request payload, %v", err), http.StatusBadRequest)
+			return
+		}
+	case strings.HasPrefix(contentType, "text/csv"):
+		fmt.Sscan(r.URL.Query().Get("campaign_id"), &contactsReq.CampaignId)
+		contactsReq.Contacts, err = campaigns.ParseCSV(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request payload, %v", err), http.StatusBadRequest)
+			return
+		}
+	default:
+		if err := json.NewDecoder(r.Body).Decode(&contactsReq); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if len(contactsReq.Contacts) == 0 {
+		http.Error(w, "Invalid request payload, at least one contact is required", http.StatusBadRequest)
+		return
+	}
+	if err := campaigns.ValidateContacts(contactsReq.Contacts); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request payload, %v", err), http.StatusBadRequest)
+		return
+	}
+
+	campaign, ok := a.findCampaign(w, user, strconv.FormatUint(uint64(contactsReq.CampaignId), 10))
+	if !ok {
+		return
+	}
+	if campaign.Status == models.CampaignStatusCancelled || campaign.Status == models.CampaignStatusCompleted {
+		http.Error(w, "Contacts can't be added to a "+campaign.Status+" campaign", http.StatusConflict)
+		return
+	}
+
+	contacts := campaigns.NewContacts(campaign, contactsReq.Contacts)
+	if err := a.DB.CreateInBatches(contacts, 500).Error; err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to save contacts", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]int{"num_contacts": len(contacts)})
+}
+
+func (a *API) ListCampaignContacts(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	queryParams := r.URL.Query()
+	campaign, ok := a.findCampaign(w, user, queryParams.Get("campaign_id"))
+	if !ok {
+		return
+	}
+
+	cursor := queryParams.Get("cursor")
+	limit := queryParams.Get("limit")
+	if limit == "" {
+		limit = "10"
+	}
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil {
+		http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+		return
+	}
+
+	query := a.DB.Where("campaign_id = ?", campaign.ID).
+		Order("id").
+		Limit(limitInt + 1)
+	if cursor != "" {
+		query = query.Where("id > ?", cursor)
+	}
+	if status := queryParams.Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if outcome := queryParams.Get("outcome"); outcome != "" {
+		query = query.Where("outcome = ?", outcome)
+	}
+
+	var contacts []models.CampaignContact
+	if err := query.Find(&contacts).Error; err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to retrieve contacts", http.StatusInternalServerError)
+		return
+	}
+
+	hasMore := len(contacts) > limitInt
+	if hasMore {
+		contacts = contacts[:limitInt]
+	}
+
+	response := struct {
+		NumItems int                      `json:"num_items"`
+		Cursor   string                   `json:"cursor,omitempty"`
+		Contacts []models.CampaignContact `json:"contacts"`
+	}{
+		NumItems: len(contacts),
+		Contacts: contacts,
+	}
+	if hasMore {
+		response.Cursor = strconv.FormatUint(uint64(contacts[len(contacts)-1].ID), 10)
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// StartCampaign starts dialing a draft campaign or resumes a paused one
+func (a *API) StartCampaign(w http.ResponseWriter, r *http.Request) {
+	a.changeCampaignStatus(w, r, func(campaign *models.Campaign) (int, error) {
+		if campaign.Status != models.CampaignStatusDraft && campaign.Status != models.CampaignStatusPaused {
+			return http.StatusConflict, fmt.Errorf("a %s campaign can't be started", campaign.Status)
+		}
+		if a.Cfg.TwilioStatusCallbackUrl == "" {
+			return http.StatusInternalServerError, errors.New("campaigns need TWILIO_STATUS_CALLBACK_URL to be configured")
+		}
+		return http.StatusInternalServerError, campaigns.Start(a.DB, campaign)
+	})
+}
+
+func (a *API) PauseCampaign(w http.ResponseWriter, r *http.Request) {
+	a.changeCampaignStatus(w, r, func(campaign *models.Campaign) (int, error) {
+		if campaign.Status != models.CampaignStatusRunning {
+			return http.StatusConflict, fmt.Errorf("a %s campaign can't be paused", campaign.Status)
+		}
+		return http.StatusInternalServerError, campaigns.Pause(a.DB, campaign)
+	})
+}
+
+func (a *API) CancelCampaign(w http.ResponseWriter, r *http.Request) {
+	a.changeCampaignStatus(w, r, func(campaign *models.Campaign) (int, error) {
+		if campaign.Status == models.CampaignStatusCancelled || campaign.Status == models.CampaignStatusCompleted {
+			return http.StatusConflict, fmt.Errorf("a %s campaign can't be cancelled", campaign.Status)
+		}
+		return http.StatusInternalServerError, campaigns.Cancel(a.DB, campaign)
+	})
+}
+
+// changeCampaignStatus loads the campaign in the request body and applies change to it. change returns the
+// status code to respond with when it fails.
+func (a *API) changeCampaignStatus(w http.ResponseWriter, r *http.Request, change func(campaign *models.Campaign) (int, error)) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var statusReq struct {
+		ID uint `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&statusReq); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	campaign, ok := a.findCampaign(w, user, strconv.FormatUint(uint64(statusReq.ID), 10))
+	if !ok {
+		return
+	}
+
+	if status, err := change(campaign); err != nil {
+		if status == http.StatusInternalServerError {
+			logger.S.Error(err)
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	json.NewEncoder(w).Encode(campaign)
+}
+
+// findCampaign loads one of the user's campaigns, writing the error response when it can't
+func (a *API) findCampaign(w http.ResponseWriter, user *models.User, campaignID string) (*models.Campaign, bool) {
+	if campaignID == "" || campaignID == "0" {
+		http.Error(w, "Campaign ID is required", http.StatusBadRequest)
+		return nil, false
+	}
+
+	var campaign models.Campaign
+	result := a.DB.Where("id = ? AND user_id = ?", campaignID, user.ID).First(&campaign)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			http.Error(w, "Campaign not found", http.StatusNotFound)
+		} else {
+			logger.S.Error(result.Error)
+			http.Error(w, "Failed to retrieve campaign", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return &campaign, true
+}
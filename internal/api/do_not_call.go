@@ -0,0 +1,205 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/flyflow-devs/flyflow/internal/compliance"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+)
+
+// AddDoNotCall adds one or more numbers to the user's do not call list
+func (a *API) AddDoNotCall(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var dncReq struct {
+		PhoneNumbers []string `json:"phone_numbers"`
+		Reason       string   `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&dncReq); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if len(dncReq.PhoneNumbers) == 0 {
+		http.Error(w, "Invalid request payload, phone_numbers is required", http.StatusBadRequest)
+		return
+	}
+
+	entries := make([]models.DoNotCallEntry, 0, len(dncReq.PhoneNumbers))
+	for _, number := range dncReq.PhoneNumbers {
+		phoneNumber, ok := compliance.NormalizePhoneNumber(number)
+		if !ok {
+			http.Error(w, fmt.Sprintf("Invalid request payload, phone number %q must be in E.164 format, e.g. +14155550100", number), http.StatusBadRequest)
+			return
+		}
+		entries = append(entries, models.DoNotCallEntry{
+			UserId:      user.ID,
+			PhoneNumber: phoneNumber,
+			Source:      models.DoNotCallSourceAPI,
+			Reason:      dncReq.Reason,
+		})
+	}
+
+	if err := compliance.AddToDoNotCallList(a.DB, entries); err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to update the do not call list", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]int{"num_phone_numbers": len(entries)})
+}
+
+// ImportDoNotCall adds the numbers in a CSV, sent either as the body or as a multipart form "file" field
+func (a *API) ImportDoNotCall(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxContactsSize)
+
+	var entries []models.DoNotCallEntry
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxContactsSize); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Invalid request payload, file is required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		entries, err = compliance.ParseDoNotCallCSV(file)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request payload, %v", err), http.StatusBadRequest)
+			return
+		}
+	} else {
+		entries, err = compliance.ParseDoNotCallCSV(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request payload, %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	for i := range entries {
+		entries[i].UserId = user.ID
+		entries[i].Source = models.DoNotCallSourceImport
+	}
+
+	if err := compliance.AddToDoNotCallList(a.DB, entries); err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to update the do not call list", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]int{"num_phone_numbers": len(entries)})
+}
+
+func (a *API) ListDoNotCall(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	queryParams := r.URL.Query()
+	cursor := queryParams.Get("cursor")
+	limit := queryParams.Get("limit")
+	if limit == "" {
+		limit = "10"
+	}
+
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil {
+		http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+		return
+	}
+
+	query := a.DB.Where("user_id = ?", user.ID).
+		Order("id").
+		Limit(limitInt + 1)
+	if cursor != "" {
+		query = query.Where("id > ?", cursor)
+	}
+	if phoneNumber := queryParams.Get("phone_number"); phoneNumber != "" {
+		if normalized, ok := compliance.NormalizePhoneNumber(phoneNumber); ok {
+			phoneNumber = normalized
+		}
+		query = query.Where("phone_number = ?", phoneNumber)
+	}
+	if source := queryParams.Get("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+
+	var entries []models.DoNotCallEntry
+	if err := query.Find(&entries).Error; err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to retrieve the do not call list", http.StatusInternalServerError)
+		return
+	}
+
+	hasMore := len(entries) > limitInt
+	if hasMore {
+		entries = entries[:limitInt]
+	}
+
+	response := struct {
+		NumItems int                     `json:"num_items"`
+		Cursor   string                  `json:"cursor,omitempty"`
+		Entries  []models.DoNotCallEntry `json:"entries"`
+	}{
+		NumItems: len(entries),
+		Entries:  entries,
+	}
+	if hasMore {
+		response.Cursor = strconv.FormatUint(uint64(entries[len(entries)-1].ID), 10)
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (a *API) DeleteDoNotCall(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	phoneNumber := r.URL.Query().Get("phone_number")
+	if phoneNumber == "" {
+		http.Error(w, "Phone number is required", http.StatusBadRequest)
+		return
+	}
+	if normalized, ok := compliance.NormalizePhoneNumber(phoneNumber); ok {
+		phoneNumber = normalized
+	}
+
+	result := a.DB.Where("user_id = ? AND phone_number = ?", user.ID, phoneNumber).Delete(&models.DoNotCallEntry{})
+	if result.Error != nil {
+		logger.S.Error(result.Error)
+		http.Error(w, "Failed to update the do not call list", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "Phone number not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
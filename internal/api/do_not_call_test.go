@@ -0,0 +1,50 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/apitest"
+	"github.com/flyflow-devs/flyflow/internal/models"
+)
+
+func TestCreateCallRefusesFormattingVariantsOfListedNumbers(t *testing.T) {
+	h := apitest.New(t)
+	user, apiKey := h.CreateUser(t, "owner@example.com")
+	agent := models.Agent{UserId: user.ID, Name: "Sales", PhoneNumber: "+15550000000"}
+	if err := h.DB.Create(&agent).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	body := map[string]interface{}{"phone_numbers": []string{"+15551234567"}}
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/v1/dnc", apiKey, body), http.StatusOK, nil)
+
+	scheduledAt := time.Now().Add(time.Hour)
+	for _, to := range []string{"+15551234567", "15551234567", "5551234567", "+1 (555) 123-4567", "555.123.4567"} {
+		call := map[string]interface{}{"from": agent.PhoneNumber, "to": to}
+		apitest.Decode(t, h.Do(t, http.MethodPost, "/v1/call", apiKey, call), http.StatusForbidden, nil)
+
+		call["scheduled_at"] = scheduledAt
+		apitest.Decode(t, h.Do(t, http.MethodPost, "/v1/call", apiKey, call), http.StatusForbidden, nil)
+	}
+
+	call := map[string]interface{}{"from": agent.PhoneNumber, "to": "12345"}
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/v1/call", apiKey, call), http.StatusBadRequest, nil)
+}
+
+func TestAddDoNotCallStoresNumbersInE164(t *testing.T) {
+	h := apitest.New(t)
+	user, apiKey := h.CreateUser(t, "owner@example.com")
+
+	body := map[string]interface{}{"phone_numbers": []string{"(555) 123-4567"}}
+	apitest.Decode(t, h.Do(t, http.MethodPost, "/v1/dnc", apiKey, body), http.StatusOK, nil)
+
+	var entry models.DoNotCallEntry
+	if err := h.DB.Where("user_id = ?", user.ID).First(&entry).Error; err != nil {
+		t.Fatal(err)
+	}
+	if entry.PhoneNumber != "+15551234567" {
+		t.Errorf("got %q on the list, want +15551234567", entry.PhoneNumber)
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (a *API) GetJob(w http.ResponseWriter, r *http.Request) {
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	jobID := r.URL.Query().Get("id")
+	if jobID == "" {
+		http.Error(w, "Job ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var job models.Job
+	result := a.DB.Where("id = ? AND user_id = ?", jobID, user.ID).First(&job)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			http.Error(w, "Job not found", http.StatusNotFound)
+		} else {
+			logger.S.Error(result.Error)
+			http.Error(w, "Failed to retrieve job", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(job)
+}
+
+func (a *API) ListJobs(w http.ResponseWriter, r *http.Request) {
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	queryParams := r.URL.Query()
+	cursor := queryParams.Get("cursor")
+	limit := queryParams.Get("limit")
+	if limit == "" {
+		limit = "10"
+	}
+
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil {
+		http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+		return
+	}
+
+	query := a.DB.Model(&models.Job{}).
+		Where("user_id = ?", user.ID).
+		Order("created_at DESC").
+		Limit(limitInt + 1)
+
+	if cursor != "" {
+		cursorTime, err := parseCursor(cursor)
+		if err != nil {
+			http.Error(w, "Invalid cursor parameter", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("created_at < ?", cursorTime)
+	}
+	if status := queryParams.Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if jobType := queryParams.Get("type"); jobType != "" {
+		query = query.Where("type = ?", jobType)
+	}
+	if callID := queryParams.Get("call_id"); callID != "" {
+		query = query.Where("call_id = ?", callID)
+	}
+
+	var jobs []models.Job
+	result := query.Find(&jobs)
+	if result.Error != nil {
+		logger.S.Error(result.Error)
+		http.Error(w, "Failed to retrieve jobs", http.StatusInternalServerError)
+		return
+	}
+
+	hasMore := len(jobs) > limitInt
+	if hasMore {
+		jobs = jobs[:limitInt]
+	}
+
+	response := struct {
+		NumItems int          `json:"num_items"`
+		Cursor   string       `json:"cursor,omitempty"`
+		Jobs     []models.Job `json:"jobs"`
+	}{
+		NumItems: len(jobs),
+		Jobs:     jobs,
+	}
+
+	if hasMore {
+		response.Cursor = jobs[len(jobs)-1].CreatedAt.Format(time.RFC3339)
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,353 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/flyflow-devs/flyflow/internal/knowledge"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/sashabaranov/go-openai"
+	"gorm.io/gorm"
+)
+
+// maxDocumentSize is the largest document that can be uploaded to a knowledge base
+const maxDocumentSize = 10 << 20
+
+func (a *API) UpsertKnowledgeBase(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse the request body
+	var kbReq models.KnowledgeBase
+	if err := json.NewDecoder(r.Body).Decode(&kbReq); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if kbReq.Name == "" {
+		http.Error(w, "Invalid request payload, name is required", http.StatusBadRequest)
+		return
+	}
+
+	// Find the existing knowledge base based on the user ID and name
+	var kb models.KnowledgeBase
+	result := a.DB.Where("user_id = ? AND name = ?", user.ID, kbReq.Name).First(&kb)
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		logger.S.Error(result.Error)
+		http.Error(w, "Failed to retrieve knowledge base", http.StatusInternalServerError)
+		return
+	}
+
+	kb.UserId = user.ID
+	kb.Name = kbReq.Name
+	kb.Description = kbReq.Description
+
+	if err := a.DB.Save(&kb).Error; err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to save knowledge base", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(kb)
+}
+
+func (a *API) GetKnowledgeBase(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	kbID := r.URL.Query().Get("id")
+	if kbID == "" {
+		http.Error(w, "Knowledge base ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var kb models.KnowledgeBase
+	result := a.DB.Where("id = ? AND user_id = ?", kbID, user.ID).First(&kb)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			http.Error(w, "Knowledge base not found", http.StatusNotFound)
+		} else {
+			logger.S.Error(result.Error)
+			http.Error(w, "Failed to retrieve knowledge base", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var documents []models.Document
+	if err := a.DB.Where("knowledge_base_id = ?", kb.ID).Order("created_at DESC").Find(&documents).Error; err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to retrieve documents", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		models.KnowledgeBase
+		Documents []models.Document `json:"documents"`
+	}{
+		KnowledgeBase: kb,
+		Documents:     documents,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (a *API) ListKnowledgeBases(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var kbs []models.KnowledgeBase
+	if err := a.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Find(&kbs).Error; err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to retrieve knowledge bases", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		NumItems       int                    `json:"num_items"`
+		KnowledgeBases []models.KnowledgeBase `json:"knowledge_bases"`
+	}{
+		NumItems:       len(kbs),
+		KnowledgeBases: kbs,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (a *API) DeleteKnowledgeBase(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	kbID := r.URL.Query().Get("id")
+	if kbID == "" {
+		http.Error(w, "Knowledge base ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var kb models.KnowledgeBase
+	result := a.DB.Where("id = ? AND user_id = ?", kbID, user.ID).First(&kb)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			http.Error(w, "Knowledge base not found", http.StatusNotFound)
+		} else {
+			logger.S.Error(result.Error)
+			http.Error(w, "Failed to retrieve knowledge base", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Delete the knowledge base along with its documents and chunks, and detach it from the agents using it
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		var agents []models.Agent
+		if err := tx.Select("id", "knowledge_base_ids").Where("user_id = ?", user.ID).Find(&agents).Error; err != nil {
+			return err
+		}
+		for _, agent := range agents {
+			ids := make([]uint, 0, len(agent.KnowledgeBaseIds))
+			for _, id := range agent.KnowledgeBaseIds {
+				if id != kb.ID {
+					ids = append(ids, id)
+				}
+			}
+			if len(ids) == len(agent.KnowledgeBaseIds) {
+				continue
+			}
+			agent.KnowledgeBaseIds = ids
+			if err := tx.Model(&agent).Select("knowledge_base_ids").Updates(&agent).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("knowledge_base_id = ?", kb.ID).Delete(&models.DocumentChunk{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("knowledge_base_id = ?", kb.ID).Delete(&models.Document{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&kb).Error
+	})
+	if err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to delete knowledge base", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UploadDocument adds a document to a knowledge base. Documents can be sent either as a JSON body
+// with the content inline or as a multipart form with a "file" field.
+func (a *API) UploadDocument(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var docReq struct {
+		KnowledgeBaseId uint   `json:"knowledge_base_id"`
+		Name            string `json:"name"`
+		ContentType     string `json:"content_type"`
+		Content         string `json:"content"`
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxDocumentSize)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxDocumentSize); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Invalid request payload, file is required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		content, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, "Failed to read file", http.StatusBadRequest)
+			return
+		}
+
+		fmt.Sscan(r.FormValue("knowledge_base_id"), &docReq.KnowledgeBaseId)
+		docReq.Name = r.FormValue("name")
+		if docReq.Name == "" {
+			docReq.Name = header.Filename
+		}
+		docReq.ContentType = r.FormValue("content_type")
+		if docReq.ContentType == "" {
+			docReq.ContentType = contentTypeFromFilename(header.Filename)
+		}
+		docReq.Content = string(content)
+	} else if err := json.NewDecoder(r.Body).Decode(&docReq); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if docReq.ContentType == "" {
+		docReq.ContentType = knowledge.ContentTypeText
+	}
+	if !knowledge.ValidContentType(docReq.ContentType) {
+		http.Error(w, "Invalid request payload, content_type must be text, markdown or pdf (extracted text)", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(docReq.Content) == "" {
+		http.Error(w, "Invalid request payload, content is required", http.StatusBadRequest)
+		return
+	}
+	if strings.HasPrefix(docReq.Content, "%PDF-") || !utf8.ValidString(docReq.Content) {
+		http.Error(w, "Invalid request payload, content must be text, extract the text from PDFs before uploading", http.StatusBadRequest)
+		return
+	}
+
+	// Validate that the knowledge base belongs to the user
+	var kb models.KnowledgeBase
+	result := a.DB.Where("id = ? AND user_id = ?", docReq.KnowledgeBaseId, user.ID).First(&kb)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			http.Error(w, "Knowledge base not found", http.StatusNotFound)
+		} else {
+			logger.S.Error(result.Error)
+			http.Error(w, "Failed to retrieve knowledge base", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	document := &models.Document{
+		UserId:          user.ID,
+		KnowledgeBaseId: kb.ID,
+		Name:            docReq.Name,
+		ContentType:     docReq.ContentType,
+	}
+	if err := a.DB.Create(document).Error; err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to create document", http.StatusInternalServerError)
+		return
+	}
+
+	openaiClient := openai.NewClientWithConfig(openai.DefaultConfig(a.Cfg.OpenAIAPIKey))
+	if err := knowledge.Ingest(r.Context(), a.DB, openaiClient, document, docReq.Content); err != nil {
+		logger.S.Errorf("error ingesting document %d: %v", document.ID, err)
+		a.DB.Delete(document)
+		http.Error(w, "Failed to process document", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(document)
+}
+
+func (a *API) DeleteDocument(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	docID := r.URL.Query().Get("id")
+	if docID == "" {
+		http.Error(w, "Document ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var document models.Document
+	result := a.DB.Where("id = ? AND user_id = ?", docID, user.ID).First(&document)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			http.Error(w, "Document not found", http.StatusNotFound)
+		} else {
+			logger.S.Error(result.Error)
+			http.Error(w, "Failed to retrieve document", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", document.ID).Delete(&models.DocumentChunk{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&document).Error
+	})
+	if err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to delete document", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func contentTypeFromFilename(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".md", ".markdown":
+		return knowledge.ContentTypeMarkdown
+	case ".pdf":
+		return knowledge.ContentTypePDF
+	default:
+		return knowledge.ContentTypeText
+	}
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (a *API) GetScheduledCall(w http.ResponseWriter, r *http.Request) {
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	scheduledID := r.URL.Query().Get("id")
+	if scheduledID == "" {
+		http.Error(w, "Scheduled call ID is required", http.StatusBadRequest)
+		return
+	}
+
+	var scheduled models.ScheduledCall
+	result := a.DB.Where("id = ? AND user_id = ?", scheduledID, user.ID).First(&scheduled)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			http.Error(w, "Scheduled call not found", http.StatusNotFound)
+		} else {
+			logger.S.Error(result.Error)
+			http.Error(w, "Failed to retrieve scheduled call", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	json.NewEncoder(w).Encode(scheduled)
+}
+
+func (a *API) ListScheduledCalls(w http.ResponseWriter, r *http.Request) {
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	queryParams := r.URL.Query()
+	cursor := queryParams.Get("cursor")
+	limit := queryParams.Get("limit")
+	if limit == "" {
+		limit = "10"
+	}
+
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil {
+		http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+		return
+	}
+
+	query := a.DB.Model(&models.ScheduledCall{}).
+		Where("user_id = ?", user.ID).
+		Order("scheduled_at").
+		Limit(limitInt + 1)
+
+	if cursor != "" {
+		cursorTime, err := parseCursor(cursor)
+		if err != nil {
+			http.Error(w, "Invalid cursor parameter", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("scheduled_at > ?", cursorTime)
+	}
+	if status := queryParams.Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if agentID := queryParams.Get("agent_id"); agentID != "" {
+		query = query.Where("agent_id = ?", agentID)
+	}
+	if source := queryParams.Get("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+
+	var scheduledCalls []models.ScheduledCall
+	result := query.Find(&scheduledCalls)
+	if result.Error != nil {
+		logger.S.Error(result.Error)
+		http.Error(w, "Failed to retrieve scheduled calls", http.StatusInternalServerError)
+		return
+	}
+
+	hasMore := len(scheduledCalls) > limitInt
+	if hasMore {
+		scheduledCalls = scheduledCalls[:limitInt]
+	}
+
+	response := struct {
+		NumItems       int                    `json:"num_items"`
+		Cursor         string                 `json:"cursor,omitempty"`
+		ScheduledCalls []models.ScheduledCall `json:"scheduled_calls"`
+	}{
+		NumItems:       len(scheduledCalls),
+		ScheduledCalls: scheduledCalls,
+	}
+
+	if hasMore {
+		response.Cursor = scheduledCalls[len(scheduledCalls)-1].ScheduledAt.Format(time.RFC3339Nano)
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// CancelScheduledCall cancels a call that hasn't been placed yet
+func (a *API) CancelScheduledCall(w http.ResponseWriter, r *http.Request) {
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	scheduledID := r.URL.Query().Get("id")
+	if scheduledID == "" {
+		http.Error(w, "Scheduled call ID is required", http.StatusBadRequest)
+		return
+	}
+
+	// Only calls that are still scheduled can be cancelled, the job placing the call skips cancelled calls
+	result := a.DB.Model(&models.ScheduledCall{}).
+		Where("id = ? AND user_id = ? AND status = ?", scheduledID, user.ID, models.ScheduledCallStatusScheduled).
+		Update("status", models.ScheduledCallStatusCancelled)
+	if result.Error != nil {
+		logger.S.Error(result.Error)
+		http.Error(w, "Failed to cancel scheduled call", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "Scheduled call not found or already placed", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Scheduled call cancelled successfully"})
+}
@@ -3,6 +3,8 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/flyflow-devs/flyflow/internal/compliance"
+	"github.com/flyflow-devs/flyflow/internal/logger"
 	"github.com/flyflow-devs/flyflow/internal/models"
 	"github.com/flyflow-devs/flyflow/internal/slack"
 	"net/http"
@@ -99,4 +101,34 @@ func (a *API) SetPlan(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(userResponse)
-}
\ No newline at end of file
+}
+// SetCallingHours sets the hours outbound calls are allowed in the recipient's timezone
+func (a *API) SetCallingHours(w http.ResponseWriter, r *http.Request) {
+	// Validate the API key
+	user, err := a.ValidateAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse the request body
+	var callingHours models.CallingWindow
+	if err := json.NewDecoder(r.Body).Decode(&callingHours); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if err := callingHours.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request payload, %v", err), http.StatusBadRequest)
+		return
+	}
+
+	user.CallingHours = callingHours
+	if err := a.DB.Model(user).Select("calling_hours").Updates(user).Error; err != nil {
+		logger.S.Error(err)
+		http.Error(w, "Failed to update calling hours", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(compliance.CallingHours(user))
+}
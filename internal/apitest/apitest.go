@@ -0,0 +1,186 @@
+// Package apitest runs the API against a throwaway SQLite database for hermetic tests, or a throwaway Postgres
+// database for the tests built with the postgres tag
+package apitest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/migrations"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/server"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Harness is an API server backed by a migrated database
+type Harness struct {
+	URL    string
+	DB     *gorm.DB
+	Cfg    *config.Config
+	Server *server.Server
+}
+
+// Config returns a configuration for tests, vendor keys are placeholders so nothing reaches the vendors
+func Config(t testing.TB) *config.Config {
+	return &config.Config{
+		Port:                   "0",
+		DBDriver:               config.DBDriverSQLite,
+		DBPath:                 filepath.Join(t.TempDir(), "flyflow.db"),
+		Env:                    "test",
+		JWTSecret:              "test-secret",
+		TwilioAccountAuthToken: "test-auth-token",
+		OpenAIAPIKey:           "<placeholder>",
+		AnalysisModel:          "gpt-4o",
+		InstanceId:             "test",
+	}
+}
+
+// New starts the API on a fresh database, it's shut down when the test ends
+func New(t testing.TB) *Harness {
+	t.Helper()
+	return NewWithConfig(t, Config(t))
+}
+
+// NewWithConfig starts the API on the database cfg points at, such as one from Postgres
+func NewWithConfig(t testing.TB, cfg *config.Config) *Harness {
+	t.Helper()
+	if logger.S == nil {
+		logger.InitLogger("test")
+	}
+
+	db, err := server.InitDB(cfg)
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("error loading migrations: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+
+	s := server.NewServer(cfg, db)
+	ts := httptest.NewServer(s.Router)
+	t.Cleanup(func() {
+		ts.Close()
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	return &Harness{URL: ts.URL, DB: db, Cfg: cfg, Server: s}
+}
+
+// CreateUser adds a user with an API key and returns them with the key
+func (h *Harness) CreateUser(t testing.TB, email string) (*models.User, string) {
+	t.Helper()
+	user := models.User{Email: email}
+	if err := h.DB.Create(&user).Error; err != nil {
+		t.Fatalf("error creating user: %v", err)
+	}
+	apiKey := models.APIKey{UserId: user.ID, Name: "test", Key: uuid.NewString()}
+	if err := h.DB.Create(&apiKey).Error; err != nil {
+		t.Fatalf("error creating API key: %v", err)
+	}
+	return &user, apiKey.Key
+}
+
+// Do sends a request authenticated with the API key, body is encoded as JSON unless it's nil
+func (h *Harness) Do(t testing.TB, method string, path string, apiKey string, body interface{}) *http.Response {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("error encoding request: %v", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, h.URL+path, reader)
+	if err != nil {
+		t.Fatalf("error building request: %v", err)
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error sending %s %s: %v", method, path, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// DoTwilio posts the form to path like a Twilio callback with the X-Twilio-Signature header, unless it's empty
+func (h *Harness) DoTwilio(t testing.TB, path string, form url.Values, signature string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, h.URL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatalf("error building request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if signature != "" {
+		req.Header.Set("X-Twilio-Signature", signature)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error sending POST %s: %v", path, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// SignTwilio signs a callback to path with the test auth token
+func (h *Harness) SignTwilio(path string, form url.Values) string {
+	return TwilioSignature(h.Cfg.TwilioAccountAuthToken, h.URL+path, form)
+}
+
+// TwilioSignature signs a callback the way Twilio does, the URL followed by the sorted form parameters
+func TwilioSignature(authToken string, url string, form url.Values) string {
+	keys := make([]string, 0, len(form))
+	for key := range form {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(url))
+	for _, key := range keys {
+		mac.Write([]byte(key + form.Get(key)))
+	}
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Decode checks the response has the status code and decodes its JSON body into v
+func Decode(t testing.TB, resp *http.Response, status int, v interface{}) {
+	t.Helper()
+	if resp.StatusCode != status {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("%s %s returned %d, want %d: %s", resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, status, body)
+	}
+	if v == nil {
+		return
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("error decoding response: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package apitest
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/server"
+	"github.com/google/uuid"
+)
+
+// PostgresConfig creates a throwaway database on the Postgres server DB_HOST, DB_PORT, DB_USER and DB_PASS point at,
+// the docker-compose one by default, and returns a test configuration using it. The database is dropped when the
+// test ends.
+func PostgresConfig(t testing.TB) *config.Config {
+	t.Helper()
+	cfg := Config(t)
+	cfg.DBDriver = config.DBDriverPostgres
+	cfg.DBHost = envOr("DB_HOST", "localhost")
+	cfg.DBPort = envOr("DB_PORT", "5432")
+	cfg.DBUser = envOr("DB_USER", "postgres")
+	cfg.DBPass = envOr("DB_PASS", "password")
+	cfg.DBName = "postgres"
+
+	admin, err := server.InitDB(cfg)
+	if err != nil {
+		t.Fatalf("error connecting to postgres: %v", err)
+	}
+	name := "flyflow_test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if err := admin.Exec(fmt.Sprintf(`CREATE DATABASE "%s"`, name)).Error; err != nil {
+		t.Fatalf("error creating database: %v", err)
+	}
+	// Cleanups run last first, so this runs after the test has closed its connections
+	t.Cleanup(func() {
+		if err := admin.Exec(fmt.Sprintf(`DROP DATABASE IF EXISTS "%s"`, name)).Error; err != nil {
+			t.Errorf("error dropping database %s: %v", name, err)
+		}
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	cfg.DBName = name
+	return cfg
+}
+
+func envOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,390 @@
+package campaigns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/compliance"
+	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/jobs"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/outbound"
+	"github.com/flyflow-devs/flyflow/internal/prompts"
+	"gorm.io/gorm"
+)
+
+const DialerJobType = "campaign_dialer"
+
+const (
+	// The dialer wakes up this often to fill free call slots
+	dialInterval = 15 * time.Second
+
+	// Contacts still marked as calling after this long never got a status callback and count as failed
+	callingTimeout = 2 * time.Hour
+
+	defaultRetryDelay = 60 * time.Minute
+)
+
+type dialerPayload struct {
+	CampaignId uint `json:"campaign_id"`
+}
+
+// Start starts or resumes dialing a campaign's contacts
+func Start(db *gorm.DB, campaign *models.Campaign) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		job, err := jobs.Enqueue(tx, DialerJobType, dialerPayload{CampaignId: campaign.ID}, jobs.ForUser(campaign.UserId))
+		if err != nil {
+			return err
+		}
+
+		campaign.Status = models.CampaignStatusRunning
+		campaign.DialerJobId = job.ID
+		return tx.Model(campaign).Select("status", "dialer_job_id").Updates(campaign).Error
+	})
+}
+
+// Pause stops placing new calls, calls in progress are left to finish
+func Pause(db *gorm.DB, campaign *models.Campaign) error {
+	campaign.Status = models.CampaignStatusPaused
+	return db.Model(campaign).Update("status", campaign.Status).Error
+}
+
+// Cancel stops the campaign for good and cancels every contact that hasn't been called yet
+func Cancel(db *gorm.DB, campaign *models.Campaign) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		campaign.Status = models.CampaignStatusCancelled
+		if err := tx.Model(campaign).Update("status", campaign.Status).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.CampaignContact{}).
+			Where("campaign_id = ? AND status = ?", campaign.ID, models.ContactStatusPending).
+			Update("status", models.ContactStatusCancelled).Error
+	})
+}
+
+// Progress counts the campaign's contacts by status and by the outcome of their latest call
+func Progress(db *gorm.DB, campaignId uint) (map[string]int64, map[string]int64, error) {
+	type count struct {
+		Key   string
+		Count int64
+	}
+
+	statuses := map[string]int64{}
+	var byStatus []count
+	err := db.Model(&models.CampaignContact{}).
+		Select("status AS key, COUNT(*) AS count").
+		Where("campaign_id = ?", campaignId).
+		Group("status").
+		Scan(&byStatus).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, c := range byStatus {
+		statuses[c.Key] = c.Count
+	}
+
+	outcomes := map[string]int64{}
+	var byOutcome []count
+	err = db.Model(&models.CampaignContact{}).
+		Select("outcome AS key, COUNT(*) AS count").
+		Where("campaign_id = ? AND outcome <> ''", campaignId).
+		Group("outcome").
+		Scan(&byOutcome).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, c := range byOutcome {
+		outcomes[c.Key] = c.Count
+	}
+
+	return statuses, outcomes, nil
+}
+
+// Outcome maps Twilio's final call status and answering machine detection result to a campaign outcome
+func Outcome(status string, answeredBy string) string {
+	switch status {
+	case "completed":
+		if strings.HasPrefix(answeredBy, "machine") || answeredBy == "fax" {
+			return models.OutcomeVoicemail
+		}
+		return models.OutcomeAnswered
+	case "busy":
+		return models.OutcomeBusy
+	case "no-answer":
+		return models.OutcomeNoAnswer
+	default:
+		return models.OutcomeFailed
+	}
+}
+
+// RecordOutcome updates the campaign contact a finished call was placed for
+func RecordOutcome(db *gorm.DB, call *models.Call) error {
+	if call.CampaignContactId == 0 {
+		return nil
+	}
+
+	var contact models.CampaignContact
+	if err := db.First(&contact, call.CampaignContactId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	// Twilio may deliver the callback more than once
+	if contact.Status != models.ContactStatusCalling || contact.LastCallId != call.ID {
+		return nil
+	}
+
+	var campaign models.Campaign
+	if err := db.First(&campaign, contact.CampaignId).Error; err != nil {
+		return err
+	}
+
+	return finish(db, &campaign, &contact, Outcome(call.Status, call.AnsweredBy), "")
+}
+
+// finish records the outcome of a contact's call and either schedules a retry or closes the contact
+func finish(db *gorm.DB, campaign *models.Campaign, contact *models.CampaignContact, outcome string, lastError string) error {
+	contact.Outcome = outcome
+	contact.LastError = lastError
+
+	switch {
+	case outcome == models.OutcomeAnswered:
+		contact.Status = models.ContactStatusCompleted
+	case campaign.Status != models.CampaignStatusCancelled && campaign.Retry.ShouldRetry(outcome, contact.Attempts):
+		delay := defaultRetryDelay
+		if campaign.Retry.DelayMinutes > 0 {
+			delay = time.Duration(campaign.Retry.DelayMinutes) * time.Minute
+		}
+		contact.Status = models.ContactStatusPending
+		contact.NextAttemptAt = time.Now().Add(delay)
+	default:
+		contact.Status = models.ContactStatusFailed
+	}
+
+	return db.Save(contact).Error
+}
+
+// NewDialerJobHandler returns the job handler that places a campaign's calls. Each run fills the free call
+// slots and queues the next run until the campaign is paused, cancelled or out of contacts.
+func NewDialerJobHandler(cfg *config.Config, db *gorm.DB) jobs.Handler {
+	return func(ctx context.Context, job *models.Job) error {
+		var payload dialerPayload
+		if err := jobs.DecodePayload(job, &payload); err != nil {
+			return jobs.Permanent(err)
+		}
+
+		var campaign models.Campaign
+		if err := db.First(&campaign, payload.CampaignId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		// A newer dialer took over when the campaign was paused and resumed
+		if campaign.Status != models.CampaignStatusRunning || campaign.DialerJobId != job.ID {
+			return nil
+		}
+
+		var agent models.Agent
+		if err := db.First(&agent, campaign.AgentId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.S.Warnf("agent %d of campaign %d was deleted, pausing the campaign", campaign.AgentId, campaign.ID)
+				return Pause(db, &campaign)
+			}
+			return err
+		}
+
+		if err := expireStaleCalls(db, &campaign); err != nil {
+			logger.S.Errorf("error expiring stale calls of campaign %d: %v", campaign.ID, err)
+		}
+		if err := dial(cfg, db, &campaign, &agent); err != nil {
+			logger.S.Errorf("error dialing campaign %d: %v", campaign.ID, err)
+		}
+
+		var remaining int64
+		err := db.Model(&models.CampaignContact{}).
+			Where("campaign_id = ? AND status IN ?", campaign.ID, []string{models.ContactStatusPending, models.ContactStatusCalling}).
+			Count(&remaining).Error
+		if err != nil {
+			return err
+		}
+		if remaining == 0 {
+			return db.Model(&campaign).Update("status", models.CampaignStatusCompleted).Error
+		}
+
+		next, err := jobs.Enqueue(db, DialerJobType, payload, jobs.RunAt(time.Now().Add(dialInterval)), jobs.ForUser(campaign.UserId))
+		if err != nil {
+			return err
+		}
+		return db.Model(&campaign).Update("dialer_job_id", next.ID).Error
+	}
+}
+
+// dial places calls to due contacts until the campaign's concurrency limit is reached
+func dial(cfg *config.Config, db *gorm.DB, campaign *models.Campaign, agent *models.Agent) error {
+	var inFlight int64
+	err := db.Model(&models.CampaignContact{}).
+		Where("campaign_id = ? AND status = ?", campaign.ID, models.ContactStatusCalling).
+		Count(&inFlight).Error
+	if err != nil {
+		return err
+	}
+
+	slots := campaign.MaxConcurrentCalls - int(inFlight)
+	if slots <= 0 {
+		return nil
+	}
+
+	// Fetch extra contacts since some may be outside of their calling window
+	now := time.Now()
+	var contacts []models.CampaignContact
+	err = db.Where("campaign_id = ? AND status = ? AND next_attempt_at <= ?", campaign.ID, models.ContactStatusPending, now).
+		Order("next_attempt_at").
+		Limit(slots * 4).
+		Find(&contacts).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range contacts {
+		if slots == 0 {
+			break
+		}
+		contact := &contacts[i]
+
+		if opens := campaign.CallingWindow.NextOpen(now, location(campaign, contact)); opens.After(now) {
+			if err := db.Model(contact).Update("next_attempt_at", opens).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		req := outbound.Request{
+			To:                contact.PhoneNumber,
+			Variables:         contact.Variables,
+			Timezone:          contact.Timezone,
+			MachineDetection:  campaign.Retry.ShouldRetry(models.OutcomeVoicemail, 0),
+			CampaignId:        campaign.ID,
+			CampaignContactId: contact.ID,
+		}
+		if req.Timezone == "" {
+			req.Timezone = campaign.Timezone
+		}
+
+		if err := outbound.CheckCompliance(db, agent, req); err != nil {
+			var hours *compliance.CallingHoursError
+			var dnc *compliance.DoNotCallError
+			switch {
+			case errors.As(err, &hours):
+				if err := db.Model(contact).Update("next_attempt_at", hours.NextAllowed).Error; err != nil {
+					return err
+				}
+			case errors.As(err, &dnc):
+				contact.Status = models.ContactStatusFailed
+				contact.Outcome = models.OutcomeDoNotCall
+				contact.LastError = err.Error()
+				if err := db.Save(contact).Error; err != nil {
+					return err
+				}
+			default:
+				return err
+			}
+			continue
+		}
+
+		contact.Status = models.ContactStatusCalling
+		contact.Attempts++
+		if err := db.Save(contact).Error; err != nil {
+			return err
+		}
+		slots--
+
+		call, err := outbound.Place(cfg, db, agent, req)
+		if call == nil {
+			var missing *prompts.MissingVariablesError
+			if errors.As(err, &missing) {
+				// Retrying won't fill in the missing variables
+				contact.Attempts = campaign.Retry.MaxAttempts
+			}
+			if err := finish(db, campaign, contact, models.OutcomeFailed, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			logger.S.Errorf("call %s for campaign contact %d was placed but not saved: %v", call.Sid, contact.ID, err)
+		}
+
+		contact.LastCallId = call.ID
+		contact.CallIds = append(contact.CallIds, call.ID)
+		if err := db.Save(contact).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expireStaleCalls closes contacts whose call never reported a final status
+func expireStaleCalls(db *gorm.DB, campaign *models.Campaign) error {
+	var stale []models.CampaignContact
+	err := db.Where("campaign_id = ? AND status = ? AND updated_at < ?", campaign.ID, models.ContactStatusCalling, time.Now().Add(-callingTimeout)).
+		Find(&stale).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range stale {
+		if err := finish(db, campaign, &stale[i], models.OutcomeFailed, "no final call status was received"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// location returns the timezone the contact's calling window is evaluated in
+func location(campaign *models.Campaign, contact *models.CampaignContact) *time.Location {
+	for _, name := range []string{contact.Timezone, campaign.Timezone} {
+		if name == "" {
+			continue
+		}
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// Validate checks a campaign's settings
+func Validate(campaign *models.Campaign) error {
+	if campaign.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if campaign.MaxConcurrentCalls < 1 {
+		return fmt.Errorf("max_concurrent_calls must be at least 1")
+	}
+	if _, err := time.LoadLocation(campaign.Timezone); err != nil {
+		return fmt.Errorf("timezone must be a valid IANA timezone such as America/New_York")
+	}
+	if err := campaign.CallingWindow.Validate(); err != nil {
+		return err
+	}
+	if campaign.Retry.MaxAttempts < 0 || campaign.Retry.DelayMinutes < 0 {
+		return fmt.Errorf("retry max_attempts and delay_minutes can't be negative")
+	}
+	for _, outcome := range campaign.Retry.RetryOn {
+		switch outcome {
+		case models.OutcomeNoAnswer, models.OutcomeBusy, models.OutcomeVoicemail, models.OutcomeFailed:
+		default:
+			return fmt.Errorf("retry_on must only contain no_answer, busy, voicemail or failed")
+		}
+	}
+	return nil
+}
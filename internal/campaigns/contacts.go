@@ -0,0 +1,107 @@
+package campaigns
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/compliance"
+	"github.com/flyflow-devs/flyflow/internal/models"
+)
+
+// Contact is an uploaded campaign recipient
+type Contact struct {
+	PhoneNumber string            `json:"phone_number"`
+	Timezone    string            `json:"timezone,omitempty"`
+	Variables   map[string]string `json:"variables,omitempty"`
+}
+
+// ParseCSV reads contacts from a CSV with a header row. The phone_number column is required and the
+// optional timezone column sets the contact's timezone, every other column becomes a prompt variable.
+func ParseCSV(r io.Reader) ([]Contact, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("csv is empty")
+		}
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	phoneColumn := -1
+	for i, name := range header {
+		if name == "phone_number" {
+			phoneColumn = i
+		}
+	}
+	if phoneColumn == -1 {
+		return nil, fmt.Errorf("csv must have a phone_number column")
+	}
+
+	var contacts []Contact
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		contact := Contact{Variables: map[string]string{}}
+		for i, value := range record {
+			switch header[i] {
+			case "phone_number":
+				contact.PhoneNumber = strings.TrimSpace(value)
+			case "timezone":
+				contact.Timezone = strings.TrimSpace(value)
+			default:
+				contact.Variables[header[i]] = value
+			}
+		}
+		contacts = append(contacts, contact)
+	}
+
+	return contacts, nil
+}
+
+// ValidateContacts checks every contact, errors mention the position of the offending contact
+func ValidateContacts(contacts []Contact) error {
+	for i, contact := range contacts {
+		if !compliance.ValidPhoneNumber(contact.PhoneNumber) {
+			return fmt.Errorf("contact %d: phone_number %q must be in E.164 format, e.g. +14155550100", i+1, contact.PhoneNumber)
+		}
+		if contact.Timezone != "" {
+			if _, err := time.LoadLocation(contact.Timezone); err != nil {
+				return fmt.Errorf("contact %d: timezone %q is not a valid IANA timezone", i+1, contact.Timezone)
+			}
+		}
+	}
+	return nil
+}
+
+// NewContacts builds the pending contact records for a campaign
+func NewContacts(campaign *models.Campaign, contacts []Contact) []models.CampaignContact {
+	records := make([]models.CampaignContact, 0, len(contacts))
+	now := time.Now()
+	for _, contact := range contacts {
+		records = append(records, models.CampaignContact{
+			CampaignId:    campaign.ID,
+			PhoneNumber:   contact.PhoneNumber,
+			Timezone:      contact.Timezone,
+			Variables:     contact.Variables,
+			Status:        models.ContactStatusPending,
+			NextAttemptAt: now,
+			CallIds:       []uint{},
+		})
+	}
+	return records
+}
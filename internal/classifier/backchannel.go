@@ -0,0 +1,99 @@
+package classifier
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	tokens "github.com/n3integration/classifier"
+	"github.com/n3integration/classifier/naive"
+)
+
+const (
+	labelBackchannel  = "backchannel"
+	labelInterruption = "interruption"
+
+	// MaxBackchannelDuration is the longest an utterance can be and still be a backchannel
+	MaxBackchannelDuration = 1500 * time.Millisecond
+
+	// Backchannels are a few words at most
+	maxBackchannelWords = 4
+
+	// The classifier has to be at least this sure before an utterance is treated as a backchannel
+	minBackchannelProbability = 0.7
+)
+
+// backchannelWords are acknowledgements that never interrupt on their own
+var backchannelWords = map[string]bool{
+	"uh-huh": true, "uh": true, "huh": true, "mm-hmm": true, "mhm": true, "mm": true, "hmm": true, "um": true,
+	"yeah": true, "yep": true, "yes": true, "yup": true, "ok": true, "okay": true, "right": true, "sure": true,
+	"alright": true, "cool": true, "gotcha": true, "got": true, "it": true, "i": true, "see": true, "oh": true,
+	"ah": true, "nice": true, "great": true, "true": true, "exactly": true, "totally": true, "definitely": true,
+}
+
+// backchannelExamples seed the classifier for utterances the word list doesn't settle
+var backchannelExamples = map[string][]string{
+	labelBackchannel: {
+		"uh huh", "mm hmm", "yeah yeah", "okay okay", "right right", "i see", "got it", "makes sense",
+		"that makes sense", "oh okay", "oh i see", "sounds good", "for sure", "of course", "no problem",
+		"yeah that's right", "mhm yeah", "okay great", "oh wow", "oh nice", "perfect", "understood",
+		"yes exactly", "all right", "okay sure", "yeah totally", "i hear you", "go on", "keep going",
+	},
+	labelInterruption: {
+		"wait", "hold on", "stop", "sorry what", "no no", "no that's wrong", "actually", "excuse me",
+		"can you repeat that", "what did you say", "that's not what i asked", "hang on a second",
+		"i have a question", "sorry to interrupt", "no i said tuesday", "can i speak to someone",
+		"i don't understand", "what do you mean", "let me stop you there", "that's not right",
+		"how much is it", "when is it", "i need to cancel", "can you transfer me", "one second",
+		"no thanks", "i already did that", "just a moment", "sorry can you say that again",
+	},
+}
+
+func trainBackchannelClassifier() *naive.Classifier {
+	// Short words like "uh" and "no" matter here, so nothing is filtered out as a stop word
+	classifier := naive.New(naive.Tokenizer(tokens.NewTokenizer(
+		tokens.SplitFunc(tokens.ScanAlphaWords),
+		tokens.Filters(),
+	)))
+
+	for label, examples := range backchannelExamples {
+		for _, example := range examples {
+			_ = classifier.TrainString(example, label)
+		}
+	}
+	return classifier
+}
+
+// IsBackchannel reports whether the caller's utterance is an acknowledgement like "mm-hmm" that shouldn't cut
+// the agent off, based on its length, the backchannel word list and the trained classifier
+func (c *Classifier) IsBackchannel(text string, duration time.Duration) bool {
+	if duration > MaxBackchannelDuration {
+		return false
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '-' && r != '\''
+	})
+	if len(words) == 0 || len(words) > maxBackchannelWords {
+		return false
+	}
+
+	onlyBackchannelWords := true
+	for _, word := range words {
+		if !backchannelWords[word] {
+			onlyBackchannelWords = false
+			break
+		}
+	}
+	if onlyBackchannelWords {
+		return true
+	}
+
+	// Probabilities aren't normalized across the labels
+	probabilities, _ := c.backchannel.Probabilities(strings.Join(words, " "))
+	total := probabilities[labelBackchannel] + probabilities[labelInterruption]
+	if total == 0 {
+		return false
+	}
+	return probabilities[labelBackchannel]/total >= minBackchannelProbability
+}
@@ -3,10 +3,12 @@ package classifier
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"github.com/flyflow-devs/flyflow/internal/logger"
 	"github.com/n3integration/classifier/knn"
 	"github.com/n3integration/classifier/naive"
 	"log"
+	"io/fs"
 	"os"
 	"strings"
 )
@@ -14,6 +16,8 @@ import (
 type Classifier struct {
 	classifier *naive.Classifier
 	classifier2 *knn.Classifier
+	backchannel *naive.Classifier
+	turns turnClassifier
 }
 
 
@@ -22,6 +26,8 @@ func NewClassifier() *Classifier {
 	return &Classifier{
 		classifier: n,
 		classifier2: k,
+		backchannel: trainBackchannelClassifier(),
+		turns: turnClassifier{classifier: trainTurnClassifier(nil)},
 	}
 }
 
@@ -71,6 +77,8 @@ func contains(word string, list []string) bool {
 }
 
 
+const fillerWordsPath = "internal/classifier/data/filler_words.jsonl"
+
 type TrainingData struct {
 	User     string `json:"user"`
 	Assistant string `json:"assistant"`
@@ -81,7 +89,12 @@ func trainClassifier() (*naive.Classifier, *knn.Classifier) {
 	classifier := naive.New()
 	classifier2 := knn.New()
 
-	file, err := os.Open("internal/classifier/data/filler_words.jsonl")
+	// Without the training data, e.g. in tests run outside the repo root, agents don't get filler words
+	file, err := os.Open(fillerWordsPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Printf("No filler word training data at %s, filler words are off", fillerWordsPath)
+		return classifier, classifier2
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,81 @@
+package classifier
+
+import (
+	"strings"
+	"sync"
+
+	tokens "github.com/n3integration/classifier"
+	"github.com/n3integration/classifier/naive"
+)
+
+const (
+	labelTurnComplete   = "complete"
+	labelTurnIncomplete = "incomplete"
+)
+
+// TurnExample is a caller's utterance labeled by whether they had finished their turn
+type TurnExample struct {
+	Text     string
+	Complete bool
+}
+
+// seedTurnExamples keep the endpointing classifier usable before any decisions have been logged
+var seedTurnExamples = []TurnExample{
+	{"yes", true}, {"no", true}, {"yeah that works", true}, {"that sounds good", true},
+	{"no thank you", true}, {"okay thanks bye", true}, {"my name is john smith", true},
+	{"it's the second one", true}, {"tuesday at three works for me", true}, {"can you help me with my order", true},
+	{"i'd like to cancel my appointment", true}, {"what are your hours", true}, {"how much does it cost", true},
+	{"that's all i needed", true}, {"sure go ahead", true}, {"i'm calling about my bill", true},
+	{"perfect thank you so much", true}, {"can you repeat that", true}, {"i don't think so", true},
+	{"so", false}, {"um", false}, {"and", false}, {"well i was thinking", false}, {"i want to", false},
+	{"my number is five five five", false}, {"the thing is", false}, {"i was wondering if", false},
+	{"so basically what happened was", false}, {"let me think", false}, {"hold on let me check", false},
+	{"i need", false}, {"it's about the", false}, {"yeah but", false}, {"because", false},
+	{"first of all", false}, {"i have a couple of questions the first one is", false}, {"my address is", false},
+	{"one sec", false}, {"so i guess", false}, {"and also", false},
+}
+
+// turnClassifier predicts whether the caller has finished their turn, it's retrained as decisions are labeled
+type turnClassifier struct {
+	sync.RWMutex
+	classifier *naive.Classifier
+}
+
+func trainTurnClassifier(examples []TurnExample) *naive.Classifier {
+	// Trailing words like "and" or "so" carry the signal, so nothing is filtered out as a stop word
+	classifier := naive.New(naive.Tokenizer(tokens.NewTokenizer(
+		tokens.SplitFunc(tokens.ScanAlphaWords),
+		tokens.Filters(),
+	)))
+
+	for _, example := range append(seedTurnExamples, examples...) {
+		label := labelTurnIncomplete
+		if example.Complete {
+			label = labelTurnComplete
+		}
+		_ = classifier.TrainString(strings.ToLower(example.Text), label)
+	}
+	return classifier
+}
+
+// TrainEndpointing retrains the endpointing classifier on the seed examples and the given labeled turns
+func (c *Classifier) TrainEndpointing(examples []TurnExample) {
+	classifier := trainTurnClassifier(examples)
+
+	c.turns.Lock()
+	defer c.turns.Unlock()
+	c.turns.classifier = classifier
+}
+
+// TurnCompleteProbability returns the probability from 0 to 100 that the caller has finished their turn
+func (c *Classifier) TurnCompleteProbability(text string) uint {
+	c.turns.RLock()
+	defer c.turns.RUnlock()
+
+	probabilities, _ := c.turns.classifier.Probabilities(strings.ToLower(text))
+	total := probabilities[labelTurnComplete] + probabilities[labelTurnIncomplete]
+	if total == 0 {
+		return 100
+	}
+	return uint(100 * probabilities[labelTurnComplete] / total)
+}
@@ -19,6 +19,23 @@ func NewCartesiaClient(cfg *config.Config) *CartesiaClient {
 }
 
 func (c *CartesiaClient) StreamSpeechBytes(modelID, transcript string, voiceID string, language string) (io.ReadCloser, error) {
+	return c.speechBytes(modelID, transcript, voiceID, language, map[string]interface{}{
+		"container":   "raw",
+		"encoding":    "pcm_mulaw",
+		"sample_rate": 8000,
+	})
+}
+
+// SpeechWAV returns the speech as a WAV file, for audio Twilio plays from a URL
+func (c *CartesiaClient) SpeechWAV(modelID, transcript string, voiceID string, language string) (io.ReadCloser, error) {
+	return c.speechBytes(modelID, transcript, voiceID, language, map[string]interface{}{
+		"container":   "wav",
+		"encoding":    "pcm_s16le",
+		"sample_rate": 16000,
+	})
+}
+
+func (c *CartesiaClient) speechBytes(modelID, transcript string, voiceID string, language string, outputFormat map[string]interface{}) (io.ReadCloser, error) {
 	reqBody := map[string]interface{}{
 		"model_id":   modelID,
 		"transcript": transcript,
@@ -26,12 +43,8 @@ func (c *CartesiaClient) StreamSpeechBytes(modelID, transcript string, voiceID s
 			"mode": "id",
 			"id":   voiceID,
 		},
-		"output_format": map[string]interface{}{
-			"container":   "raw",
-			"encoding":    "pcm_mulaw",
-			"sample_rate": 8000,
-		},
-		"language": language,
+		"output_format": outputFormat,
+		"language":      language,
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -0,0 +1,229 @@
+package compliance
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultCallingHours follows the TCPA's 8am to 9pm limit in the recipient's local time
+var DefaultCallingHours = models.CallingWindow{Start: "08:00", End: "21:00"}
+
+// Numbers in the North American Numbering Plan without a known timezone must be inside calling hours in
+// every continental US timezone
+var northAmericanLocations = []string{"America/New_York", "America/Chicago", "America/Denver", "America/Los_Angeles"}
+
+var phoneNumberPattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// Punctuation people format phone numbers with
+var phoneNumberFormatting = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "")
+
+// ValidPhoneNumber reports whether the number is in E.164 format
+func ValidPhoneNumber(number string) bool {
+	return phoneNumberPattern.MatchString(number)
+}
+
+// NormalizePhoneNumber returns the number in E.164 format with its formatting removed. Numbers without a
+// country code are read as North American when they have 10 digits, or 11 starting with 1. ok is false
+// when the number can't be normalized.
+func NormalizePhoneNumber(number string) (normalized string, ok bool) {
+	normalized = phoneNumberFormatting.Replace(strings.TrimSpace(number))
+	if !strings.HasPrefix(normalized, "+") {
+		switch {
+		case len(normalized) == 10:
+			normalized = "+1" + normalized
+		case len(normalized) == 11 && normalized[0] == '1':
+			normalized = "+" + normalized
+		}
+	}
+	if !ValidPhoneNumber(normalized) {
+		return "", false
+	}
+	return normalized, true
+}
+
+// DoNotCallError is returned when the recipient is on the user's do not call list
+type DoNotCallError struct {
+	PhoneNumber string
+}
+
+func (e *DoNotCallError) Error() string {
+	return fmt.Sprintf("%s is on the do not call list", e.PhoneNumber)
+}
+
+// CallingHoursError is returned when it's outside of calling hours for the recipient
+type CallingHoursError struct {
+	PhoneNumber string
+	NextAllowed time.Time
+}
+
+func (e *CallingHoursError) Error() string {
+	return fmt.Sprintf("%s can't be called outside of calling hours in the recipient's timezone, the next allowed time is %s",
+		e.PhoneNumber, e.NextAllowed.UTC().Format(time.RFC3339))
+}
+
+// Recipient is who an outbound call is going to
+type Recipient struct {
+	PhoneNumber string
+
+	// Timezone is the recipient's IANA timezone when known
+	Timezone string
+
+	// Fallback is used for numbers outside North America when the recipient's timezone isn't known
+	Fallback *time.Location
+}
+
+// Check returns a *DoNotCallError or *CallingHoursError when the user may not call the recipient at now
+func Check(db *gorm.DB, user *models.User, recipient Recipient, now time.Time) error {
+	if _, ok := NormalizePhoneNumber(recipient.PhoneNumber); !ok {
+		return fmt.Errorf("%q is not a valid phone number", recipient.PhoneNumber)
+	}
+
+	listed, err := IsOnDoNotCallList(db, user.ID, recipient.PhoneNumber)
+	if err != nil {
+		return err
+	}
+	if listed {
+		return &DoNotCallError{PhoneNumber: recipient.PhoneNumber}
+	}
+
+	if next := NextAllowed(CallingHours(user), recipient, now); next.After(now) {
+		return &CallingHoursError{PhoneNumber: recipient.PhoneNumber, NextAllowed: next}
+	}
+	return nil
+}
+
+// IsOnDoNotCallList reports whether the number is on the user's do not call list, in any formatting
+func IsOnDoNotCallList(db *gorm.DB, userId uint, phoneNumber string) (bool, error) {
+	if normalized, ok := NormalizePhoneNumber(phoneNumber); ok {
+		phoneNumber = normalized
+	}
+
+	var count int64
+	err := db.Model(&models.DoNotCallEntry{}).
+		Where("user_id = ? AND phone_number = ?", userId, phoneNumber).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// AddToDoNotCallList adds the entries, numbers that are already on the list are left as they are
+func AddToDoNotCallList(db *gorm.DB, entries []models.DoNotCallEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "phone_number"}},
+		DoNothing: true,
+	}).CreateInBatches(entries, 500).Error
+}
+
+// CallingHours returns the user's calling hours
+func CallingHours(user *models.User) models.CallingWindow {
+	if user.CallingHours.Start == "" && user.CallingHours.End == "" {
+		return DefaultCallingHours
+	}
+	return user.CallingHours
+}
+
+// NextAllowed returns now if calling hours allow calling the recipient, otherwise the next time they do
+func NextAllowed(window models.CallingWindow, recipient Recipient, now time.Time) time.Time {
+	locations := recipientLocations(recipient)
+
+	// Move forward until the window is open in every possible location of the recipient
+	t := now
+	for i := 0; i < 16; i++ {
+		moved := false
+		for _, loc := range locations {
+			if opens := window.NextOpen(t, loc); opens.After(t) {
+				t = opens
+				moved = true
+			}
+		}
+		if !moved {
+			return t
+		}
+	}
+	return t
+}
+
+func recipientLocations(recipient Recipient) []*time.Location {
+	if recipient.Timezone != "" {
+		if loc, err := time.LoadLocation(recipient.Timezone); err == nil {
+			return []*time.Location{loc}
+		}
+	}
+
+	if strings.HasPrefix(recipient.PhoneNumber, "+1") {
+		var locations []*time.Location
+		for _, name := range northAmericanLocations {
+			if loc, err := time.LoadLocation(name); err == nil {
+				locations = append(locations, loc)
+			}
+		}
+		return locations
+	}
+
+	if recipient.Fallback != nil {
+		return []*time.Location{recipient.Fallback}
+	}
+	return []*time.Location{time.UTC}
+}
+
+// ParseDoNotCallCSV reads do not call entries from a CSV with a header row, a phone_number column and an
+// optional reason column
+func ParseDoNotCallCSV(r io.Reader) ([]models.DoNotCallEntry, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("csv is empty")
+		}
+		return nil, err
+	}
+
+	phoneColumn, reasonColumn := -1, -1
+	for i, name := range header {
+		switch strings.TrimSpace(name) {
+		case "phone_number":
+			phoneColumn = i
+		case "reason":
+			reasonColumn = i
+		}
+	}
+	if phoneColumn == -1 {
+		return nil, fmt.Errorf("csv must have a phone_number column")
+	}
+
+	var entries []models.DoNotCallEntry
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		phoneNumber, ok := NormalizePhoneNumber(record[phoneColumn])
+		if !ok {
+			return nil, fmt.Errorf("line %d: phone_number %q must be in E.164 format, e.g. +14155550100", line, record[phoneColumn])
+		}
+		entry := models.DoNotCallEntry{PhoneNumber: phoneNumber}
+		if reasonColumn != -1 {
+			entry.Reason = record[reasonColumn]
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
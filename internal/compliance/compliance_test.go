@@ -0,0 +1,132 @@
+package compliance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/models"
+)
+
+func TestNextAllowed(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	weekdays := models.CallingWindow{Start: "09:00", End: "17:00", Days: []string{"mon", "tue", "wed", "thu", "fri"}}
+
+	tests := []struct {
+		name      string
+		window    models.CallingWindow
+		recipient Recipient
+		now       time.Time
+		want      time.Time
+	}{
+		{
+			// 08:00 in New York is 05:00 in Los Angeles
+			name:      "north american number without a timezone waits for the pacific coast",
+			window:    DefaultCallingHours,
+			recipient: Recipient{PhoneNumber: "+14155550100"},
+			now:       time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC),
+			want:      time.Date(2026, 10, 14, 15, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "north american number without a timezone after the east coast closes",
+			window:    DefaultCallingHours,
+			recipient: Recipient{PhoneNumber: "+14155550100"},
+			now:       time.Date(2026, 10, 15, 1, 30, 0, 0, time.UTC),
+			want:      time.Date(2026, 10, 15, 15, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "north american number inside every timezone's hours",
+			window:    DefaultCallingHours,
+			recipient: Recipient{PhoneNumber: "+14155550100"},
+			now:       time.Date(2026, 10, 14, 18, 0, 0, 0, time.UTC),
+			want:      time.Date(2026, 10, 14, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "explicit timezone is the only one checked",
+			window:    DefaultCallingHours,
+			recipient: Recipient{PhoneNumber: "+14155550100", Timezone: "America/New_York"},
+			now:       time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC),
+			want:      time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "invalid timezone falls back to every north american timezone",
+			window:    DefaultCallingHours,
+			recipient: Recipient{PhoneNumber: "+14155550100", Timezone: "America/Nowhere"},
+			now:       time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC),
+			want:      time.Date(2026, 10, 14, 15, 0, 0, 0, time.UTC),
+		},
+		{
+			// Los Angeles springs forward at 10:00 UTC, 08:00 PDT is 15:00 UTC
+			name:      "day the clocks go forward",
+			window:    DefaultCallingHours,
+			recipient: Recipient{PhoneNumber: "+14155550100"},
+			now:       time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC),
+			want:      time.Date(2026, 3, 8, 15, 0, 0, 0, time.UTC),
+		},
+		{
+			// 08:00 PST is 16:00 UTC once the clocks have gone back
+			name:      "day the clocks go back",
+			window:    DefaultCallingHours,
+			recipient: Recipient{PhoneNumber: "+14155550100"},
+			now:       time.Date(2026, 11, 1, 15, 30, 0, 0, time.UTC),
+			want:      time.Date(2026, 11, 1, 16, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "weekday window on a friday evening waits for monday",
+			window:    weekdays,
+			recipient: Recipient{PhoneNumber: "+14155550100"},
+			now:       time.Date(2026, 10, 16, 23, 0, 0, 0, time.UTC),
+			want:      time.Date(2026, 10, 19, 16, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "international number uses the fallback timezone",
+			window:    DefaultCallingHours,
+			recipient: Recipient{PhoneNumber: "+442071838750", Fallback: london},
+			now:       time.Date(2026, 10, 14, 6, 0, 0, 0, time.UTC),
+			want:      time.Date(2026, 10, 14, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "international number without a fallback uses UTC",
+			window:    DefaultCallingHours,
+			recipient: Recipient{PhoneNumber: "+442071838750"},
+			now:       time.Date(2026, 10, 14, 6, 0, 0, 0, time.UTC),
+			want:      time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NextAllowed(tt.window, tt.recipient, tt.now); !got.Equal(tt.want) {
+				t.Errorf("NextAllowed() = %s, want %s", got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	tests := []struct {
+		number string
+		want   string
+		ok     bool
+	}{
+		{number: "+15551234567", want: "+15551234567", ok: true},
+		{number: "15551234567", want: "+15551234567", ok: true},
+		{number: "5551234567", want: "+15551234567", ok: true},
+		{number: "+1 (555) 123-4567", want: "+15551234567", ok: true},
+		{number: " 555.123.4567 ", want: "+15551234567", ok: true},
+		{number: "+44 20 7183 8750", want: "+442071838750", ok: true},
+		{number: "442071838750", ok: false},
+		{number: "12345", ok: false},
+		{number: "+1555CALLNOW", ok: false},
+		{number: "", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			got, ok := NormalizePhoneNumber(tt.number)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("NormalizePhoneNumber(%q) = %q, %v, want %q, %v", tt.number, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -1,12 +1,24 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
+// Database drivers, named like the gorm dialects
+const (
+	DBDriverPostgres = "postgres"
+	DBDriverSQLite   = "sqlite"
+)
+
 type Config struct {
 	OpenAIAPIKey   string
 	Port           string
+
+	// DBDriver is postgres or sqlite, DBPath is the SQLite database file, :memory: for a throwaway database
+	DBDriver       string
+	DBPath         string
 	DBHost         string
 	DBPort         string
 	DBUser         string
@@ -21,6 +33,7 @@ type Config struct {
 	TwilioStreamingURL string
 	TwilioMLSid string
 	TwilioMLUrl string
+	TwilioStatusCallbackUrl string
 	FireworksAPIKey string
 	ForwardRedirectMLUrl string
 
@@ -28,6 +41,43 @@ type Config struct {
 
 	CartesiaAPIKey string
 	CartesiaVersion string
+
+	AnalysisModel string
+
+	WorkerConcurrency int
+	EmbeddedWorker bool
+
+	// RecordingArchiveDir is where workers archive recordings and the API serves them from. Unless the worker
+	// is embedded it has to be shared storage mounted at the same path on the API and worker hosts, the API
+	// falls back to Twilio for recordings it can't find.
+	RecordingArchiveDir string
+
+	// EndpointingRetentionDays is how long endpointing decisions are kept for training, 0 keeps them forever
+	EndpointingRetentionDays int
+
+	// APIBaseURL is the public URL of the API, used for links to recordings in webhooks
+	APIBaseURL string
+
+	// InstanceId and InstanceAddress identify this instance in the call ownership registry, they default to
+	// the hostname and http://<hostname>:<port>
+	InstanceId      string
+	InstanceAddress string
+
+	// InternalToken authenticates commands forwarded between instances, forwarding is off without one
+	InternalToken string
+
+	// DrainTimeout is how long shutdown waits for calls in progress to finish before ending them
+	DrainTimeout time.Duration
+
+	// MetricsEnabled serves Prometheus metrics on /metrics. The endpoint has no auth, so only turn it on where
+	// the port isn't reachable from outside or the proxy in front of it blocks /metrics.
+	MetricsEnabled bool
+
+	// TracingEndpoint is the OTLP/HTTP collector spans are exported to, e.g. localhost:4318, empty turns tracing off
+	TracingEndpoint    string
+	TracingInsecure    bool
+	TracingSampleRatio float64
+	ServiceName        string
 }
 
 func NewConfig() (*Config, error) {
@@ -43,6 +93,8 @@ func NewConfig() (*Config, error) {
 	// Set default values
 	viper.SetDefault("OPENAI_API_KEY", "<placeholder>")
 	viper.SetDefault("PORT", "<placeholder>")
+	viper.SetDefault("DB_DRIVER", DBDriverPostgres)
+	viper.SetDefault("DB_PATH", "flyflow.db")
 	viper.SetDefault("DB_HOST", "localhost")
 	viper.SetDefault("DB_PORT", "5432")
 	viper.SetDefault("DB_USER", "postgres")
@@ -57,16 +109,34 @@ func NewConfig() (*Config, error) {
 	viper.SetDefault("DEEPGRAM_API_KEY", "<placeholder>")
 	viper.SetDefault("TWILIO_ML_SID", "<placeholder>")
 	viper.SetDefault("TWILIO_ML_URL", "<placeholder>")
+	viper.SetDefault("TWILIO_STATUS_CALLBACK_URL", "")
 	viper.SetDefault("FIREWORKS_API_KEY", "<placeholder>")
 	viper.SetDefault("TWILIO_REDIRECT_ML_URL", "<placeholder>")
 	viper.SetDefault("STIPE_SECRET_KEY", "<placeholder>")
 	viper.SetDefault("CARTESIA_API_KEY", "<placeholder>")
 	viper.SetDefault("CARTESIA_VERSION", "<placeholder>")
+	viper.SetDefault("ANALYSIS_MODEL", "gpt-4o")
+	viper.SetDefault("WORKER_CONCURRENCY", 4)
+	viper.SetDefault("EMBEDDED_WORKER", true)
+	viper.SetDefault("RECORDING_ARCHIVE_DIR", "")
+	viper.SetDefault("ENDPOINTING_RETENTION_DAYS", 90)
+	viper.SetDefault("API_BASE_URL", "")
+	viper.SetDefault("INSTANCE_ID", "")
+	viper.SetDefault("INSTANCE_ADDRESS", "")
+	viper.SetDefault("INTERNAL_TOKEN", "")
+	viper.SetDefault("DRAIN_TIMEOUT", "10m")
+	viper.SetDefault("METRICS_ENABLED", false)
+	viper.SetDefault("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	viper.SetDefault("OTEL_EXPORTER_OTLP_INSECURE", false)
+	viper.SetDefault("OTEL_TRACES_SAMPLE_RATIO", 1.0)
+	viper.SetDefault("OTEL_SERVICE_NAME", "voice-api")
 
 	// Return the config
 	return &Config{
 		OpenAIAPIKey: viper.GetString("OPENAI_API_KEY"),
 		Port:         viper.GetString("PORT"),
+		DBDriver:     viper.GetString("DB_DRIVER"),
+		DBPath:       viper.GetString("DB_PATH"),
 		DBHost:       viper.GetString("DB_HOST"),
 		DBPort:       viper.GetString("DB_PORT"),
 		DBUser:       viper.GetString("DB_USER"),
@@ -81,10 +151,26 @@ func NewConfig() (*Config, error) {
 		TwilioStreamingURL: viper.GetString("TWILIO_STREAMING_URL"),
 		TwilioMLSid: viper.GetString("TWILIO_ML_SID"),
 		TwilioMLUrl: viper.GetString("TWILIO_ML_URL"),
+		TwilioStatusCallbackUrl: viper.GetString("TWILIO_STATUS_CALLBACK_URL"),
 		FireworksAPIKey: viper.GetString("FIREWORKS_API_KEY"),
 		ForwardRedirectMLUrl: viper.GetString("TWILIO_REDIRECT_ML_URL"),
 		StripeSecretKey: viper.GetString("STIPE_SECRET_KEY"),
 		CartesiaAPIKey: viper.GetString("CARTESIA_API_KEY"),
 		CartesiaVersion: viper.GetString("CARTESIA_VERSION"),
+		AnalysisModel: viper.GetString("ANALYSIS_MODEL"),
+		WorkerConcurrency: viper.GetInt("WORKER_CONCURRENCY"),
+		EmbeddedWorker: viper.GetBool("EMBEDDED_WORKER"),
+		RecordingArchiveDir: viper.GetString("RECORDING_ARCHIVE_DIR"),
+		EndpointingRetentionDays: viper.GetInt("ENDPOINTING_RETENTION_DAYS"),
+		APIBaseURL: viper.GetString("API_BASE_URL"),
+		InstanceId: viper.GetString("INSTANCE_ID"),
+		InstanceAddress: viper.GetString("INSTANCE_ADDRESS"),
+		InternalToken: viper.GetString("INTERNAL_TOKEN"),
+		DrainTimeout: viper.GetDuration("DRAIN_TIMEOUT"),
+		MetricsEnabled: viper.GetBool("METRICS_ENABLED"),
+		TracingEndpoint: viper.GetString("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		TracingInsecure: viper.GetBool("OTEL_EXPORTER_OTLP_INSECURE"),
+		TracingSampleRatio: viper.GetFloat64("OTEL_TRACES_SAMPLE_RATIO"),
+		ServiceName: viper.GetString("OTEL_SERVICE_NAME"),
 	}, err
 }
@@ -0,0 +1,143 @@
+package endpointing
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/flyflow-devs/flyflow/internal/classifier"
+	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/sashabaranov/go-openai"
+	"gorm.io/gorm"
+)
+
+// DefaultThreshold is the probability at or above which the agent responds right away
+const DefaultThreshold = 70
+
+// Timeout is how long an endpointer gets before the agent responds anyway
+const Timeout = 700 * time.Millisecond
+
+// MaxSilenceWindowMs is the longest silence window an agent can set
+const MaxSilenceWindowMs = 5000
+
+const (
+	// Each point of probability below the threshold adds this much wait
+	backoffPerPoint = 50 * time.Millisecond
+
+	// Longest the agent waits for the caller to keep talking
+	maxBackoff = 3 * time.Second
+
+	// How many logged decisions the classifier is trained on
+	trainingDecisions = 10000
+)
+
+// Words that leave a sentence hanging, the caller is likely to keep talking after them
+var trailingWords = map[string]bool{
+	"and": true, "but": true, "or": true, "so": true, "because": true, "um": true, "uh": true, "like": true,
+	"the": true, "a": true, "an": true, "to": true, "of": true, "my": true, "is": true, "if": true, "then": true,
+}
+
+// Turn is the conversation an endpointer decides on
+type Turn struct {
+	// Messages are the conversation so far without the system prompt
+	Messages []openai.ChatCompletionMessage
+
+	// Transcript is what the caller said since the agent last spoke
+	Transcript string
+}
+
+// Endpointer returns the probability from 0 to 100 that the caller has finished their turn
+type Endpointer interface {
+	Name() string
+	Probability(ctx context.Context, turn Turn) (uint, error)
+}
+
+// New returns the agent's endpointer, the LLM for unknown names
+func New(cfg *config.Config, c *classifier.Classifier, agent *models.Agent) Endpointer {
+	switch agent.Endpointer {
+	case models.EndpointerClassifier:
+		return NewClassifier(c)
+	case models.EndpointerSilence:
+		return Silence{Window: time.Duration(agent.SilenceWindowMs) * time.Millisecond}
+	default:
+		return NewLLM(cfg)
+	}
+}
+
+// Valid reports whether name is an endpointer agents can use
+func Valid(name string) bool {
+	switch name {
+	case "", models.EndpointerLLM, models.EndpointerClassifier, models.EndpointerSilence:
+		return true
+	}
+	return false
+}
+
+// Backoff returns how long to wait for the caller to keep talking before responding. Turns ending in a sentence
+// wait less and turns ending mid-sentence wait longer.
+func Backoff(threshold uint, probability uint, transcript string) time.Duration {
+	if probability >= threshold {
+		return 0
+	}
+	backoff := time.Duration(threshold-probability) * backoffPerPoint
+
+	transcript = strings.TrimSpace(transcript)
+	switch {
+	case strings.HasSuffix(transcript, "?"):
+		backoff /= 4
+	case strings.HasSuffix(transcript, "."), strings.HasSuffix(transcript, "!"):
+		backoff /= 2
+	case strings.HasSuffix(transcript, ","), strings.HasSuffix(transcript, "-"):
+		backoff *= 2
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(transcript), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	if len(words) > 0 && trailingWords[words[len(words)-1]] {
+		backoff *= 2
+	}
+
+	return min(backoff, maxBackoff)
+}
+
+// Wait returns how long to wait for the caller to keep talking, the silence window for the silence endpointer
+// and the backoff for the others
+func Wait(e Endpointer, threshold uint, probability uint, transcript string) time.Duration {
+	if silence, ok := e.(Silence); ok && silence.Window > 0 {
+		return silence.Window
+	}
+	return Backoff(threshold, probability, transcript)
+}
+
+// Train retrains the classifier endpointer on decisions whose outcome was seen. Turns the agent answered right
+// away aren't used since the caller never had the chance to keep talking.
+func Train(db *gorm.DB, c *classifier.Classifier) error {
+	var decisions []models.EndpointingDecision
+	err := db.Select("transcript", "continued").
+		Where("wait_ms > 0 AND failed = ?", false).
+		Order("id desc").
+		Limit(trainingDecisions).
+		Find(&decisions).Error
+	if err != nil {
+		return err
+	}
+
+	examples := make([]classifier.TurnExample, 0, len(decisions))
+	for _, decision := range decisions {
+		examples = append(examples, classifier.TurnExample{Text: decision.Transcript, Complete: !decision.Continued})
+	}
+	c.TrainEndpointing(examples)
+
+	logger.S.Infof("trained endpointing classifier on %d decisions", len(examples))
+	return nil
+}
+
+// Prune deletes the decisions logged before the retention period and returns how many it deleted
+func Prune(db *gorm.DB, retention time.Duration) (int64, error) {
+	result := db.Unscoped().Where("created_at < ?", time.Now().Add(-retention)).Delete(&models.EndpointingDecision{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,67 @@
+package endpointing_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/apitest"
+	"github.com/flyflow-devs/flyflow/internal/endpointing"
+	"github.com/flyflow-devs/flyflow/internal/models"
+)
+
+func TestSilenceWaitsForTheWindow(t *testing.T) {
+	tests := []struct {
+		name            string
+		window          time.Duration
+		wantProbability uint
+		wantWait        time.Duration
+	}{
+		{name: "no window", window: 0, wantProbability: 100, wantWait: 0},
+		{name: "window", window: 800 * time.Millisecond, wantProbability: 0, wantWait: 800 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			silence := endpointing.Silence{Window: tt.window}
+			probability, err := silence.Probability(context.Background(), endpointing.Turn{Transcript: "I'd like to book a table"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if probability != tt.wantProbability {
+				t.Errorf("got probability %d, want %d", probability, tt.wantProbability)
+			}
+			wait := endpointing.Wait(silence, endpointing.DefaultThreshold, probability, "I'd like to book a table")
+			if wait != tt.wantWait {
+				t.Errorf("got wait %v, want %v", wait, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestPruneDeletesOldDecisions(t *testing.T) {
+	h := apitest.New(t)
+	old := models.EndpointingDecision{Transcript: "old"}
+	old.CreatedAt = time.Now().AddDate(0, 0, -91)
+	recent := models.EndpointingDecision{Transcript: "recent"}
+	for _, decision := range []*models.EndpointingDecision{&old, &recent} {
+		if err := h.DB.Create(decision).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pruned, err := endpointing.Prune(h.DB, 90*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 1 {
+		t.Errorf("pruned %d decisions, want 1", pruned)
+	}
+
+	var left []models.EndpointingDecision
+	if err := h.DB.Unscoped().Find(&left).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 1 || left[0].Transcript != "recent" {
+		t.Errorf("got %+v left, want only the recent decision", left)
+	}
+}
@@ -1,19 +1,16 @@
-package streaming
+package endpointing
 
 import (
 	"context"
 	"encoding/json"
-	"github.com/flyflow-devs/flyflow/internal/logger"
+
+	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/telemetry"
 	"github.com/sashabaranov/go-openai"
-	"time"
 )
 
-type ProbabilityResponse struct {
-	Probability uint `json:"probability"`
-}
-
-func (c *CallOrchestrator) smartEndpointing(messages []openai.ChatCompletionMessage, probabilityChan chan uint) {
-	prompt := `You are being used in an interactive voice application that sometimes returns partial responses. Your job is to return the probability that the user has completed a full thought and now wants the agent to respond
+const prompt = `You are being used in an interactive voice application that sometimes returns partial responses. Your job is to return the probability that the user has completed a full thought and now wants the agent to respond
 
 The probability you assign will be used to calculate how long the agent waits before confirming the user has finished their thought and the agent can respond.
 
@@ -176,10 +173,25 @@ User: I'm in high school still.
 {"probability": 80}
 `
 
-	ctx, cancel := context.WithTimeout(context.Background(), 700*time.Millisecond)
-	defer cancel()
+type ProbabilityResponse struct {
+	Probability uint `json:"probability"`
+}
+
+// LLM asks a Fireworks hosted Llama whether the caller has finished their thought
+type LLM struct {
+	cfg *config.Config
+}
+
+func NewLLM(cfg *config.Config) *LLM {
+	return &LLM{cfg: cfg}
+}
+
+func (e *LLM) Name() string {
+	return models.EndpointerLLM
+}
 
-	openaiConfig := openai.DefaultConfig(c.cfg.FireworksAPIKey)
+func (e *LLM) Probability(ctx context.Context, turn Turn) (uint, error) {
+	openaiConfig := openai.DefaultConfig(e.cfg.FireworksAPIKey)
 	openaiConfig.BaseURL = "https://api.fireworks.ai/inference/v1"
 	openaiClient := openai.NewClientWithConfig(openaiConfig)
 
@@ -189,47 +201,27 @@ User: I'm in high school still.
 			Content: prompt,
 		},
 	}
-	fullMessages = append(fullMessages, messages...)
-
-	start := time.Now()
-
-	respChan := make(chan openai.ChatCompletionResponse)
-	errChan := make(chan error)
-
-	go func() {
-		resp, err := openaiClient.CreateChatCompletion(
-			ctx,
-			openai.ChatCompletionRequest{
-				Model:    "accounts/fireworks/models/llama-v3-70b-instruct",
-				Messages: fullMessages,
-				ResponseFormat: &openai.ChatCompletionResponseFormat{
-					Type: openai.ChatCompletionResponseFormatTypeJSONObject,
-				},
+	fullMessages = append(fullMessages, turn.Messages...)
+
+	ctx, end := telemetry.StartVendorCall(ctx, "fireworks", "endpointing")
+	resp, err := openaiClient.CreateChatCompletion(
+		ctx,
+		openai.ChatCompletionRequest{
+			Model:    "accounts/fireworks/models/llama-v3-70b-instruct",
+			Messages: fullMessages,
+			ResponseFormat: &openai.ChatCompletionResponseFormat{
+				Type: openai.ChatCompletionResponseFormatTypeJSONObject,
 			},
-		)
-		if err != nil {
-			errChan <- err
-			return
-		}
-		respChan <- resp
-	}()
-
-	select {
-	case <-ctx.Done():
-		logger.S.Warn("Request timed out after 700ms")
-		probabilityChan <- 100
-	case err := <-errChan:
-		logger.S.Error(err)
-		probabilityChan <- 100
-	case resp := <-respChan:
-		logger.S.Infof("time to generating endpointing %v", time.Since(start))
-
-		var probabilityResp ProbabilityResponse
-		err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &probabilityResp)
-		if err != nil {
-			probabilityChan <- 100
-		} else {
-			probabilityChan <- probabilityResp.Probability
-		}
+		},
+	)
+	end(err)
+	if err != nil {
+		return 0, err
+	}
+
+	var probabilityResp ProbabilityResponse
+	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &probabilityResp); err != nil {
+		return 0, err
 	}
-}
\ No newline at end of file
+	return min(probabilityResp.Probability, 100), nil
+}
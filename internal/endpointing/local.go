@@ -0,0 +1,43 @@
+package endpointing
+
+import (
+	"context"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/classifier"
+	"github.com/flyflow-devs/flyflow/internal/models"
+)
+
+// Classifier decides locally with a classifier trained on logged decisions, it only looks at the caller's words
+type Classifier struct {
+	classifier *classifier.Classifier
+}
+
+func NewClassifier(c *classifier.Classifier) *Classifier {
+	return &Classifier{classifier: c}
+}
+
+func (e *Classifier) Name() string {
+	return models.EndpointerClassifier
+}
+
+func (e *Classifier) Probability(ctx context.Context, turn Turn) (uint, error) {
+	return e.classifier.TurnCompleteProbability(turn.Transcript), nil
+}
+
+// Silence relies on the transcriber's endpointing alone. The caller is done once a final transcript arrives and
+// they stay quiet for Window, right away without a window.
+type Silence struct {
+	Window time.Duration
+}
+
+func (Silence) Name() string {
+	return models.EndpointerSilence
+}
+
+func (e Silence) Probability(ctx context.Context, turn Turn) (uint, error) {
+	if e.Window > 0 {
+		return 0, nil
+	}
+	return 100, nil
+}
@@ -0,0 +1,425 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultMaxAttempts = 5
+
+	// Retries back off exponentially from the base delay up to the max delay
+	baseRetryDelay = 30 * time.Second
+	maxRetryDelay  = 1 * time.Hour
+
+	// Jobs running for longer than this are assumed to belong to a worker that died and are requeued
+	lease = 15 * time.Minute
+
+	// Handlers are cancelled after this, well within the lease so a slow job is never run twice at once
+	handlerTimeout = 10 * time.Minute
+
+	recoveryInterval = 1 * time.Minute
+)
+
+// permanentError marks a failure that retrying won't fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent wraps an error so the job is marked failed without using its remaining attempts
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// Handler processes a job. Returning an error schedules a retry until the job runs out of attempts.
+// Handlers may update job.Payload, the new payload is stored with the retry.
+type Handler func(ctx context.Context, job *models.Job) error
+
+type enqueueOptions struct {
+	runAt       time.Time
+	maxAttempts int
+	userId      uint
+	callId      uint
+}
+
+type Option func(*enqueueOptions)
+
+// RunAt delays the job until the given time
+func RunAt(t time.Time) Option {
+	return func(o *enqueueOptions) {
+		o.runAt = t
+	}
+}
+
+// MaxAttempts overrides the number of times the job is tried before it's marked failed
+func MaxAttempts(n int) Option {
+	return func(o *enqueueOptions) {
+		o.maxAttempts = n
+	}
+}
+
+// ForUser associates the job with a user so it shows up in their job list
+func ForUser(userId uint) Option {
+	return func(o *enqueueOptions) {
+		o.userId = userId
+	}
+}
+
+// ForCall associates the job with a call
+func ForCall(callId uint) Option {
+	return func(o *enqueueOptions) {
+		o.callId = callId
+	}
+}
+
+// Enqueue persists a job to be picked up by a worker
+func Enqueue(db *gorm.DB, jobType string, payload interface{}, opts ...Option) (*models.Job, error) {
+	options := enqueueOptions{
+		runAt:       time.Now(),
+		maxAttempts: DefaultMaxAttempts,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	encoded, err := encodePayload(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &models.Job{
+		UserId:      options.userId,
+		CallId:      options.callId,
+		Type:        jobType,
+		Payload:     encoded,
+		Status:      models.JobStatusQueued,
+		MaxAttempts: options.maxAttempts,
+		RunAt:       options.runAt,
+	}
+	if err := db.Create(job).Error; err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// DecodePayload unmarshals the job's payload into v
+func DecodePayload(job *models.Job, v interface{}) error {
+	encoded, err := json.Marshal(job.Payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, v)
+}
+
+// SetPayload replaces the job's payload, typically so a retry only redoes the work that failed
+func SetPayload(job *models.Job, payload interface{}) error {
+	encoded, err := encodePayload(payload)
+	if err != nil {
+		return err
+	}
+	job.Payload = encoded
+	return nil
+}
+
+// IsFinalAttempt reports whether a failure of the current attempt will mark the job failed
+func IsFinalAttempt(job *models.Job) bool {
+	return job.Attempts >= job.MaxAttempts
+}
+
+func encodePayload(payload interface{}) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling job payload: %w", err)
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(encoded, &result); err != nil {
+		return nil, fmt.Errorf("job payload must be a json object: %w", err)
+	}
+	return result, nil
+}
+
+// Worker claims queued jobs from the database and runs their handlers
+type Worker struct {
+	db           *gorm.DB
+	handlers     map[string]Handler
+	tasks        []task
+	concurrency  int
+	pollInterval time.Duration
+}
+
+// task is maintenance the worker runs on an interval
+type task struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+func NewWorker(db *gorm.DB, concurrency int) *Worker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Worker{
+		db:           db,
+		handlers:     make(map[string]Handler),
+		concurrency:  concurrency,
+		pollInterval: 1 * time.Second,
+	}
+}
+
+// Register sets the handler for a job type
+func (w *Worker) Register(jobType string, handler Handler) {
+	w.handlers[jobType] = handler
+}
+
+// Every runs fn on the interval while the worker runs. Every worker runs it, so it has to be safe to run twice.
+func (w *Worker) Every(name string, interval time.Duration, fn func(ctx context.Context) error) {
+	w.tasks = append(w.tasks, task{name: name, interval: interval, run: fn})
+}
+
+// Run processes jobs until the context is cancelled, then waits for in-flight jobs to finish
+func (w *Worker) Run(ctx context.Context) {
+	logger.S.Infof("job worker started with %d goroutines", w.concurrency)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.recoverStaleJobs(ctx)
+	}()
+
+	for _, t := range w.tasks {
+		wg.Add(1)
+		go func(t task) {
+			defer wg.Done()
+			w.runTask(ctx, t)
+		}(t)
+	}
+
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+	wg.Wait()
+
+	logger.S.Info("job worker stopped")
+}
+
+// recoverStaleJobs requeues jobs whose worker died while running them
+func (w *Worker) recoverStaleJobs(ctx context.Context) {
+	ticker := time.NewTicker(recoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		result := w.db.Model(&models.Job{}).
+			Where("status = ? AND locked_at < ?", models.JobStatusRunning, time.Now().Add(-lease)).
+			Updates(map[string]interface{}{
+				"status":    models.JobStatusQueued,
+				"locked_at": nil,
+				"run_at":    time.Now(),
+			})
+		if result.Error != nil {
+			logger.S.Errorf("error recovering stale jobs: %v", result.Error)
+		} else if result.RowsAffected > 0 {
+			logger.S.Warnf("requeued %d stale jobs", result.RowsAffected)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) runTask(ctx context.Context, t task) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.run(ctx); err != nil {
+			logger.S.Errorf("error running %s: %v", t.name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) loop(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		job, err := w.claim()
+		if err != nil {
+			logger.S.Errorf("error claiming job: %v", err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(w.pollInterval):
+			}
+			continue
+		}
+
+		// Jobs run to completion even when shutting down so they aren't left half done
+		jobCtx, cancel := context.WithTimeout(context.Background(), handlerTimeout)
+		w.process(jobCtx, job)
+		cancel()
+	}
+}
+
+// claim locks the next due job, skipping jobs other workers have already locked
+func (w *Worker) claim() (*models.Job, error) {
+	types := make([]string, 0, len(w.handlers))
+	for jobType := range w.handlers {
+		types = append(types, jobType)
+	}
+
+	var job *models.Job
+	var err error
+	if w.db.Dialector.Name() == config.DBDriverPostgres {
+		err = w.db.Transaction(func(tx *gorm.DB) error {
+			job, err = lockNext(tx, tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}), types)
+			return err
+		})
+	} else {
+		// SQLite has no row locks, and a transaction that reads before writing fails while another worker
+		// writes, so the job is read on its own and a worker that loses the race misses it in the update
+		job, err = lockNext(w.db, w.db, types)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// lockNext finds the next due job with query and marks it running with db
+func lockNext(db *gorm.DB, query *gorm.DB, types []string) (*models.Job, error) {
+	var job models.Job
+	result := query.
+		Where("status = ? AND run_at <= ? AND type IN ?", models.JobStatusQueued, time.Now(), types).
+		Order("run_at").
+		Limit(1).
+		Find(&job)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	now := time.Now()
+	job.Status = models.JobStatusRunning
+	job.Attempts++
+	job.LockedAt = &now
+	result = db.Model(&job).
+		Where("status = ?", models.JobStatusQueued).
+		Select("status", "attempts", "locked_at", "updated_at").
+		Updates(&job)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &job, nil
+}
+
+func (w *Worker) process(ctx context.Context, job *models.Job) {
+	err := w.run(ctx, job)
+
+	now := time.Now()
+	job.LockedAt = nil
+	if err == nil {
+		job.Status = models.JobStatusSucceeded
+		job.CompletedAt = &now
+		job.LastError = ""
+	} else if IsFinalAttempt(job) || IsPermanent(err) {
+		logger.S.Errorf("job %d (%s) failed after %d attempts: %v", job.ID, job.Type, job.Attempts, err)
+		job.Status = models.JobStatusFailed
+		job.CompletedAt = &now
+		job.LastError = err.Error()
+	} else {
+		logger.S.Warnf("job %d (%s) attempt %d failed, retrying: %v", job.ID, job.Type, job.Attempts, err)
+		job.Status = models.JobStatusQueued
+		job.RunAt = now.Add(retryDelay(job.Attempts))
+		job.LastError = err.Error()
+	}
+
+	// Only record the result while the claim holds. A job that outlived its lease was requeued and may have been
+	// claimed again, which bumps its attempts, and the newer run owns the row.
+	result := w.db.Model(job).
+		Where("status = ? AND attempts = ?", models.JobStatusRunning, job.Attempts).
+		Select("status", "locked_at", "completed_at", "last_error", "run_at", "payload", "updated_at").
+		Updates(job)
+	if result.Error != nil {
+		logger.S.Errorf("error saving job %d: %v", job.ID, result.Error)
+	} else if result.RowsAffected == 0 {
+		logger.S.Warnf("job %d (%s) attempt %d finished after its lease expired, dropping its result", job.ID, job.Type, job.Attempts)
+	}
+}
+
+// IsPermanent reports whether the error was marked as one retrying won't fix
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+// run calls the job's handler, turning panics into errors so one bad job can't take the worker down
+func (w *Worker) run(ctx context.Context, job *models.Job) (err error) {
+	handler, ok := w.handlers[job.Type]
+	if !ok {
+		return fmt.Errorf("no handler registered for job type %s", job.Type)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return handler(ctx, job)
+}
+
+// retryDelay doubles the delay with every attempt, with jitter so failed jobs don't retry in lockstep
+func retryDelay(attempts int) time.Duration {
+	delay := baseRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+}
@@ -0,0 +1,241 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/migrations"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// newTestWorker returns a worker on a fresh SQLite database that runs handler for "test" jobs
+func newTestWorker(t *testing.T, handler Handler) *Worker {
+	t.Helper()
+	if logger.S == nil {
+		logger.InitLogger("test")
+	}
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "jobs.db")+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWorker(db, 1)
+	w.Register("test", handler)
+	return w
+}
+
+func reload(t *testing.T, db *gorm.DB, job *models.Job) models.Job {
+	t.Helper()
+	var stored models.Job
+	if err := db.First(&stored, job.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+func TestClaim(t *testing.T) {
+	w := newTestWorker(t, func(ctx context.Context, job *models.Job) error { return nil })
+
+	later, err := Enqueue(w.db, "test", map[string]string{}, RunAt(time.Now().Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Enqueue(w.db, "unregistered", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	due, err := Enqueue(w.db, "test", map[string]string{"n": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := w.claim()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job == nil || job.ID != due.ID {
+		t.Fatalf("claimed %+v, want job %d", job, due.ID)
+	}
+	if job.Status != models.JobStatusRunning || job.Attempts != 1 || job.LockedAt == nil {
+		t.Errorf("claimed job is %s with %d attempts and lock %v", job.Status, job.Attempts, job.LockedAt)
+	}
+
+	// The running job, the future job and the job without a handler are all left alone
+	job, err = w.claim()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job != nil {
+		t.Errorf("claimed job %d, want nothing", job.ID)
+	}
+	if stored := reload(t, w.db, later); stored.Status != models.JobStatusQueued || stored.Attempts != 0 {
+		t.Errorf("future job is %s with %d attempts", stored.Status, stored.Attempts)
+	}
+}
+
+func TestConcurrentClaimsTakeEachJobOnce(t *testing.T) {
+	w := newTestWorker(t, func(ctx context.Context, job *models.Job) error { return nil })
+	for i := 0; i < 20; i++ {
+		if _, err := Enqueue(w.db, "test", map[string]int{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	claimed := make(map[uint]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, err := w.claim()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if job == nil {
+					return
+				}
+				mu.Lock()
+				claimed[job.ID]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claimed) != 20 {
+		t.Errorf("claimed %d jobs, want 20", len(claimed))
+	}
+	for id, n := range claimed {
+		if n != 1 {
+			t.Errorf("job %d was claimed %d times", id, n)
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		maxAttempts int
+		wantStatus  string
+	}{
+		{name: "success", wantStatus: models.JobStatusSucceeded, maxAttempts: 3},
+		{name: "retry", err: errors.New("vendor is down"), maxAttempts: 3, wantStatus: models.JobStatusQueued},
+		{name: "final attempt", err: errors.New("vendor is down"), maxAttempts: 1, wantStatus: models.JobStatusFailed},
+		{name: "permanent", err: Permanent(errors.New("bad payload")), maxAttempts: 3, wantStatus: models.JobStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWorker(t, func(ctx context.Context, job *models.Job) error { return tt.err })
+			if _, err := Enqueue(w.db, "test", map[string]string{}, MaxAttempts(tt.maxAttempts)); err != nil {
+				t.Fatal(err)
+			}
+			job, err := w.claim()
+			if err != nil || job == nil {
+				t.Fatalf("error claiming job: %v", err)
+			}
+
+			start := time.Now()
+			w.process(context.Background(), job)
+			stored := reload(t, w.db, job)
+			if stored.Status != tt.wantStatus {
+				t.Fatalf("got status %s, want %s", stored.Status, tt.wantStatus)
+			}
+			if stored.LockedAt != nil {
+				t.Error("job is still locked")
+			}
+			if tt.err != nil && stored.LastError != tt.err.Error() {
+				t.Errorf("got last error %q, want %q", stored.LastError, tt.err.Error())
+			}
+
+			// The first retry waits between half and all of the base delay
+			if tt.wantStatus == models.JobStatusQueued {
+				if wait := stored.RunAt.Sub(start); wait < baseRetryDelay/2 || wait > baseRetryDelay+time.Second {
+					t.Errorf("retry runs in %v, want between %v and %v", wait, baseRetryDelay/2, baseRetryDelay)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		max      time.Duration
+	}{
+		{attempts: 1, max: baseRetryDelay},
+		{attempts: 2, max: 2 * baseRetryDelay},
+		{attempts: 3, max: 4 * baseRetryDelay},
+		{attempts: 20, max: maxRetryDelay},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if delay := retryDelay(tt.attempts); delay < tt.max/2 || delay >= tt.max {
+				t.Errorf("attempt %d: got delay %v, want between %v and %v", tt.attempts, delay, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestStaleJobsAreRecovered(t *testing.T) {
+	w := newTestWorker(t, func(ctx context.Context, job *models.Job) error { return nil })
+	if _, err := Enqueue(w.db, "test", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	stale, err := w.claim()
+	if err != nil || stale == nil {
+		t.Fatalf("error claiming job: %v", err)
+	}
+
+	// The worker running the job dies, once the lease is up the job is queued again
+	lockedAt := time.Now().Add(-lease - time.Minute)
+	if err := w.db.Model(stale).Update("locked_at", lockedAt).Error; err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w.recoverStaleJobs(ctx)
+	if stored := reload(t, w.db, stale); stored.Status != models.JobStatusQueued || stored.LockedAt != nil {
+		t.Fatalf("stale job is %s with lock %v, want it queued", stored.Status, stored.LockedAt)
+	}
+
+	job, err := w.claim()
+	if err != nil || job == nil || job.ID != stale.ID {
+		t.Fatalf("error reclaiming job: %v", err)
+	}
+
+	// The first run finishing late doesn't overwrite the run that owns the job now
+	w.process(context.Background(), stale)
+	if stored := reload(t, w.db, job); stored.Status != models.JobStatusRunning || stored.Attempts != 2 {
+		t.Fatalf("job is %s with %d attempts, want the second attempt still running", stored.Status, stored.Attempts)
+	}
+
+	w.process(context.Background(), job)
+	if stored := reload(t, w.db, job); stored.Status != models.JobStatusSucceeded {
+		t.Errorf("job is %s, want succeeded", stored.Status)
+	}
+}
@@ -0,0 +1,142 @@
+package knowledge
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	ContentTypeText     = "text"
+	ContentTypeMarkdown = "markdown"
+	ContentTypePDF      = "pdf"
+)
+
+// ChunkSize is the target number of characters per chunk, ChunkOverlap is carried over between chunks
+const (
+	ChunkSize    = 1000
+	ChunkOverlap = 150
+)
+
+var (
+	markdownHeading = regexp.MustCompile(`(?m)^#{1,6}\s`)
+	hyphenatedBreak = regexp.MustCompile(`(\w)-\n(\w)`)
+	blankLines      = regexp.MustCompile(`\n\s*\n`)
+	whitespace      = regexp.MustCompile(`[ \t\r\f\v]+`)
+)
+
+// ValidContentType reports whether documents of the content type can be ingested
+func ValidContentType(contentType string) bool {
+	switch contentType {
+	case ContentTypeText, ContentTypeMarkdown, ContentTypePDF:
+		return true
+	}
+	return false
+}
+
+// Chunk splits a document into overlapping chunks that respect section and paragraph boundaries where possible
+func Chunk(content string, contentType string) []string {
+	content = normalize(content, contentType)
+
+	var chunks []string
+	for _, section := range sections(content, contentType) {
+		chunks = append(chunks, splitSection(section)...)
+	}
+	return chunks
+}
+
+func normalize(content string, contentType string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if contentType == ContentTypePDF {
+		// Text extracted from PDFs breaks words across lines and pages
+		content = hyphenatedBreak.ReplaceAllString(content, "$1$2")
+		content = strings.ReplaceAll(content, "\f", "\n\n")
+	}
+	content = whitespace.ReplaceAllString(content, " ")
+	return strings.TrimSpace(content)
+}
+
+// sections splits markdown on headings so chunks don't straddle unrelated sections
+func sections(content string, contentType string) []string {
+	if contentType != ContentTypeMarkdown {
+		return []string{content}
+	}
+
+	var result []string
+	indexes := markdownHeading.FindAllStringIndex(content, -1)
+	start := 0
+	for _, index := range indexes {
+		if index[0] > start {
+			result = append(result, content[start:index[0]])
+		}
+		start = index[0]
+	}
+	result = append(result, content[start:])
+	return result
+}
+
+func splitSection(section string) []string {
+	var chunks []string
+	current := ""
+
+	for _, paragraph := range splitParagraphs(section) {
+		if current != "" && len(current)+len(paragraph)+2 > ChunkSize {
+			chunks = append(chunks, current)
+			current = overlap(current)
+		}
+		if current == "" {
+			current = paragraph
+		} else {
+			current += "\n\n" + paragraph
+		}
+	}
+
+	if strings.TrimSpace(current) != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// splitParagraphs breaks text on blank lines, further splitting paragraphs that are larger than a chunk
+func splitParagraphs(text string) []string {
+	var paragraphs []string
+	for _, paragraph := range blankLines.Split(text, -1) {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+
+		for len(paragraph) > ChunkSize {
+			cut := strings.LastIndexAny(paragraph[:ChunkSize], ".!?\n ")
+			if cut <= 0 {
+				cut = ChunkSize - 1
+				for cut > 0 && !utf8.RuneStart(paragraph[cut+1]) {
+					cut--
+				}
+			}
+			paragraphs = append(paragraphs, strings.TrimSpace(paragraph[:cut+1]))
+			paragraph = strings.TrimSpace(paragraph[cut+1:])
+		}
+		if paragraph != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+	return paragraphs
+}
+
+// overlap returns the tail of a chunk to carry over into the next, starting on a word boundary
+func overlap(chunk string) string {
+	if len(chunk) <= ChunkOverlap {
+		return ""
+	}
+	// Don't cut a multi-byte character in half
+	start := len(chunk) - ChunkOverlap
+	for start > 0 && !utf8.RuneStart(chunk[start]) {
+		start--
+	}
+	tail := chunk[start:]
+	if i := strings.IndexByte(tail, ' '); i >= 0 {
+		tail = tail[i+1:]
+	}
+	return tail
+}
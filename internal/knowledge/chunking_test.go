@@ -0,0 +1,25 @@
+package knowledge
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunksAreValidUTF8(t *testing.T) {
+	for name, content := range map[string]string{
+		"without spaces": strings.Repeat("日本語の文章", 400),
+		"with spaces":    strings.Repeat("überprüfen Straße ", 300),
+		"mixed widths":   strings.Repeat("a€😀", 500),
+	} {
+		chunks := Chunk(content, ContentTypeText)
+		if len(chunks) < 2 {
+			t.Fatalf("%s: got %d chunks, want the content split", name, len(chunks))
+		}
+		for i, chunk := range chunks {
+			if !utf8.ValidString(chunk) {
+				t.Errorf("%s: chunk %d isn't valid UTF-8", name, i)
+			}
+		}
+	}
+}
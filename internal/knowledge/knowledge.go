@@ -0,0 +1,167 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/sashabaranov/go-openai"
+	"gorm.io/gorm"
+)
+
+const (
+	EmbeddingModel = openai.SmallEmbedding3
+
+	// embeddingBatchSize is the number of chunks embedded per request
+	embeddingBatchSize = 100
+)
+
+// Embed returns an embedding for every input text
+func Embed(ctx context.Context, client *openai.Client, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, 0, len(texts))
+
+	for start := 0; start < len(texts); start += embeddingBatchSize {
+		end := start + embeddingBatchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+
+		input := make([]string, 0, end-start)
+		for _, text := range texts[start:end] {
+			input = append(input, strings.ReplaceAll(text, "\n", " "))
+		}
+
+		resp, err := client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+			Input: input,
+			Model: EmbeddingModel,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error creating embeddings: %w", err)
+		}
+		if len(resp.Data) != len(input) {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", len(input), len(resp.Data))
+		}
+
+		sort.Slice(resp.Data, func(i, j int) bool { return resp.Data[i].Index < resp.Data[j].Index })
+		for _, data := range resp.Data {
+			embeddings = append(embeddings, data.Embedding)
+		}
+	}
+
+	return embeddings, nil
+}
+
+// Ingest chunks and embeds a document's content, replacing any chunks previously stored for the document
+func Ingest(ctx context.Context, db *gorm.DB, client *openai.Client, document *models.Document, content string) error {
+	texts := Chunk(content, document.ContentType)
+	if len(texts) == 0 {
+		return fmt.Errorf("document %q has no text content", document.Name)
+	}
+
+	embeddings, err := Embed(ctx, client, texts)
+	if err != nil {
+		return err
+	}
+
+	chunks := make([]models.DocumentChunk, 0, len(texts))
+	for i, text := range texts {
+		chunks = append(chunks, models.DocumentChunk{
+			KnowledgeBaseId: document.KnowledgeBaseId,
+			DocumentId:      document.ID,
+			Position:        i,
+			Content:         text,
+			Embedding:       embeddings[i],
+		})
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", document.ID).Delete(&models.DocumentChunk{}).Error; err != nil {
+			return err
+		}
+		if err := tx.CreateInBatches(chunks, embeddingBatchSize).Error; err != nil {
+			return err
+		}
+
+		document.Characters = len(content)
+		document.NumChunks = len(chunks)
+		return tx.Save(document).Error
+	})
+}
+
+// Result is a chunk matched by a search along with its cosine similarity to the query
+type Result struct {
+	Chunk models.DocumentChunk
+	Score float64
+}
+
+// Index is an in-process vector index over the chunks of one or more knowledge bases
+type Index struct {
+	chunks []models.DocumentChunk
+	norms  []float64
+}
+
+// LoadIndex loads every chunk belonging to the given knowledge bases into memory
+func LoadIndex(db *gorm.DB, knowledgeBaseIds []uint) (*Index, error) {
+	index := &Index{}
+	if len(knowledgeBaseIds) == 0 {
+		return index, nil
+	}
+
+	if err := db.Where("knowledge_base_id IN ?", knowledgeBaseIds).Find(&index.chunks).Error; err != nil {
+		return nil, err
+	}
+
+	index.norms = make([]float64, len(index.chunks))
+	for i, chunk := range index.chunks {
+		index.norms[i] = norm(chunk.Embedding)
+	}
+
+	return index, nil
+}
+
+// Len returns the number of chunks in the index
+func (idx *Index) Len() int {
+	return len(idx.chunks)
+}
+
+// Search returns up to k chunks most similar to the query embedding with a score of at least minScore
+func (idx *Index) Search(query []float32, k int, minScore float64) []Result {
+	queryNorm := norm(query)
+	if queryNorm == 0 {
+		return nil
+	}
+
+	var results []Result
+	for i, chunk := range idx.chunks {
+		if idx.norms[i] == 0 || len(chunk.Embedding) != len(query) {
+			continue
+		}
+
+		var dot float64
+		for j := range query {
+			dot += float64(query[j]) * float64(chunk.Embedding[j])
+		}
+
+		score := dot / (queryNorm * idx.norms[i])
+		if score >= minScore {
+			results = append(results, Result{Chunk: chunk, Score: score})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+func norm(vector []float32) float64 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	return math.Sqrt(sum)
+}
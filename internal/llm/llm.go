@@ -0,0 +1,64 @@
+package llm
+
+import (
+	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+const DefaultModel = "gpt-4o"
+
+// LLM is an OpenAI compatible chat completion endpoint
+type LLM struct {
+	// Vendor hosting the model, used in telemetry
+	Vendor  string
+	BaseURL string
+	Model   string
+	APIKey  string
+}
+
+func models(cfg *config.Config) map[string]LLM {
+	return map[string]LLM{
+		"gpt-4o": {
+			Vendor:  "openai",
+			BaseURL: "https://api.openai.com/v1",
+			Model:   "gpt-4o",
+			APIKey:  cfg.OpenAIAPIKey,
+		},
+		"gpt-4o-mini": {
+			Vendor:  "openai",
+			BaseURL: "https://api.openai.com/v1",
+			Model:   "gpt-4o-mini",
+			APIKey:  cfg.OpenAIAPIKey,
+		},
+		"flyflow-voice": {
+			Vendor:  "fireworks",
+			BaseURL: "https://api.fireworks.ai/inference/v1",
+			Model:   "accounts/fireworks/models/llama-v3-70b-instruct",
+			APIKey:  cfg.FireworksAPIKey,
+		},
+	}
+}
+
+// Valid reports whether name is one of the models agents can use
+func Valid(name string) bool {
+	_, ok := models(&config.Config{})[name]
+	return ok
+}
+
+// Get returns the endpoint for the named model, falling back to the default model for unknown names
+func Get(cfg *config.Config, name string) LLM {
+	llms := models(cfg)
+	llm, ok := llms[name]
+	if !ok {
+		return llms[DefaultModel]
+	}
+	return llm
+}
+
+// NewClient returns a client for the named model along with the model name to send in requests
+func NewClient(cfg *config.Config, name string) (*openai.Client, string) {
+	llm := Get(cfg, name)
+	openaiConfig := openai.DefaultConfig(llm.APIKey)
+	openaiConfig.BaseURL = llm.BaseURL
+	return openai.NewClientWithConfig(openaiConfig), llm.Model
+}
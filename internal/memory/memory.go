@@ -0,0 +1,229 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/recordings"
+	"gorm.io/gorm"
+)
+
+// DefaultCalls is the number of previous calls remembered when the agent doesn't set a limit
+const DefaultCalls = 5
+
+// maxSummaryLength keeps a single remembered call from crowding out the rest of the prompt
+const maxSummaryLength = 600
+
+// PreviousCall is what's remembered about one earlier call with a caller
+type PreviousCall struct {
+	CallId        uint                   `json:"call_id"`
+	StartedAt     time.Time              `json:"started_at"`
+	Summary       string                 `json:"summary,omitempty"`
+	Disposition   string                 `json:"disposition,omitempty"`
+	ExtractedData map[string]interface{} `json:"extracted_data,omitempty"`
+}
+
+// Profile is everything an agent remembers about a caller
+type Profile struct {
+	AgentId       uint           `json:"agent_id"`
+	ClientNumber  string         `json:"client_number"`
+	PreviousCalls []PreviousCall `json:"previous_calls"`
+
+	// Prompt is the caller history as it's given to the agent
+	Prompt string `json:"prompt"`
+}
+
+// Load returns the agent's most recent finished calls with the caller, newest first. excludeCallId leaves
+// out the call in progress.
+func Load(db *gorm.DB, agent *models.Agent, clientNumber string, excludeCallId uint) (*Profile, error) {
+	limit := agent.CallerMemoryCalls
+	if limit <= 0 {
+		limit = DefaultCalls
+	}
+
+	var calls []models.Call
+	err := db.Select("id", "started_at", "summary", "disposition", "extracted_data").
+		Where("agent_id = ? AND client_number = ? AND id <> ? AND in_progress = ?", agent.ID, clientNumber, excludeCallId, false).
+		Order("started_at DESC").
+		Limit(limit).
+		Find(&calls).Error
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &Profile{
+		AgentId:       agent.ID,
+		ClientNumber:  clientNumber,
+		PreviousCalls: []PreviousCall{},
+	}
+	for _, call := range calls {
+		if call.Summary == "" && call.Disposition == "" && len(call.ExtractedData) == 0 {
+			continue
+		}
+		profile.PreviousCalls = append(profile.PreviousCalls, PreviousCall{
+			CallId:        call.ID,
+			StartedAt:     call.StartedAt,
+			Summary:       call.Summary,
+			Disposition:   call.Disposition,
+			ExtractedData: call.ExtractedData,
+		})
+	}
+	profile.Prompt = render(profile.PreviousCalls, location(agent))
+
+	return profile, nil
+}
+
+// render writes the previous calls as a compact, oldest first history for the system prompt
+func render(calls []PreviousCall, loc *time.Location) string {
+	var b strings.Builder
+	for i := len(calls) - 1; i >= 0; i-- {
+		call := calls[i]
+		fmt.Fprintf(&b, "- Call on %s", call.StartedAt.In(loc).Format("January 2, 2006"))
+		if call.Disposition != "" {
+			fmt.Fprintf(&b, " (outcome: %s)", call.Disposition)
+		}
+		b.WriteString("\n")
+
+		if call.Summary != "" {
+			summary := call.Summary
+			if len(summary) > maxSummaryLength {
+				summary = strings.ToValidUTF8(summary[:maxSummaryLength], "") + "..."
+			}
+			fmt.Fprintf(&b, "  Summary: %s\n", summary)
+		}
+		if len(call.ExtractedData) > 0 {
+			if data, err := json.Marshal(call.ExtractedData); err == nil {
+				fmt.Fprintf(&b, "  Details: %s\n", data)
+			}
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func location(agent *models.Agent) *time.Location {
+	if loc, err := time.LoadLocation(agent.Timezone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// erasedColumns are scrubbed from a caller's calls when their memory is erased
+var erasedColumns = map[string]interface{}{
+	"client_number":      "",
+	"transcript":         "[]",
+	"context":            "",
+	"variables":          "{}",
+	"summary":            "",
+	"action_items":       "[]",
+	"extracted_data":     nil,
+	"sentiment_timeline": "[]",
+	"retrievals":         "[]",
+	"node_transitions":   "[]",
+	"handoffs":           "[]",
+	"recording_sid":      "",
+	"recording_path":     "",
+}
+
+// Erasure is what was erased about a caller
+type Erasure struct {
+	Calls                int64 `json:"calls_erased"`
+	EndpointingDecisions int64 `json:"endpointing_decisions_erased"`
+	Jobs                 int64 `json:"jobs_erased"`
+	ScheduledCalls       int64 `json:"scheduled_calls_erased"`
+	CampaignContacts     int64 `json:"campaign_contacts_erased"`
+
+	// Recordings are deleted from Twilio and the archive by a background job
+	RecordingsDeleting int `json:"recordings_deleting"`
+}
+
+// Erase removes everything kept about a caller from the user's calls, limited to one agent when agentId
+// isn't zero: the calls' content, their recordings, the endpointing decisions made on them, the queued
+// and finished jobs for them, whose payloads hold summaries and webhook events, the context and variables
+// of calls scheduled to the caller or booked on their calls, and the variables of campaign contacts with
+// their number. Calls in progress and jobs running during the erase are left alone. Webhooks already
+// delivered and the do-not-call list are outside of it.
+func Erase(db *gorm.DB, userId uint, agentId uint, clientNumber string) (*Erasure, error) {
+	erasure := &Erasure{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&models.Call{}).
+			Where("client_number = ? AND in_progress = ?", clientNumber, false).
+			Where("agent_id IN (SELECT id FROM agents WHERE user_id = ?)", userId)
+		if agentId != 0 {
+			query = query.Where("agent_id = ?", agentId)
+		}
+
+		var calls []models.Call
+		if err := query.Select("id", "recording_sid", "recording_path").Find(&calls).Error; err != nil {
+			return err
+		}
+
+		callIds := make([]uint, 0, len(calls))
+		var stored []recordings.Recording
+		for _, call := range calls {
+			callIds = append(callIds, call.ID)
+			if call.RecordingSid != "" || call.RecordingPath != "" {
+				stored = append(stored, recordings.Recording{Sid: call.RecordingSid, Path: call.RecordingPath})
+			}
+		}
+
+		// Calls to the caller scheduled through the API and callbacks booked on their calls. to is quoted as
+		// it's a keyword.
+		scheduled := tx.Where(`"to" = ? AND user_id = ?`, clientNumber, userId)
+		if agentId != 0 {
+			scheduled = scheduled.Where("agent_id = ?", agentId)
+		}
+		if len(callIds) > 0 {
+			scheduled = tx.Where(scheduled).Or("source_call_id IN ?", callIds)
+		}
+		result := tx.Model(&models.ScheduledCall{}).Where(scheduled).Updates(map[string]interface{}{"context": "", "variables": "{}"})
+		if result.Error != nil {
+			return result.Error
+		}
+		erasure.ScheduledCalls = result.RowsAffected
+
+		// Campaigns keep dialing the number, only what was imported about the caller is erased
+		campaigns := tx.Model(&models.Campaign{}).Select("id").Where("user_id = ?", userId)
+		if agentId != 0 {
+			campaigns = campaigns.Where("agent_id = ?", agentId)
+		}
+		result = tx.Model(&models.CampaignContact{}).
+			Where("phone_number = ? AND campaign_id IN (?)", clientNumber, campaigns).
+			Update("variables", "{}")
+		if result.Error != nil {
+			return result.Error
+		}
+		erasure.CampaignContacts = result.RowsAffected
+
+		if len(callIds) == 0 {
+			return nil
+		}
+
+		result = tx.Model(&models.Call{}).Where("id IN ?", callIds).Updates(erasedColumns)
+		if result.Error != nil {
+			return result.Error
+		}
+		erasure.Calls = result.RowsAffected
+
+		result = tx.Where("call_id IN ?", callIds).Delete(&models.EndpointingDecision{})
+		if result.Error != nil {
+			return result.Error
+		}
+		erasure.EndpointingDecisions = result.RowsAffected
+
+		result = tx.Where("call_id IN ? AND status <> ?", callIds, models.JobStatusRunning).Delete(&models.Job{})
+		if result.Error != nil {
+			return result.Error
+		}
+		erasure.Jobs = result.RowsAffected
+
+		erasure.RecordingsDeleting = len(stored)
+		return recordings.EnqueueDelete(tx, userId, stored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return erasure, nil
+}
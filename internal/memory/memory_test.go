@@ -0,0 +1,86 @@
+package memory_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/flyflow-devs/flyflow/internal/apitest"
+	"github.com/flyflow-devs/flyflow/internal/memory"
+	"github.com/flyflow-devs/flyflow/internal/models"
+)
+
+func TestLoad(t *testing.T) {
+	h := apitest.New(t)
+	user, _ := h.CreateUser(t, "owner@example.com")
+	agent := models.Agent{UserId: user.ID, Name: "Support", PhoneNumber: "+15550000000", CallerMemoryCalls: 3, Timezone: "America/New_York"}
+	if err := h.DB.Create(&agent).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	caller := "+15551111111"
+	day := func(d int) time.Time { return time.Date(2026, time.March, d, 2, 0, 0, 0, time.UTC) }
+	calls := []models.Call{
+		{ClientNumber: caller, StartedAt: day(1), Summary: "Too old to be remembered"},
+		{ClientNumber: caller, StartedAt: day(2), Summary: strings.Repeat("é", 400), Disposition: "callback"},
+		{ClientNumber: caller, StartedAt: day(3)},
+		{ClientNumber: caller, StartedAt: day(4), ExtractedData: map[string]interface{}{"party_size": 4}},
+		{ClientNumber: caller, StartedAt: day(5), Summary: "Still on the call", InProgress: true},
+		{ClientNumber: "+15552222222", StartedAt: day(5), Summary: "Another caller"},
+		{ClientNumber: caller, StartedAt: day(6), Summary: "The call being answered"},
+	}
+	for i := range calls {
+		calls[i].AgentId = agent.ID
+		if err := h.DB.Create(&calls[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	profile, err := memory.Load(h.DB, &agent, caller, calls[6].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The three latest finished calls are looked at, the one with nothing remembered about it is skipped
+	var ids []uint
+	for _, call := range profile.PreviousCalls {
+		ids = append(ids, call.CallId)
+	}
+	if len(ids) != 2 || ids[0] != calls[3].ID || ids[1] != calls[1].ID {
+		t.Fatalf("got previous calls %v, want %d and %d", ids, calls[3].ID, calls[1].ID)
+	}
+
+	// The prompt tells the history oldest first in the agent's timezone, with long summaries cut short
+	lines := strings.Split(profile.Prompt, "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got prompt %q", profile.Prompt)
+	}
+	if lines[0] != "- Call on March 1, 2026 (outcome: callback)" || lines[2] != "- Call on March 3, 2026" {
+		t.Errorf("got call lines %q and %q", lines[0], lines[2])
+	}
+	summary := strings.TrimPrefix(strings.TrimSpace(lines[1]), "Summary: ")
+	if !strings.HasSuffix(summary, "...") || len(summary) > 603 || !strings.HasPrefix(summary, "éé") || !utf8.ValidString(summary) {
+		t.Errorf("got summary %q, want it cut to 600 bytes", summary)
+	}
+	if strings.TrimSpace(lines[3]) != `Details: {"party_size":4}` {
+		t.Errorf("got details %q", lines[3])
+	}
+}
+
+func TestLoadWithoutPreviousCalls(t *testing.T) {
+	h := apitest.New(t)
+	user, _ := h.CreateUser(t, "owner@example.com")
+	agent := models.Agent{UserId: user.ID, Name: "Support", PhoneNumber: "+15550000000"}
+	if err := h.DB.Create(&agent).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := memory.Load(h.DB, &agent, "+15551111111", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profile.PreviousCalls) != 0 || profile.Prompt != "" {
+		t.Errorf("got profile %+v, want it empty", profile)
+	}
+}
@@ -0,0 +1,235 @@
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/config"
+	"gorm.io/gorm"
+)
+
+// Migrations are sql/<dialect>/<version>_<name>.up.sql with a matching .down.sql that reverts it, each
+// dialect has the same versions. A down file with only comments marks a migration that can't be reverted.
+//
+//go:embed sql/postgres/*.sql sql/sqlite/*.sql
+var files embed.FS
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Key of the advisory lock that keeps instances from migrating at the same time
+const lockKey = 7_340_211
+
+// Migration is a versioned schema change
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Reversible reports whether the migration's down file has anything to run
+func (m Migration) Reversible() bool {
+	for _, line := range strings.Split(m.Down, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return true
+		}
+	}
+	return false
+}
+
+// SchemaMigration records an applied migration
+type SchemaMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// Status is a migration and when it was applied, AppliedAt is nil for pending migrations
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Load returns the embedded migrations for the database dialect in version order
+func Load(dialect string) ([]Migration, error) {
+	dir := "sql/" + dialect
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s databases", dialect)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		content, err := files.ReadFile(dir + "/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has files named %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies and reverts the embedded migrations, tracking them in the schema_migrations table
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	migrations, err := Load(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		if err := db.Migrator().CreateTable(&SchemaMigration{}); err != nil {
+			return nil, fmt.Errorf("error creating schema_migrations: %w", err)
+		}
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up applies the pending migrations in order and returns the ones it applied
+func (m *Migrator) Up() ([]Migration, error) {
+	var applied []Migration
+	for _, migration := range m.migrations {
+		ran := false
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := lock(tx); err != nil {
+				return err
+			}
+			// Another instance may have applied it while we waited for the lock
+			var count int64
+			if err := tx.Model(&SchemaMigration{}).Where("version = ?", migration.Version).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil
+			}
+
+			if err := tx.Exec(migration.Up).Error; err != nil {
+				return err
+			}
+			ran = true
+			return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("error applying migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		if ran {
+			applied = append(applied, migration)
+		}
+	}
+	return applied, nil
+}
+
+// Down reverts the last steps applied migrations and returns the ones it reverted
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	var reverted []Migration
+	for i := 0; i < steps; i++ {
+		var migration *Migration
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := lock(tx); err != nil {
+				return err
+			}
+			var last SchemaMigration
+			result := tx.Order("version DESC").Limit(1).Find(&last)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			migration = m.find(last.Version)
+			if migration == nil {
+				return fmt.Errorf("migration %d_%s isn't in this build", last.Version, last.Name)
+			}
+			if !migration.Reversible() {
+				return fmt.Errorf("migration %d_%s can't be reverted", migration.Version, migration.Name)
+			}
+			if err := tx.Exec(migration.Down).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, "version = ?", last.Version).Error
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("error reverting migration: %w", err)
+		}
+		if migration == nil {
+			break
+		}
+		reverted = append(reverted, *migration)
+	}
+	return reverted, nil
+}
+
+// Status returns every embedded migration and when it was applied
+func (m *Migrator) Status() ([]Status, error) {
+	var applied []SchemaMigration
+	if err := m.db.Order("version").Find(&applied).Error; err != nil {
+		return nil, err
+	}
+	appliedAt := map[int64]time.Time{}
+	for _, a := range applied {
+		appliedAt[a.Version] = a.AppliedAt
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if at, ok := appliedAt[migration.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// lock holds the migration lock until the transaction ends. SQLite only allows one writer at a time anyway.
+func lock(tx *gorm.DB) error {
+	if tx.Dialector.Name() != config.DBDriverPostgres {
+		return nil
+	}
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", lockKey).Error
+}
@@ -0,0 +1,19 @@
+//go:build postgres
+
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/flyflow-devs/flyflow/internal/apitest"
+)
+
+// Run with go test -tags postgres against the docker-compose database, or the one DB_HOST points at
+
+func TestPostgresMigrationsMatchModels(t *testing.T) {
+	testMigrationsMatchModels(t, apitest.PostgresConfig(t))
+}
+
+func TestPostgresUpDownStatus(t *testing.T) {
+	testUpDownStatus(t, apitest.PostgresConfig(t))
+}
@@ -0,0 +1,241 @@
+package migrations_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/flyflow-devs/flyflow/internal/apitest"
+	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/migrations"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/server"
+	"gorm.io/gorm"
+)
+
+func TestDialectsHaveTheSameVersions(t *testing.T) {
+	postgres, err := migrations.Load(config.DBDriverPostgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := migrations.Load(config.DBDriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(postgres) != len(sqlite) {
+		t.Fatalf("postgres has %d migrations and sqlite has %d", len(postgres), len(sqlite))
+	}
+	for i := range postgres {
+		if postgres[i].Version != sqlite[i].Version || postgres[i].Name != sqlite[i].Name {
+			t.Errorf("postgres migration %d_%s doesn't match sqlite migration %d_%s",
+				postgres[i].Version, postgres[i].Name, sqlite[i].Version, sqlite[i].Name)
+		}
+	}
+}
+
+// Both dialects have to end up with the same tables, columns and indexes, Postgres isn't run in every test
+func TestDialectsHaveTheSameSchema(t *testing.T) {
+	postgres := schemaOf(t, config.DBDriverPostgres)
+	sqlite := schemaOf(t, config.DBDriverSQLite)
+
+	for table, columns := range postgres.columns {
+		if _, ok := sqlite.columns[table]; !ok {
+			t.Errorf("table %s is only created for postgres", table)
+			continue
+		}
+		for column := range columns {
+			if !sqlite.columns[table][column] {
+				t.Errorf("column %s.%s is only created for postgres", table, column)
+			}
+		}
+	}
+	for table, columns := range sqlite.columns {
+		if _, ok := postgres.columns[table]; !ok {
+			t.Errorf("table %s is only created for sqlite", table)
+			continue
+		}
+		for column := range columns {
+			if !postgres.columns[table][column] {
+				t.Errorf("column %s.%s is only created for sqlite", table, column)
+			}
+		}
+	}
+
+	for index := range postgres.indexes {
+		if !sqlite.indexes[index] {
+			t.Errorf("index %s is only created for postgres", index)
+		}
+	}
+	for index := range sqlite.indexes {
+		if !postgres.indexes[index] {
+			t.Errorf("index %s is only created for sqlite", index)
+		}
+	}
+}
+
+var (
+	createTable = regexp.MustCompile(`^CREATE TABLE (?:IF NOT EXISTS )?"(\w+)" \($`)
+	tableColumn = regexp.MustCompile(`^\s+"(\w+)" `)
+	addColumn   = regexp.MustCompile(`^ALTER TABLE "(\w+)" ADD COLUMN (?:IF NOT EXISTS )?"(\w+)"`)
+	dropColumn  = regexp.MustCompile(`^ALTER TABLE "(\w+)" DROP COLUMN (?:IF EXISTS )?"(\w+)"`)
+	dropTable   = regexp.MustCompile(`^DROP TABLE (?:IF EXISTS )?"(\w+)"`)
+	createIndex = regexp.MustCompile(`^CREATE (?:UNIQUE )?INDEX (?:IF NOT EXISTS )?"(\w+)" ON "\w+"`)
+	dropIndex   = regexp.MustCompile(`^DROP INDEX (?:IF EXISTS )?"(\w+)"`)
+)
+
+type schema struct {
+	columns map[string]map[string]bool
+	indexes map[string]bool
+}
+
+// schemaOf reads the schema a dialect's up migrations create, applied in order
+func schemaOf(t *testing.T, dialect string) schema {
+	all, err := migrations.Load(dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := schema{columns: map[string]map[string]bool{}, indexes: map[string]bool{}}
+	for _, m := range all {
+		table := ""
+		for _, line := range strings.Split(m.Up, "\n") {
+			if table != "" {
+				if strings.HasPrefix(line, ")") {
+					table = ""
+				} else if match := tableColumn.FindStringSubmatch(line); match != nil {
+					s.columns[table][match[1]] = true
+				}
+				continue
+			}
+
+			if match := createTable.FindStringSubmatch(line); match != nil {
+				table = match[1]
+				s.columns[table] = map[string]bool{}
+			} else if match := addColumn.FindStringSubmatch(line); match != nil {
+				s.columns[match[1]][match[2]] = true
+			} else if match := dropColumn.FindStringSubmatch(line); match != nil {
+				delete(s.columns[match[1]], match[2])
+			} else if match := dropTable.FindStringSubmatch(line); match != nil {
+				delete(s.columns, match[1])
+			} else if match := createIndex.FindStringSubmatch(line); match != nil {
+				s.indexes[match[1]] = true
+			} else if match := dropIndex.FindStringSubmatch(line); match != nil {
+				delete(s.indexes, match[1])
+			} else if strings.HasPrefix(line, "ALTER TABLE") {
+				t.Errorf("%s migration %d_%s has a statement the test doesn't read: %s", dialect, m.Version, m.Name, line)
+			}
+		}
+	}
+	if len(s.columns) == 0 {
+		t.Fatalf("no tables read from the %s migrations", dialect)
+	}
+	return s
+}
+
+// The migrations have to create every column the models use, a new field needs a migration
+func TestMigrationsMatchModels(t *testing.T) {
+	testMigrationsMatchModels(t, apitest.Config(t))
+}
+
+func testMigrationsMatchModels(t *testing.T, cfg *config.Config) {
+	db := openDB(t, cfg)
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, model := range []interface{}{
+		&models.APIKey{}, &models.User{}, &models.Agent{}, &models.Call{}, &models.KnowledgeBase{}, &models.Document{},
+		&models.DocumentChunk{}, &models.Job{}, &models.Campaign{}, &models.CampaignContact{}, &models.ScheduledCall{},
+		&models.DoNotCallEntry{}, &models.EndpointingDecision{}, &models.CallOwner{},
+	} {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !db.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("%s.%s has no migration", stmt.Schema.Table, field.DBName)
+			}
+		}
+	}
+}
+
+func openDB(t *testing.T, cfg *config.Config) *gorm.DB {
+	db, err := server.InitDB(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestUpDownStatus(t *testing.T) {
+	testUpDownStatus(t, apitest.Config(t))
+}
+
+func testUpDownStatus(t *testing.T, cfg *config.Config) {
+	db := openDB(t, cfg)
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := migrator.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) == 0 {
+		t.Fatal("no migrations applied to an empty database")
+	}
+	if !db.Migrator().HasTable("calls") {
+		t.Fatal("calls table missing after migrating up")
+	}
+
+	// Applied migrations aren't run again
+	applied, err = migrator.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 {
+		t.Fatalf("%d migrations applied twice", len(applied))
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("migration %d_%s is pending after migrating up", status.Version, status.Name)
+		}
+	}
+
+	// Everything after the baseline is reverted, the baseline itself holds customer data and is kept
+	reverted, err := migrator.Down(len(statuses))
+	if err == nil {
+		t.Fatal("reverting the baseline succeeded")
+	}
+	if len(reverted) != len(statuses)-1 {
+		t.Fatalf("reverted %d migrations, want %d", len(reverted), len(statuses)-1)
+	}
+	if !db.Migrator().HasTable("calls") || db.Migrator().HasColumn("calls", "turn_latencies") {
+		t.Fatal("calls should be back to the baseline schema after migrating down")
+	}
+	if db.Migrator().HasTable("jobs") {
+		t.Fatal("jobs table left after migrating down")
+	}
+
+	// And the migrations after it apply again
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1 @@
+-- The baseline can't be reverted, it would drop every customer's agents, calls and keys.
@@ -0,0 +1,94 @@
+-- Baseline: the schema AutoMigrate created before versioned migrations, the tables and columns databases already have.
+-- Tables and indexes are only created when missing so databases set up by AutoMigrate can adopt it.
+
+CREATE TABLE IF NOT EXISTS "api_keys" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint,
+    "name" text,
+    "key" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_api_keys_created_at" ON "api_keys" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_api_keys_deleted_at" ON "api_keys" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_api_keys_key" ON "api_keys" ("key");
+CREATE INDEX IF NOT EXISTS "idx_api_keys_user_id" ON "api_keys" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "email" text,
+    "hashed_password" text,
+    "stripe_customer_id" text,
+    "plan" text,
+    "details" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_users_created_at" ON "users" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users" ("email");
+
+CREATE TABLE IF NOT EXISTS "agents" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint,
+    "name" text,
+    "phone_number" text,
+    "twilio_phone_sid" text,
+    "system_prompt" text,
+    "initial_message" text,
+    "llm_model" text,
+    "voice_id" text,
+    "webhook" text,
+    "tools" text,
+    "filler_words" boolean,
+    "actions" text,
+    "voicemail_number" text,
+    "chunking" boolean,
+    "endpointing" bigint,
+    "smart_endpointing_threshold" bigint,
+    "voice_optimization" bigint,
+    "multilingual" boolean,
+    "language" text,
+    "compliance_checks" text,
+    "filler_words_whitelist" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_agents_created_at" ON "agents" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_agents_deleted_at" ON "agents" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_agents_phone_number" ON "agents" ("phone_number");
+CREATE INDEX IF NOT EXISTS "idx_agents_twilio_phone_sid" ON "agents" ("twilio_phone_sid");
+CREATE INDEX IF NOT EXISTS "idx_agents_user_id" ON "agents" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "calls" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "agent_id" bigint,
+    "time_seconds" decimal,
+    "user_speaks_first" boolean,
+    "average_latency" decimal,
+    "transcript" text,
+    "context" text,
+    "sid" text,
+    "recording_sid" text,
+    "client_number" text,
+    "sentiment" bigint,
+    "in_progress" boolean,
+    "started_at" timestamptz,
+    "ended_at" timestamptz,
+    "disconnect_reason" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_calls_agent_id" ON "calls" ("agent_id");
+CREATE INDEX IF NOT EXISTS "idx_calls_client_number" ON "calls" ("client_number");
+CREATE INDEX IF NOT EXISTS "idx_calls_created_at" ON "calls" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_calls_deleted_at" ON "calls" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_calls_sid" ON "calls" ("sid");
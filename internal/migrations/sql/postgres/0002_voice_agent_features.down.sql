@@ -0,0 +1,52 @@
+DROP TABLE IF EXISTS "call_owners";
+DROP TABLE IF EXISTS "endpointing_decisions";
+DROP TABLE IF EXISTS "do_not_call_entries";
+DROP TABLE IF EXISTS "scheduled_calls";
+DROP TABLE IF EXISTS "campaign_contacts";
+DROP TABLE IF EXISTS "campaigns";
+DROP TABLE IF EXISTS "jobs";
+DROP TABLE IF EXISTS "document_chunks";
+DROP TABLE IF EXISTS "documents";
+DROP TABLE IF EXISTS "knowledge_bases";
+
+DROP INDEX IF EXISTS "idx_calls_campaign_id";
+DROP INDEX IF EXISTS "idx_calls_disposition";
+DROP INDEX IF EXISTS "idx_calls_type";
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS "calling_hours";
+ALTER TABLE "agents" DROP COLUMN IF EXISTS "voicemail";
+ALTER TABLE "agents" DROP COLUMN IF EXISTS "endpointer";
+ALTER TABLE "agents" DROP COLUMN IF EXISTS "speculative_tts";
+ALTER TABLE "agents" DROP COLUMN IF EXISTS "timezone";
+ALTER TABLE "agents" DROP COLUMN IF EXISTS "variables";
+ALTER TABLE "agents" DROP COLUMN IF EXISTS "knowledge_base_ids";
+ALTER TABLE "agents" DROP COLUMN IF EXISTS "flow";
+ALTER TABLE "agents" DROP COLUMN IF EXISTS "extraction_schema";
+ALTER TABLE "agents" DROP COLUMN IF EXISTS "analysis";
+ALTER TABLE "agents" DROP COLUMN IF EXISTS "caller_memory";
+ALTER TABLE "agents" DROP COLUMN IF EXISTS "caller_memory_calls";
+ALTER TABLE "agents" DROP COLUMN IF EXISTS "idle_policy";
+ALTER TABLE "agents" DROP COLUMN IF EXISTS "barge_in";
+ALTER TABLE "agents" DROP COLUMN IF EXISTS "handoff_message";
+ALTER TABLE "agents" DROP COLUMN IF EXISTS "routing";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "type";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "speculation_hit_rate";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "turn_latencies";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "variables";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "recording_path";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "status";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "answered_by";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "campaign_id";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "campaign_contact_id";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "routing_rule";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "sentiment_timeline";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "analysis_status";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "extracted_data";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "summary";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "disposition";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "action_items";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "retrievals";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "current_node";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "node_transitions";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "handoffs";
+ALTER TABLE "calls" DROP COLUMN IF EXISTS "interruptions";
@@ -0,0 +1,246 @@
+-- Tables and columns for knowledge bases, flows, analysis, jobs, campaigns, scheduled calls, caller memory,
+-- compliance, routing, endpointing and call ownership.
+-- Columns are only added when missing so databases that AutoMigrate already updated can adopt it.
+
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "calling_hours" text;
+
+ALTER TABLE "agents" ADD COLUMN IF NOT EXISTS "voicemail" text;
+ALTER TABLE "agents" ADD COLUMN IF NOT EXISTS "endpointer" text;
+ALTER TABLE "agents" ADD COLUMN IF NOT EXISTS "speculative_tts" boolean;
+ALTER TABLE "agents" ADD COLUMN IF NOT EXISTS "timezone" text;
+ALTER TABLE "agents" ADD COLUMN IF NOT EXISTS "variables" text;
+ALTER TABLE "agents" ADD COLUMN IF NOT EXISTS "knowledge_base_ids" text;
+ALTER TABLE "agents" ADD COLUMN IF NOT EXISTS "flow" text;
+ALTER TABLE "agents" ADD COLUMN IF NOT EXISTS "extraction_schema" text;
+ALTER TABLE "agents" ADD COLUMN IF NOT EXISTS "analysis" text;
+ALTER TABLE "agents" ADD COLUMN IF NOT EXISTS "caller_memory" boolean;
+ALTER TABLE "agents" ADD COLUMN IF NOT EXISTS "caller_memory_calls" bigint;
+ALTER TABLE "agents" ADD COLUMN IF NOT EXISTS "idle_policy" text;
+ALTER TABLE "agents" ADD COLUMN IF NOT EXISTS "barge_in" text;
+ALTER TABLE "agents" ADD COLUMN IF NOT EXISTS "handoff_message" text;
+ALTER TABLE "agents" ADD COLUMN IF NOT EXISTS "routing" text;
+
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "type" text;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "speculation_hit_rate" decimal;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "turn_latencies" text;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "variables" text;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "recording_path" text;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "status" text;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "answered_by" text;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "campaign_id" bigint;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "campaign_contact_id" bigint;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "routing_rule" text;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "sentiment_timeline" text;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "analysis_status" text;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "extracted_data" jsonb;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "summary" text;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "disposition" text;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "action_items" text;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "retrievals" text;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "current_node" text;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "node_transitions" text;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "handoffs" text;
+ALTER TABLE "calls" ADD COLUMN IF NOT EXISTS "interruptions" bigint;
+CREATE INDEX IF NOT EXISTS "idx_calls_campaign_id" ON "calls" ("campaign_id");
+CREATE INDEX IF NOT EXISTS "idx_calls_disposition" ON "calls" ("disposition");
+CREATE INDEX IF NOT EXISTS "idx_calls_type" ON "calls" ("type");
+
+CREATE TABLE IF NOT EXISTS "knowledge_bases" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint,
+    "name" text,
+    "description" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_knowledge_bases_created_at" ON "knowledge_bases" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_knowledge_bases_deleted_at" ON "knowledge_bases" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_knowledge_bases_user_id" ON "knowledge_bases" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "documents" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint,
+    "knowledge_base_id" bigint,
+    "name" text,
+    "content_type" text,
+    "characters" bigint,
+    "num_chunks" bigint,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_documents_created_at" ON "documents" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_documents_deleted_at" ON "documents" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_documents_knowledge_base_id" ON "documents" ("knowledge_base_id");
+CREATE INDEX IF NOT EXISTS "idx_documents_user_id" ON "documents" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "document_chunks" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "knowledge_base_id" bigint,
+    "document_id" bigint,
+    "position" bigint,
+    "content" text,
+    "embedding" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_document_chunks_created_at" ON "document_chunks" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_document_chunks_deleted_at" ON "document_chunks" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_document_chunks_document_id" ON "document_chunks" ("document_id");
+CREATE INDEX IF NOT EXISTS "idx_document_chunks_knowledge_base_id" ON "document_chunks" ("knowledge_base_id");
+
+CREATE TABLE IF NOT EXISTS "jobs" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint,
+    "call_id" bigint,
+    "type" text,
+    "payload" text,
+    "status" text,
+    "attempts" bigint,
+    "max_attempts" bigint,
+    "run_at" timestamptz,
+    "locked_at" timestamptz,
+    "completed_at" timestamptz,
+    "last_error" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_jobs_call_id" ON "jobs" ("call_id");
+CREATE INDEX IF NOT EXISTS "idx_jobs_created_at" ON "jobs" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_jobs_deleted_at" ON "jobs" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_jobs_run_at" ON "jobs" ("run_at");
+CREATE INDEX IF NOT EXISTS "idx_jobs_status" ON "jobs" ("status");
+CREATE INDEX IF NOT EXISTS "idx_jobs_type" ON "jobs" ("type");
+CREATE INDEX IF NOT EXISTS "idx_jobs_user_id" ON "jobs" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "campaigns" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint,
+    "agent_id" bigint,
+    "name" text,
+    "status" text,
+    "max_concurrent_calls" bigint,
+    "timezone" text,
+    "calling_window" text,
+    "retry" text,
+    "dialer_job_id" bigint,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_campaigns_agent_id" ON "campaigns" ("agent_id");
+CREATE INDEX IF NOT EXISTS "idx_campaigns_created_at" ON "campaigns" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_campaigns_deleted_at" ON "campaigns" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_campaigns_status" ON "campaigns" ("status");
+CREATE INDEX IF NOT EXISTS "idx_campaigns_user_id" ON "campaigns" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "campaign_contacts" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "campaign_id" bigint,
+    "phone_number" text,
+    "timezone" text,
+    "variables" text,
+    "status" text,
+    "attempts" bigint,
+    "next_attempt_at" timestamptz,
+    "outcome" text,
+    "last_error" text,
+    "call_ids" text,
+    "last_call_id" bigint,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_campaign_contacts_campaign_id" ON "campaign_contacts" ("campaign_id");
+CREATE INDEX IF NOT EXISTS "idx_campaign_contacts_created_at" ON "campaign_contacts" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_campaign_contacts_deleted_at" ON "campaign_contacts" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_campaign_contacts_last_call_id" ON "campaign_contacts" ("last_call_id");
+CREATE INDEX IF NOT EXISTS "idx_campaign_contacts_next_attempt_at" ON "campaign_contacts" ("next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_campaign_contacts_status" ON "campaign_contacts" ("status");
+
+CREATE TABLE IF NOT EXISTS "scheduled_calls" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint,
+    "agent_id" bigint,
+    "to" text,
+    "context" text,
+    "variables" text,
+    "user_speaks_first" boolean,
+    "timezone" text,
+    "scheduled_at" timestamptz,
+    "status" text,
+    "source" text,
+    "source_call_id" bigint,
+    "call_id" bigint,
+    "last_error" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_scheduled_calls_agent_id" ON "scheduled_calls" ("agent_id");
+CREATE INDEX IF NOT EXISTS "idx_scheduled_calls_created_at" ON "scheduled_calls" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_scheduled_calls_deleted_at" ON "scheduled_calls" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_scheduled_calls_scheduled_at" ON "scheduled_calls" ("scheduled_at");
+CREATE INDEX IF NOT EXISTS "idx_scheduled_calls_source_call_id" ON "scheduled_calls" ("source_call_id");
+CREATE INDEX IF NOT EXISTS "idx_scheduled_calls_status" ON "scheduled_calls" ("status");
+CREATE INDEX IF NOT EXISTS "idx_scheduled_calls_user_id" ON "scheduled_calls" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "do_not_call_entries" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint,
+    "phone_number" text,
+    "source" text,
+    "reason" text,
+    "call_id" bigint,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_do_not_call_entries_created_at" ON "do_not_call_entries" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_do_not_call_entries_deleted_at" ON "do_not_call_entries" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_do_not_call_user_number" ON "do_not_call_entries" ("user_id","phone_number");
+
+CREATE TABLE IF NOT EXISTS "endpointing_decisions" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "call_id" bigint,
+    "agent_id" bigint,
+    "endpointer" text,
+    "transcript" text,
+    "assistant_message" text,
+    "probability" bigint,
+    "threshold" bigint,
+    "latency_ms" bigint,
+    "failed" boolean,
+    "wait_ms" bigint,
+    "continued" boolean,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_endpointing_decisions_agent_id" ON "endpointing_decisions" ("agent_id");
+CREATE INDEX IF NOT EXISTS "idx_endpointing_decisions_call_id" ON "endpointing_decisions" ("call_id");
+CREATE INDEX IF NOT EXISTS "idx_endpointing_decisions_created_at" ON "endpointing_decisions" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_endpointing_decisions_deleted_at" ON "endpointing_decisions" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "call_owners" (
+    "call_id" bigint,
+    "instance_id" text,
+    "address" text,
+    "heartbeat_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("call_id")
+);
+CREATE INDEX IF NOT EXISTS "idx_call_owners_heartbeat_at" ON "call_owners" ("heartbeat_at");
+CREATE INDEX IF NOT EXISTS "idx_call_owners_instance_id" ON "call_owners" ("instance_id");
@@ -0,0 +1,2 @@
+-- How long the silence endpointer waits for the caller to keep talking after a final transcript
+ALTER TABLE "agents" ADD COLUMN IF NOT EXISTS "silence_window_ms" bigint;
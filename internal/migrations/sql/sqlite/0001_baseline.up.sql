@@ -0,0 +1,89 @@
+-- Baseline: the schema before versioned migrations, for SQLite databases used in development and tests.
+
+CREATE TABLE "api_keys" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "user_id" integer,
+    "name" text,
+    "key" text
+);
+CREATE INDEX "idx_api_keys_created_at" ON "api_keys"("created_at");
+CREATE INDEX "idx_api_keys_deleted_at" ON "api_keys"("deleted_at");
+CREATE UNIQUE INDEX "idx_api_keys_key" ON "api_keys"("key");
+CREATE INDEX "idx_api_keys_user_id" ON "api_keys"("user_id");
+
+CREATE TABLE "users" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "email" text,
+    "hashed_password" text,
+    "stripe_customer_id" text,
+    "plan" text,
+    "details" text
+);
+CREATE INDEX "idx_users_created_at" ON "users"("created_at");
+CREATE INDEX "idx_users_deleted_at" ON "users"("deleted_at");
+CREATE UNIQUE INDEX "idx_users_email" ON "users"("email");
+
+CREATE TABLE "agents" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "user_id" integer,
+    "name" text,
+    "phone_number" text,
+    "twilio_phone_sid" text,
+    "system_prompt" text,
+    "initial_message" text,
+    "llm_model" text,
+    "voice_id" text,
+    "webhook" text,
+    "tools" text,
+    "filler_words" numeric,
+    "actions" text,
+    "voicemail_number" text,
+    "chunking" numeric,
+    "endpointing" integer,
+    "smart_endpointing_threshold" integer,
+    "voice_optimization" integer,
+    "multilingual" numeric,
+    "language" text,
+    "compliance_checks" text,
+    "filler_words_whitelist" text
+);
+CREATE INDEX "idx_agents_created_at" ON "agents"("created_at");
+CREATE INDEX "idx_agents_deleted_at" ON "agents"("deleted_at");
+CREATE INDEX "idx_agents_phone_number" ON "agents"("phone_number");
+CREATE INDEX "idx_agents_twilio_phone_sid" ON "agents"("twilio_phone_sid");
+CREATE INDEX "idx_agents_user_id" ON "agents"("user_id");
+
+CREATE TABLE "calls" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "agent_id" integer,
+    "time_seconds" real,
+    "user_speaks_first" numeric,
+    "average_latency" real,
+    "transcript" text,
+    "context" text,
+    "sid" text,
+    "recording_sid" text,
+    "client_number" text,
+    "sentiment" integer,
+    "in_progress" numeric,
+    "started_at" datetime,
+    "ended_at" datetime,
+    "disconnect_reason" text
+);
+CREATE INDEX "idx_calls_agent_id" ON "calls"("agent_id");
+CREATE INDEX "idx_calls_client_number" ON "calls"("client_number");
+CREATE INDEX "idx_calls_created_at" ON "calls"("created_at");
+CREATE INDEX "idx_calls_deleted_at" ON "calls"("deleted_at");
+CREATE INDEX "idx_calls_sid" ON "calls"("sid");
@@ -0,0 +1,52 @@
+DROP TABLE IF EXISTS "call_owners";
+DROP TABLE IF EXISTS "endpointing_decisions";
+DROP TABLE IF EXISTS "do_not_call_entries";
+DROP TABLE IF EXISTS "scheduled_calls";
+DROP TABLE IF EXISTS "campaign_contacts";
+DROP TABLE IF EXISTS "campaigns";
+DROP TABLE IF EXISTS "jobs";
+DROP TABLE IF EXISTS "document_chunks";
+DROP TABLE IF EXISTS "documents";
+DROP TABLE IF EXISTS "knowledge_bases";
+
+DROP INDEX IF EXISTS "idx_calls_campaign_id";
+DROP INDEX IF EXISTS "idx_calls_disposition";
+DROP INDEX IF EXISTS "idx_calls_type";
+
+ALTER TABLE "users" DROP COLUMN "calling_hours";
+ALTER TABLE "agents" DROP COLUMN "voicemail";
+ALTER TABLE "agents" DROP COLUMN "endpointer";
+ALTER TABLE "agents" DROP COLUMN "speculative_tts";
+ALTER TABLE "agents" DROP COLUMN "timezone";
+ALTER TABLE "agents" DROP COLUMN "variables";
+ALTER TABLE "agents" DROP COLUMN "knowledge_base_ids";
+ALTER TABLE "agents" DROP COLUMN "flow";
+ALTER TABLE "agents" DROP COLUMN "extraction_schema";
+ALTER TABLE "agents" DROP COLUMN "analysis";
+ALTER TABLE "agents" DROP COLUMN "caller_memory";
+ALTER TABLE "agents" DROP COLUMN "caller_memory_calls";
+ALTER TABLE "agents" DROP COLUMN "idle_policy";
+ALTER TABLE "agents" DROP COLUMN "barge_in";
+ALTER TABLE "agents" DROP COLUMN "handoff_message";
+ALTER TABLE "agents" DROP COLUMN "routing";
+ALTER TABLE "calls" DROP COLUMN "type";
+ALTER TABLE "calls" DROP COLUMN "speculation_hit_rate";
+ALTER TABLE "calls" DROP COLUMN "turn_latencies";
+ALTER TABLE "calls" DROP COLUMN "variables";
+ALTER TABLE "calls" DROP COLUMN "recording_path";
+ALTER TABLE "calls" DROP COLUMN "status";
+ALTER TABLE "calls" DROP COLUMN "answered_by";
+ALTER TABLE "calls" DROP COLUMN "campaign_id";
+ALTER TABLE "calls" DROP COLUMN "campaign_contact_id";
+ALTER TABLE "calls" DROP COLUMN "routing_rule";
+ALTER TABLE "calls" DROP COLUMN "sentiment_timeline";
+ALTER TABLE "calls" DROP COLUMN "analysis_status";
+ALTER TABLE "calls" DROP COLUMN "extracted_data";
+ALTER TABLE "calls" DROP COLUMN "summary";
+ALTER TABLE "calls" DROP COLUMN "disposition";
+ALTER TABLE "calls" DROP COLUMN "action_items";
+ALTER TABLE "calls" DROP COLUMN "retrievals";
+ALTER TABLE "calls" DROP COLUMN "current_node";
+ALTER TABLE "calls" DROP COLUMN "node_transitions";
+ALTER TABLE "calls" DROP COLUMN "handoffs";
+ALTER TABLE "calls" DROP COLUMN "interruptions";
@@ -0,0 +1,236 @@
+-- Tables and columns for knowledge bases, flows, analysis, jobs, campaigns, scheduled calls, caller memory,
+-- compliance, routing, endpointing and call ownership.
+
+ALTER TABLE "users" ADD COLUMN "calling_hours" text;
+
+ALTER TABLE "agents" ADD COLUMN "voicemail" text;
+ALTER TABLE "agents" ADD COLUMN "endpointer" text;
+ALTER TABLE "agents" ADD COLUMN "speculative_tts" numeric;
+ALTER TABLE "agents" ADD COLUMN "timezone" text;
+ALTER TABLE "agents" ADD COLUMN "variables" text;
+ALTER TABLE "agents" ADD COLUMN "knowledge_base_ids" text;
+ALTER TABLE "agents" ADD COLUMN "flow" text;
+ALTER TABLE "agents" ADD COLUMN "extraction_schema" text;
+ALTER TABLE "agents" ADD COLUMN "analysis" text;
+ALTER TABLE "agents" ADD COLUMN "caller_memory" numeric;
+ALTER TABLE "agents" ADD COLUMN "caller_memory_calls" integer;
+ALTER TABLE "agents" ADD COLUMN "idle_policy" text;
+ALTER TABLE "agents" ADD COLUMN "barge_in" text;
+ALTER TABLE "agents" ADD COLUMN "handoff_message" text;
+ALTER TABLE "agents" ADD COLUMN "routing" text;
+
+ALTER TABLE "calls" ADD COLUMN "type" text;
+ALTER TABLE "calls" ADD COLUMN "speculation_hit_rate" real;
+ALTER TABLE "calls" ADD COLUMN "turn_latencies" text;
+ALTER TABLE "calls" ADD COLUMN "variables" text;
+ALTER TABLE "calls" ADD COLUMN "recording_path" text;
+ALTER TABLE "calls" ADD COLUMN "status" text;
+ALTER TABLE "calls" ADD COLUMN "answered_by" text;
+ALTER TABLE "calls" ADD COLUMN "campaign_id" integer;
+ALTER TABLE "calls" ADD COLUMN "campaign_contact_id" integer;
+ALTER TABLE "calls" ADD COLUMN "routing_rule" text;
+ALTER TABLE "calls" ADD COLUMN "sentiment_timeline" text;
+ALTER TABLE "calls" ADD COLUMN "analysis_status" text;
+ALTER TABLE "calls" ADD COLUMN "extracted_data" jsonb;
+ALTER TABLE "calls" ADD COLUMN "summary" text;
+ALTER TABLE "calls" ADD COLUMN "disposition" text;
+ALTER TABLE "calls" ADD COLUMN "action_items" text;
+ALTER TABLE "calls" ADD COLUMN "retrievals" text;
+ALTER TABLE "calls" ADD COLUMN "current_node" text;
+ALTER TABLE "calls" ADD COLUMN "node_transitions" text;
+ALTER TABLE "calls" ADD COLUMN "handoffs" text;
+ALTER TABLE "calls" ADD COLUMN "interruptions" integer;
+CREATE INDEX "idx_calls_campaign_id" ON "calls"("campaign_id");
+CREATE INDEX "idx_calls_disposition" ON "calls"("disposition");
+CREATE INDEX "idx_calls_type" ON "calls"("type");
+
+CREATE TABLE "knowledge_bases" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "user_id" integer,
+    "name" text,
+    "description" text
+);
+CREATE INDEX "idx_knowledge_bases_created_at" ON "knowledge_bases"("created_at");
+CREATE INDEX "idx_knowledge_bases_deleted_at" ON "knowledge_bases"("deleted_at");
+CREATE INDEX "idx_knowledge_bases_user_id" ON "knowledge_bases"("user_id");
+
+CREATE TABLE "documents" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "user_id" integer,
+    "knowledge_base_id" integer,
+    "name" text,
+    "content_type" text,
+    "characters" integer,
+    "num_chunks" integer
+);
+CREATE INDEX "idx_documents_created_at" ON "documents"("created_at");
+CREATE INDEX "idx_documents_deleted_at" ON "documents"("deleted_at");
+CREATE INDEX "idx_documents_knowledge_base_id" ON "documents"("knowledge_base_id");
+CREATE INDEX "idx_documents_user_id" ON "documents"("user_id");
+
+CREATE TABLE "document_chunks" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "knowledge_base_id" integer,
+    "document_id" integer,
+    "position" integer,
+    "content" text,
+    "embedding" text
+);
+CREATE INDEX "idx_document_chunks_created_at" ON "document_chunks"("created_at");
+CREATE INDEX "idx_document_chunks_deleted_at" ON "document_chunks"("deleted_at");
+CREATE INDEX "idx_document_chunks_document_id" ON "document_chunks"("document_id");
+CREATE INDEX "idx_document_chunks_knowledge_base_id" ON "document_chunks"("knowledge_base_id");
+
+CREATE TABLE "jobs" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "user_id" integer,
+    "call_id" integer,
+    "type" text,
+    "payload" text,
+    "status" text,
+    "attempts" integer,
+    "max_attempts" integer,
+    "run_at" datetime,
+    "locked_at" datetime,
+    "completed_at" datetime,
+    "last_error" text
+);
+CREATE INDEX "idx_jobs_call_id" ON "jobs"("call_id");
+CREATE INDEX "idx_jobs_created_at" ON "jobs"("created_at");
+CREATE INDEX "idx_jobs_deleted_at" ON "jobs"("deleted_at");
+CREATE INDEX "idx_jobs_run_at" ON "jobs"("run_at");
+CREATE INDEX "idx_jobs_status" ON "jobs"("status");
+CREATE INDEX "idx_jobs_type" ON "jobs"("type");
+CREATE INDEX "idx_jobs_user_id" ON "jobs"("user_id");
+
+CREATE TABLE "campaigns" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "user_id" integer,
+    "agent_id" integer,
+    "name" text,
+    "status" text,
+    "max_concurrent_calls" integer,
+    "timezone" text,
+    "calling_window" text,
+    "retry" text,
+    "dialer_job_id" integer
+);
+CREATE INDEX "idx_campaigns_agent_id" ON "campaigns"("agent_id");
+CREATE INDEX "idx_campaigns_created_at" ON "campaigns"("created_at");
+CREATE INDEX "idx_campaigns_deleted_at" ON "campaigns"("deleted_at");
+CREATE INDEX "idx_campaigns_status" ON "campaigns"("status");
+CREATE INDEX "idx_campaigns_user_id" ON "campaigns"("user_id");
+
+CREATE TABLE "campaign_contacts" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "campaign_id" integer,
+    "phone_number" text,
+    "timezone" text,
+    "variables" text,
+    "status" text,
+    "attempts" integer,
+    "next_attempt_at" datetime,
+    "outcome" text,
+    "last_error" text,
+    "call_ids" text,
+    "last_call_id" integer
+);
+CREATE INDEX "idx_campaign_contacts_campaign_id" ON "campaign_contacts"("campaign_id");
+CREATE INDEX "idx_campaign_contacts_created_at" ON "campaign_contacts"("created_at");
+CREATE INDEX "idx_campaign_contacts_deleted_at" ON "campaign_contacts"("deleted_at");
+CREATE INDEX "idx_campaign_contacts_last_call_id" ON "campaign_contacts"("last_call_id");
+CREATE INDEX "idx_campaign_contacts_next_attempt_at" ON "campaign_contacts"("next_attempt_at");
+CREATE INDEX "idx_campaign_contacts_status" ON "campaign_contacts"("status");
+
+CREATE TABLE "scheduled_calls" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "user_id" integer,
+    "agent_id" integer,
+    "to" text,
+    "context" text,
+    "variables" text,
+    "user_speaks_first" numeric,
+    "timezone" text,
+    "scheduled_at" datetime,
+    "status" text,
+    "source" text,
+    "source_call_id" integer,
+    "call_id" integer,
+    "last_error" text
+);
+CREATE INDEX "idx_scheduled_calls_agent_id" ON "scheduled_calls"("agent_id");
+CREATE INDEX "idx_scheduled_calls_created_at" ON "scheduled_calls"("created_at");
+CREATE INDEX "idx_scheduled_calls_deleted_at" ON "scheduled_calls"("deleted_at");
+CREATE INDEX "idx_scheduled_calls_scheduled_at" ON "scheduled_calls"("scheduled_at");
+CREATE INDEX "idx_scheduled_calls_source_call_id" ON "scheduled_calls"("source_call_id");
+CREATE INDEX "idx_scheduled_calls_status" ON "scheduled_calls"("status");
+CREATE INDEX "idx_scheduled_calls_user_id" ON "scheduled_calls"("user_id");
+
+CREATE TABLE "do_not_call_entries" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "user_id" integer,
+    "phone_number" text,
+    "source" text,
+    "reason" text,
+    "call_id" integer
+);
+CREATE INDEX "idx_do_not_call_entries_created_at" ON "do_not_call_entries"("created_at");
+CREATE INDEX "idx_do_not_call_entries_deleted_at" ON "do_not_call_entries"("deleted_at");
+CREATE UNIQUE INDEX "idx_do_not_call_user_number" ON "do_not_call_entries"("user_id","phone_number");
+
+CREATE TABLE "endpointing_decisions" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "call_id" integer,
+    "agent_id" integer,
+    "endpointer" text,
+    "transcript" text,
+    "assistant_message" text,
+    "probability" integer,
+    "threshold" integer,
+    "latency_ms" integer,
+    "failed" numeric,
+    "wait_ms" integer,
+    "continued" numeric
+);
+CREATE INDEX "idx_endpointing_decisions_agent_id" ON "endpointing_decisions"("agent_id");
+CREATE INDEX "idx_endpointing_decisions_call_id" ON "endpointing_decisions"("call_id");
+CREATE INDEX "idx_endpointing_decisions_created_at" ON "endpointing_decisions"("created_at");
+CREATE INDEX "idx_endpointing_decisions_deleted_at" ON "endpointing_decisions"("deleted_at");
+
+CREATE TABLE "call_owners" (
+    "call_id" integer,
+    "instance_id" text,
+    "address" text,
+    "heartbeat_at" datetime,
+    "created_at" datetime,
+    PRIMARY KEY ("call_id")
+);
+CREATE INDEX "idx_call_owners_heartbeat_at" ON "call_owners"("heartbeat_at");
+CREATE INDEX "idx_call_owners_instance_id" ON "call_owners"("instance_id");
@@ -0,0 +1 @@
+ALTER TABLE "agents" DROP COLUMN "silence_window_ms";
@@ -17,9 +17,17 @@ type Agent struct {
 	FillerWords    bool          `json:"filler_words"`
 	Actions        []Action      `json:"actions" gorm:"serializer:json"`
 	VoicemailNumber string       `json:"voicemail_number"`
+	Voicemail       *VoicemailSettings `json:"voicemail,omitempty" gorm:"serializer:json"`
 	Chunking        bool         `json:"chunking"`
 	Endpointing     uint         `json:"endpointing"`
 	SmartEndpointingThreshold uint `json:"smart_endpointing_threshold"`
+	Endpointer      string       `json:"endpointer,omitempty"`
+
+	// SilenceWindowMs is how long the silence endpointer waits for the caller to keep talking after a final transcript
+	SilenceWindowMs uint `json:"silence_window_ms,omitempty"`
+
+	// SpeculativeTTS synthesizes the first sentence of a response before the caller is known to be done
+	SpeculativeTTS bool `json:"speculative_tts"`
 	VoiceOptimization uint       `json:"voice_optimization"`
 	Multilingual      bool       `json:"multilingual"`
 	Language          string     `json:"language"`
@@ -27,6 +35,29 @@ type Agent struct {
 
 	FillerWordsWhitelist []string `json:"filler_words_whitelist" gorm:"serializer:json"`
 
+	Timezone  string           `json:"timezone"`
+	Variables []PromptVariable `json:"variables" gorm:"serializer:json"`
+
+	KnowledgeBaseIds []uint `json:"knowledge_base_ids" gorm:"serializer:json"`
+
+	Flow *Flow `json:"flow,omitempty" gorm:"serializer:json"`
+
+	ExtractionSchema map[string]interface{} `json:"extraction_schema,omitempty" gorm:"serializer:json"`
+	Analysis         AnalysisSettings       `json:"analysis" gorm:"serializer:json"`
+
+	// CallerMemory gives the agent a summary of its previous calls with the same client number
+	CallerMemory      bool `json:"caller_memory"`
+	CallerMemoryCalls int  `json:"caller_memory_calls,omitempty"`
+
+	IdlePolicy IdlePolicy `json:"idle_policy" gorm:"serializer:json"`
+	BargeIn    BargeInSettings `json:"barge_in" gorm:"serializer:json"`
+
+	// HandoffMessage is what the agent says when a call is handed off to it, defaults to the initial message
+	HandoffMessage string `json:"handoff_message,omitempty"`
+
+	// Routing decides how inbound calls to the agent's number are answered
+	Routing *InboundRouting `json:"routing,omitempty" gorm:"serializer:json"`
+
 	AreaCode       string `json:"-" gorm:"-"`
 }
 
@@ -34,6 +65,96 @@ type Action struct {
 	Name string `json:"name"`
 	Instructions string `json:"instructions"`
 	ForwardingNumber string `json:"forwarding_number,omitempty"`
+
+	// AgentIds are the agents a handoff action can pass the call to
+	AgentIds []uint `json:"agent_ids,omitempty"`
+}
+
+// VoicemailSettings turns on taking a message when the agent can't answer
+type VoicemailSettings struct {
+	// Greeting is spoken in the agent's voice before recording
+	Greeting         string `json:"greeting,omitempty"`
+	MaxLengthSeconds int    `json:"max_length_seconds,omitempty"`
+}
+
+// IdlePolicy decides what happens when the caller goes quiet and how long calls can last
+type IdlePolicy struct {
+	// ReminderAfterSeconds is how long the caller can be silent before the agent checks in, zero turns it off
+	ReminderAfterSeconds int `json:"reminder_after_seconds,omitempty"`
+
+	// ReminderMessage is said when checking in, empty has the LLM write one
+	ReminderMessage string `json:"reminder_message,omitempty"`
+
+	// MaxReminders go unanswered before the agent says goodbye and hangs up, zero means 2
+	MaxReminders   int    `json:"max_reminders,omitempty"`
+	GoodbyeMessage string `json:"goodbye_message,omitempty"`
+
+	// MaxCallSeconds ends calls that run longer, zero means no limit
+	MaxCallSeconds int    `json:"max_call_seconds,omitempty"`
+	MaxCallMessage string `json:"max_call_message,omitempty"`
+}
+
+// Barge-in detection
+const (
+	BargeInDetectionVAD        = "vad"
+	BargeInDetectionTranscript = "transcript"
+)
+
+// Endpointers decide whether the caller has finished their turn
+const (
+	// EndpointerLLM asks an LLM, the default
+	EndpointerLLM = "llm"
+
+	// EndpointerClassifier uses a local classifier trained on logged decisions
+	EndpointerClassifier = "classifier"
+
+	// EndpointerSilence responds as soon as the transcriber's endpointing silence has passed
+	EndpointerSilence = "silence"
+)
+
+// Barge-in modes
+const (
+	BargeInModeSmart  = "smart"
+	BargeInModeAlways = "always"
+	BargeInModeNever  = "never"
+)
+
+// BargeInSettings tune when the caller talking over the agent cuts it off
+type BargeInSettings struct {
+	// Mode is smart to let acknowledgements like "mm-hmm" through without cutting the agent off, the default,
+	// always to stop the agent whenever the caller talks over it, or never to let the agent finish speaking
+	Mode string `json:"mode,omitempty"`
+
+	// Detection is vad to detect speech locally from the call audio, the default, or transcript to wait
+	// for interim transcripts
+	Detection string `json:"detection,omitempty"`
+
+	// MinSpeechMs of continuous speech interrupts the agent so short sounds like "uh-huh" don't, defaults to 400
+	MinSpeechMs int `json:"min_speech_ms,omitempty"`
+
+	// EnergyThreshold is the RMS level below which audio isn't counted as speech, filtering out quiet
+	// background voices, defaults to 300 out of 32767
+	EnergyThreshold int `json:"energy_threshold,omitempty"`
+
+	// VADMode is the VAD's aggressiveness in filtering out non-speech, from 0 to 3, defaults to 3
+	VADMode *int `json:"vad_mode,omitempty"`
+}
+
+// PromptVariable declares a {{name}} placeholder used in the system prompt or initial message
+type PromptVariable struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     string `json:"default,omitempty"`
+	Required    bool   `json:"required"`
+}
+
+// AnalysisSettings configures the post-call analysis run when a call ends
+type AnalysisSettings struct {
+	Model               string   `json:"model,omitempty"`
+	Summary             bool     `json:"summary"`
+	SummaryInstructions string   `json:"summary_instructions,omitempty"`
+	Dispositions        []string `json:"dispositions,omitempty"`
+	ActionItems         bool     `json:"action_items"`
 }
 
 type ComplianceCheck struct {
@@ -8,19 +8,86 @@ import (
 type Call struct {
 	BaseModel
 	AgentId        uint                           `json:"agent_id" gorm:"index"`
+	Type           string                         `json:"type,omitempty" gorm:"index"`
 	TimeSeconds    float64                        `json:"time_seconds"`
 	UserSpeaksFirst bool                          `json:"user_speaks_first"`
 	AverageLatency float64                        `json:"average_latency_ms"`
+	SpeculationHitRate float64                    `json:"speculation_hit_rate"`
+	TurnLatencies  []TurnLatency                  `json:"turn_latencies" gorm:"serializer:json"`
 	Transcript     []openai.ChatCompletionMessage `json:"transcript" gorm:"serializer:json"`
 	Context        string                         `json:"context"`
+	Variables      map[string]string              `json:"variables" gorm:"serializer:json"`
 	Sid            string                         `json:"twilio_sid" gorm:"index"`
 	RecordingSid   string                         `json:"recording_sid"`
+	RecordingPath  string                         `json:"-"`
 	ClientNumber   string                         `json:"client_number" gorm:"index"`
+	Status         string                         `json:"status"`
+	AnsweredBy     string                         `json:"answered_by,omitempty"`
+	CampaignId        uint                        `json:"campaign_id,omitempty" gorm:"index"`
+	CampaignContactId uint                        `json:"campaign_contact_id,omitempty"`
+	RoutingRule    string                         `json:"routing_rule,omitempty"`
 	Sentiment      uint                           `json:"sentiment"`
+	SentimentTimeline []UtteranceSentiment        `json:"sentiment_timeline" gorm:"serializer:json"`
+	AnalysisStatus string                         `json:"analysis_status"`
+	ExtractedData  map[string]interface{}         `json:"extracted_data" gorm:"serializer:json;type:jsonb"`
+	Summary        string                         `json:"summary"`
+	Disposition    string                         `json:"disposition" gorm:"index"`
+	ActionItems    []string                       `json:"action_items" gorm:"serializer:json"`
 	InProgress     bool                           `json:"in_progress"`
+	Retrievals     []Retrieval                    `json:"retrievals" gorm:"serializer:json"`
+	CurrentNode     string                        `json:"current_node,omitempty"`
+	NodeTransitions []NodeTransition              `json:"node_transitions" gorm:"serializer:json"`
+	Handoffs        []AgentHandoff                `json:"handoffs" gorm:"serializer:json"`
+	Interruptions   int                           `json:"interruptions"`
 
 	StartedAt time.Time  `json:"started_at"`
 	EndedAt   time.Time  `json:"ended_at"`
 
 	DisconnectReason string `json:"disconnect_reason"`
-}
\ No newline at end of file
+}
+
+// AgentHandoff records the call being passed from one agent to another
+type AgentHandoff struct {
+	FromAgentId uint      `json:"from_agent_id"`
+	ToAgentId   uint      `json:"to_agent_id"`
+	Reason      string    `json:"reason,omitempty"`
+	At          time.Time `json:"at"`
+}
+
+// TurnLatency breaks down how long the agent took to start responding, stages that didn't run are zero
+type TurnLatency struct {
+	At time.Time `json:"at"`
+
+	// STTFinalizationMs is from the end of the caller's speech to the final transcript
+	STTFinalizationMs float64 `json:"stt_finalization_ms"`
+
+	// EndpointingMs is how long the endpointer took to decide
+	EndpointingMs float64 `json:"endpointing_ms"`
+
+	LLMFirstTokenMs float64 `json:"llm_first_token_ms"`
+	LLMTotalMs      float64 `json:"llm_total_ms"`
+
+	// TTSFirstByteMs is from sending text to the voice provider to its first audio
+	TTSFirstByteMs float64 `json:"tts_first_byte_ms"`
+
+	// NetworkWriteMs is how long writing the first audio to Twilio took
+	NetworkWriteMs float64 `json:"network_write_ms"`
+
+	// TotalMs is from the final transcript to the first audio written to Twilio
+	TotalMs float64 `json:"total_ms"`
+}
+
+// UtteranceSentiment is the sentiment of a single user message in the transcript
+type UtteranceSentiment struct {
+	TranscriptIndex int  `json:"transcript_index"`
+	Sentiment       uint `json:"sentiment"`
+}
+
+// CallTypeVoicemail marks a message recorded by the caller, calls with the agent have no type
+const CallTypeVoicemail = "voicemail"
+
+const (
+	AnalysisStatusPending   = "pending"
+	AnalysisStatusCompleted = "completed"
+	AnalysisStatusFailed    = "failed"
+)
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// CallOwner records which instance is running a live call, so commands for the call reach its orchestrator
+type CallOwner struct {
+	CallId     uint   `json:"call_id" gorm:"primarykey;autoIncrement:false"`
+	InstanceId string `json:"instance_id" gorm:"index"`
+
+	// Address is the instance's internal URL that commands are forwarded to
+	Address string `json:"address"`
+
+	// HeartbeatAt is refreshed while the instance is alive, owners that stop heartbeating are ignored
+	HeartbeatAt time.Time `json:"heartbeat_at" gorm:"index"`
+	CreatedAt   time.Time `json:"created_at"`
+}
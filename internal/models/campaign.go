@@ -0,0 +1,189 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	CampaignStatusDraft     = "draft"
+	CampaignStatusRunning   = "running"
+	CampaignStatusPaused    = "paused"
+	CampaignStatusCancelled = "cancelled"
+	CampaignStatusCompleted = "completed"
+)
+
+const (
+	ContactStatusPending   = "pending"
+	ContactStatusCalling   = "calling"
+	ContactStatusCompleted = "completed"
+	ContactStatusFailed    = "failed"
+	ContactStatusCancelled = "cancelled"
+)
+
+// Call outcomes recorded on campaign contacts
+const (
+	OutcomeAnswered  = "answered"
+	OutcomeNoAnswer  = "no_answer"
+	OutcomeBusy      = "busy"
+	OutcomeVoicemail = "voicemail"
+	OutcomeFailed    = "failed"
+	OutcomeDoNotCall = "do_not_call"
+)
+
+// Campaign places calls from an agent to a list of contacts
+type Campaign struct {
+	BaseModel
+	UserId             uint          `json:"user_id" gorm:"index"`
+	AgentId            uint          `json:"agent_id" gorm:"index"`
+	Name               string        `json:"name"`
+	Status             string        `json:"status" gorm:"index"`
+	MaxConcurrentCalls int           `json:"max_concurrent_calls"`
+	Timezone           string        `json:"timezone"`
+	CallingWindow      CallingWindow `json:"calling_window" gorm:"serializer:json"`
+	Retry              RetryPolicy   `json:"retry" gorm:"serializer:json"`
+
+	// DialerJobId is the job currently dialing for the campaign, older dialer jobs stop when they see it changed
+	DialerJobId uint `json:"-"`
+}
+
+// CallingWindow restricts calls to certain hours of certain days in the contact's timezone
+type CallingWindow struct {
+	// Start and End are times of day in 24 hour HH:MM format
+	Start string `json:"start"`
+	End   string `json:"end"`
+
+	// Days are lowercase three letter weekdays, e.g. mon, empty means every day
+	Days []string `json:"days,omitempty"`
+}
+
+// RetryPolicy decides which unsuccessful calls are tried again
+type RetryPolicy struct {
+	// MaxAttempts is the total number of calls placed to a contact, including the first
+	MaxAttempts  int      `json:"max_attempts"`
+	DelayMinutes int      `json:"delay_minutes"`
+	RetryOn      []string `json:"retry_on"`
+}
+
+// ShouldRetry reports whether a contact with the given outcome and attempts should be called again
+func (p RetryPolicy) ShouldRetry(outcome string, attempts int) bool {
+	if attempts >= p.MaxAttempts {
+		return false
+	}
+	for _, retryOn := range p.RetryOn {
+		if retryOn == outcome {
+			return true
+		}
+	}
+	return false
+}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a HH:MM time", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// atTimeOfDay returns the wall clock time of day on day. Adding the duration to midnight would be off by
+// an hour on days the clocks change.
+func atTimeOfDay(day time.Time, timeOfDay time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), int(timeOfDay/time.Hour), int(timeOfDay%time.Hour/time.Minute), 0, 0, day.Location())
+}
+
+// Validate checks that the window's times and days are well formed
+func (w CallingWindow) Validate() error {
+	if w.Start == "" && w.End == "" {
+		return nil
+	}
+	start, err := parseTimeOfDay(w.Start)
+	if err != nil {
+		return fmt.Errorf("calling window start %v", err)
+	}
+	end, err := parseTimeOfDay(w.End)
+	if err != nil {
+		return fmt.Errorf("calling window end %v", err)
+	}
+	if end <= start {
+		return fmt.Errorf("calling window must end after it starts")
+	}
+	for _, day := range w.Days {
+		if !w.validDay(day) {
+			return fmt.Errorf("calling window day %q must be one of %s", day, strings.Join(weekdays, ", "))
+		}
+	}
+	return nil
+}
+
+func (w CallingWindow) validDay(day string) bool {
+	for _, weekday := range weekdays {
+		if day == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func (w CallingWindow) allowsDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, allowed := range w.Days {
+		if allowed == weekdays[day] {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns t if the window is open at t in loc, otherwise the time the window next opens.
+// A window without times is always open.
+func (w CallingWindow) NextOpen(t time.Time, loc *time.Location) time.Time {
+	start, err := parseTimeOfDay(w.Start)
+	if err != nil {
+		return t
+	}
+	end, err := parseTimeOfDay(w.End)
+	if err != nil {
+		return t
+	}
+
+	local := t.In(loc)
+	for i := 0; i < 8; i++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+i, 0, 0, 0, 0, loc)
+		if !w.allowsDay(day.Weekday()) {
+			continue
+		}
+		opens := atTimeOfDay(day, start)
+		closes := atTimeOfDay(day, end)
+		if local.Before(opens) {
+			return opens
+		}
+		if local.Before(closes) {
+			return t
+		}
+	}
+
+	// Valid windows allow at least one weekday so this is only reached for invalid days
+	return t
+}
+
+// CampaignContact is one recipient of a campaign along with the outcome of calling them
+type CampaignContact struct {
+	BaseModel
+	CampaignId    uint              `json:"campaign_id" gorm:"index"`
+	PhoneNumber   string            `json:"phone_number"`
+	Timezone      string            `json:"timezone,omitempty"`
+	Variables     map[string]string `json:"variables" gorm:"serializer:json"`
+	Status        string            `json:"status" gorm:"index"`
+	Attempts      int               `json:"attempts"`
+	NextAttemptAt time.Time         `json:"next_attempt_at" gorm:"index"`
+	Outcome       string            `json:"outcome,omitempty"`
+	LastError     string            `json:"last_error,omitempty"`
+	CallIds       []uint            `json:"call_ids" gorm:"serializer:json"`
+	LastCallId    uint              `json:"last_call_id" gorm:"index"`
+}
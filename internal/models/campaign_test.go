@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCallingWindowNextOpen(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	daytime := CallingWindow{Start: "08:00", End: "21:00"}
+	weekdays := CallingWindow{Start: "09:00", End: "17:00", Days: []string{"mon", "tue", "wed", "thu", "fri"}}
+
+	tests := []struct {
+		name   string
+		window CallingWindow
+		at     time.Time
+		want   time.Time
+	}{
+		{"inside the window", daytime, time.Date(2026, 10, 14, 10, 0, 0, 0, newYork), time.Date(2026, 10, 14, 10, 0, 0, 0, newYork)},
+		{"before it opens", daytime, time.Date(2026, 10, 14, 6, 0, 0, 0, newYork), time.Date(2026, 10, 14, 8, 0, 0, 0, newYork)},
+		{"when it closes", daytime, time.Date(2026, 10, 14, 21, 0, 0, 0, newYork), time.Date(2026, 10, 15, 8, 0, 0, 0, newYork)},
+		{"after it closes", daytime, time.Date(2026, 10, 14, 23, 30, 0, 0, newYork), time.Date(2026, 10, 15, 8, 0, 0, 0, newYork)},
+		{"on a weekend", weekdays, time.Date(2026, 10, 17, 10, 0, 0, 0, newYork), time.Date(2026, 10, 19, 9, 0, 0, 0, newYork)},
+		{"friday evening", weekdays, time.Date(2026, 10, 16, 18, 0, 0, 0, newYork), time.Date(2026, 10, 19, 9, 0, 0, 0, newYork)},
+		{"clocks going forward", daytime, time.Date(2026, 3, 8, 3, 30, 0, 0, newYork), time.Date(2026, 3, 8, 8, 0, 0, 0, newYork)},
+		{"clocks going back", daytime, time.Date(2026, 11, 1, 7, 30, 0, 0, newYork), time.Date(2026, 11, 1, 8, 0, 0, 0, newYork)},
+		{"closing on the day clocks go back", daytime, time.Date(2026, 11, 1, 20, 59, 0, 0, newYork), time.Date(2026, 11, 1, 20, 59, 0, 0, newYork)},
+		{"without times", CallingWindow{}, time.Date(2026, 10, 14, 3, 0, 0, 0, newYork), time.Date(2026, 10, 14, 3, 0, 0, 0, newYork)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.NextOpen(tt.at, newYork); !got.Equal(tt.want) {
+				t.Errorf("NextOpen(%s) = %s, want %s", tt.at, got.In(newYork), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+const (
+	DoNotCallSourceAPI    = "api"
+	DoNotCallSourceImport = "import"
+	DoNotCallSourceAgent  = "agent"
+)
+
+// DoNotCallEntry is a phone number a user's agents must never call
+type DoNotCallEntry struct {
+	BaseModel
+	UserId      uint   `json:"user_id" gorm:"uniqueIndex:idx_do_not_call_user_number"`
+	PhoneNumber string `json:"phone_number" gorm:"uniqueIndex:idx_do_not_call_user_number"`
+	Source      string `json:"source"`
+	Reason      string `json:"reason,omitempty"`
+
+	// CallId is the call during which the caller opted out, for entries added by an agent
+	CallId uint `json:"call_id,omitempty"`
+}
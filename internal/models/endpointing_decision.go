@@ -0,0 +1,28 @@
+package models
+
+// EndpointingDecision is a logged smart endpointing decision, kept to train the endpointing classifier
+type EndpointingDecision struct {
+	BaseModel
+	CallId     uint   `json:"call_id" gorm:"index"`
+	AgentId    uint   `json:"agent_id" gorm:"index"`
+	Endpointer string `json:"endpointer"`
+
+	// Transcript is what the caller said since the agent last spoke
+	Transcript string `json:"transcript"`
+
+	// AssistantMessage is what the agent said before the caller's turn
+	AssistantMessage string `json:"assistant_message"`
+
+	Probability uint  `json:"probability"`
+	Threshold   uint  `json:"threshold"`
+	LatencyMs   int64 `json:"latency_ms"`
+
+	// Failed is set when the endpointer errored or timed out and the agent responded anyway
+	Failed bool `json:"failed"`
+
+	// WaitMs is how long the agent waited for the caller to keep talking, zero when it responded right away
+	WaitMs int64 `json:"wait_ms"`
+
+	// Continued is set when the caller kept talking during the wait, i.e. they hadn't finished their turn
+	Continued bool `json:"continued"`
+}
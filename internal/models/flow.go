@@ -0,0 +1,108 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Flow breaks a conversation into named steps, each with its own prompt, tools and transitions
+type Flow struct {
+	InitialNode string     `json:"initial_node"`
+	Nodes       []FlowNode `json:"nodes"`
+}
+
+type FlowNode struct {
+	Name        string           `json:"name"`
+	Prompt      string           `json:"prompt"`
+	Tools       []openai.Tool    `json:"tools,omitempty"`
+	Transitions []FlowTransition `json:"transitions,omitempty"`
+}
+
+// FlowTransition moves the conversation to the target node. Transitions with a condition are
+// decided by the LLM after each user turn, transitions with a tool call fire when the agent calls
+// that tool, optionally only when the call's arguments match.
+type FlowTransition struct {
+	Target    string            `json:"target"`
+	Condition string            `json:"condition,omitempty"`
+	ToolCall  string            `json:"tool_call,omitempty"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+// NodeTransition records the flow moving between nodes during a call
+type NodeTransition struct {
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Reason string    `json:"reason"`
+	At     time.Time `json:"at"`
+}
+
+// Node returns the node with the given name, or nil if there isn't one
+func (f *Flow) Node(name string) *FlowNode {
+	for i := range f.Nodes {
+		if f.Nodes[i].Name == name {
+			return &f.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// Start returns the name of the node conversations start in
+func (f *Flow) Start() string {
+	if f.InitialNode != "" {
+		return f.InitialNode
+	}
+	if len(f.Nodes) > 0 {
+		return f.Nodes[0].Name
+	}
+	return ""
+}
+
+// Validate checks that node names are unique, every transition points at an existing node and tool call
+// transitions name a tool the node has, its own or one of the agent's
+func (f *Flow) Validate(agentTools []openai.Tool) error {
+	if len(f.Nodes) == 0 {
+		return fmt.Errorf("flow must have at least one node")
+	}
+
+	names := map[string]bool{}
+	for _, node := range f.Nodes {
+		if node.Name == "" {
+			return fmt.Errorf("flow nodes must have a name")
+		}
+		if names[node.Name] {
+			return fmt.Errorf("flow node %q is defined more than once", node.Name)
+		}
+		names[node.Name] = true
+	}
+
+	if !names[f.Start()] {
+		return fmt.Errorf("flow initial node %q does not exist", f.InitialNode)
+	}
+
+	for _, node := range f.Nodes {
+		for _, transition := range node.Transitions {
+			if !names[transition.Target] {
+				return fmt.Errorf("flow node %q transitions to unknown node %q", node.Name, transition.Target)
+			}
+			if transition.Condition == "" && transition.ToolCall == "" {
+				return fmt.Errorf("flow node %q transition to %q needs a condition or tool_call", node.Name, transition.Target)
+			}
+			if transition.ToolCall != "" && !hasTool(node.Tools, transition.ToolCall) && !hasTool(agentTools, transition.ToolCall) {
+				return fmt.Errorf("flow node %q transitions on tool_call %q but has no tool with that name", node.Name, transition.ToolCall)
+			}
+		}
+	}
+
+	return nil
+}
+
+func hasTool(tools []openai.Tool, name string) bool {
+	for _, tool := range tools {
+		if tool.Function != nil && tool.Function.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+type Job struct {
+	BaseModel
+	UserId      uint                   `json:"user_id" gorm:"index"`
+	CallId      uint                   `json:"call_id" gorm:"index"`
+	Type        string                 `json:"type" gorm:"index"`
+	Payload     map[string]interface{} `json:"payload" gorm:"serializer:json"`
+	Status      string                 `json:"status" gorm:"index"`
+	Attempts    int                    `json:"attempts"`
+	MaxAttempts int                    `json:"max_attempts"`
+	RunAt       time.Time              `json:"run_at" gorm:"index"`
+	LockedAt    *time.Time             `json:"locked_at"`
+	CompletedAt *time.Time             `json:"completed_at"`
+	LastError   string                 `json:"last_error"`
+}
@@ -0,0 +1,34 @@
+package models
+
+type KnowledgeBase struct {
+	BaseModel
+	UserId      uint   `json:"user_id" gorm:"index"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type Document struct {
+	BaseModel
+	UserId          uint   `json:"user_id" gorm:"index"`
+	KnowledgeBaseId uint   `json:"knowledge_base_id" gorm:"index"`
+	Name            string `json:"name"`
+	ContentType     string `json:"content_type"`
+	Characters      int    `json:"characters"`
+	NumChunks       int    `json:"num_chunks"`
+}
+
+type DocumentChunk struct {
+	BaseModel
+	KnowledgeBaseId uint      `json:"knowledge_base_id" gorm:"index"`
+	DocumentId      uint      `json:"document_id" gorm:"index"`
+	Position        int       `json:"position"`
+	Content         string    `json:"content"`
+	Embedding       []float32 `json:"-" gorm:"serializer:json"`
+}
+
+// Retrieval records which knowledge base chunks were injected into the prompt for a user turn
+type Retrieval struct {
+	TranscriptIndex int    `json:"transcript_index"`
+	Query           string `json:"query"`
+	ChunkIds        []uint `json:"chunk_ids"`
+}
@@ -0,0 +1,159 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Routing targets
+const (
+	RouteToAgent     = "agent"
+	RouteToForward   = "forward"
+	RouteToVoicemail = "voicemail"
+	RouteToReject    = "reject"
+)
+
+// When a routing rule applies
+const (
+	RouteWhenAlways  = "always"
+	RouteWhenOpen    = "open"
+	RouteWhenClosed  = "closed"
+	RouteWhenHoliday = "holiday"
+)
+
+// InboundRouting decides how calls to the agent's number are answered. Rules are evaluated in order and the
+// first match wins, calls that match no rule are answered by the agent.
+type InboundRouting struct {
+	// Timezone the business hours and holidays are in, defaults to the agent's timezone
+	Timezone string `json:"timezone,omitempty"`
+
+	// BusinessHours are the windows the business is open, empty means always open
+	BusinessHours []CallingWindow `json:"business_hours,omitempty"`
+
+	// Holidays are days the business is closed, either YYYY-MM-DD or MM-DD to repeat every year
+	Holidays []string `json:"holidays,omitempty"`
+
+	Rules []RoutingRule `json:"rules"`
+}
+
+type RoutingRule struct {
+	Name string `json:"name"`
+
+	// When limits the rule to business hours (open), outside of them including holidays (closed) or to
+	// holidays, empty means always
+	When string `json:"when,omitempty"`
+
+	// Callers limits the rule to these caller numbers, a trailing * matches every number with that prefix
+	Callers []string `json:"callers,omitempty"`
+
+	Target RoutingTarget `json:"target"`
+}
+
+type RoutingTarget struct {
+	Type string `json:"type"`
+
+	// AgentId sends the call to another of the user's agents, zero means the agent that owns the number
+	AgentId uint `json:"agent_id,omitempty"`
+
+	ForwardingNumber string `json:"forwarding_number,omitempty"`
+
+	// Greeting is played before a voicemail is recorded
+	Greeting string `json:"greeting,omitempty"`
+}
+
+// Validate checks the timezone, hours, holidays and that every rule has a usable target
+func (r *InboundRouting) Validate() error {
+	if r.Timezone != "" {
+		if _, err := time.LoadLocation(r.Timezone); err != nil {
+			return fmt.Errorf("routing timezone %q is not a valid IANA timezone", r.Timezone)
+		}
+	}
+	for _, window := range r.BusinessHours {
+		if window.Start == "" && window.End == "" {
+			return fmt.Errorf("routing business hours must have a start and end")
+		}
+		if err := window.Validate(); err != nil {
+			return fmt.Errorf("routing business hours: %v", err)
+		}
+	}
+	for _, holiday := range r.Holidays {
+		if _, _, ok := parseHoliday(holiday); !ok {
+			return fmt.Errorf("routing holiday %q must be a YYYY-MM-DD or MM-DD date", holiday)
+		}
+	}
+
+	for i, rule := range r.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("%d", i+1)
+		}
+
+		switch rule.When {
+		case "", RouteWhenAlways, RouteWhenOpen, RouteWhenClosed, RouteWhenHoliday:
+		default:
+			return fmt.Errorf("routing rule %s: when must be always, open, closed or holiday", name)
+		}
+
+		for _, caller := range rule.Callers {
+			if !strings.HasPrefix(caller, "+") || len(strings.TrimSuffix(caller, "*")) < 2 {
+				return fmt.Errorf("routing rule %s: caller %q must be an E.164 number, optionally ending in *", name, caller)
+			}
+		}
+
+		switch rule.Target.Type {
+		case RouteToAgent, RouteToVoicemail, RouteToReject:
+		case RouteToForward:
+			if rule.Target.ForwardingNumber == "" {
+				return fmt.Errorf("routing rule %s: forward targets need a forwarding_number", name)
+			}
+		default:
+			return fmt.Errorf("routing rule %s: target type must be agent, forward, voicemail or reject", name)
+		}
+	}
+	return nil
+}
+
+// IsHoliday reports whether the day of t in loc is one of the holidays
+func (r *InboundRouting) IsHoliday(t time.Time, loc *time.Location) bool {
+	t = t.In(loc)
+	for _, holiday := range r.Holidays {
+		month, day, ok := parseHoliday(holiday)
+		if !ok || month != t.Month() || day != t.Day() {
+			continue
+		}
+		if len(holiday) == len("2006-01-02") && holiday[:4] != t.Format("2006") {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// IsOpen reports whether t is inside business hours in loc and not on a holiday
+func (r *InboundRouting) IsOpen(t time.Time, loc *time.Location) bool {
+	if r.IsHoliday(t, loc) {
+		return false
+	}
+	if len(r.BusinessHours) == 0 {
+		return true
+	}
+	for _, window := range r.BusinessHours {
+		if window.NextOpen(t, loc).Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseHoliday(value string) (time.Month, int, bool) {
+	for _, layout := range []string{"2006-01-02", "01-02"} {
+		if len(value) != len(layout) {
+			continue
+		}
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Month(), t.Day(), true
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInboundRoutingIsOpen(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	splitShift := &InboundRouting{
+		BusinessHours: []CallingWindow{
+			{Start: "09:00", End: "12:00", Days: []string{"mon", "tue", "wed", "thu", "fri"}},
+			{Start: "13:00", End: "17:00", Days: []string{"mon", "tue", "wed", "thu", "fri"}},
+			{Start: "10:00", End: "14:00", Days: []string{"sat"}},
+		},
+		Holidays: []string{"12-25", "2026-11-26"},
+	}
+	alwaysOpen := &InboundRouting{Holidays: []string{"01-01"}}
+
+	tests := []struct {
+		name    string
+		routing *InboundRouting
+		at      time.Time
+		want    bool
+	}{
+		{"morning shift", splitShift, time.Date(2026, 10, 14, 9, 0, 0, 0, newYork), true},
+		{"lunch break", splitShift, time.Date(2026, 10, 14, 12, 30, 0, 0, newYork), false},
+		{"afternoon shift", splitShift, time.Date(2026, 10, 14, 16, 59, 0, 0, newYork), true},
+		{"closing time", splitShift, time.Date(2026, 10, 14, 17, 0, 0, 0, newYork), false},
+		{"saturday hours", splitShift, time.Date(2026, 10, 17, 11, 0, 0, 0, newYork), true},
+		{"sunday", splitShift, time.Date(2026, 10, 18, 11, 0, 0, 0, newYork), false},
+		{"yearly holiday", splitShift, time.Date(2026, 12, 25, 10, 0, 0, 0, newYork), false},
+		{"dated holiday", splitShift, time.Date(2026, 11, 26, 10, 0, 0, 0, newYork), false},
+		{"dated holiday in another year", splitShift, time.Date(2027, 11, 26, 10, 0, 0, 0, newYork), true},
+		{"opening on the day clocks go forward", splitShift, time.Date(2026, 3, 9, 9, 0, 0, 0, newYork), true},
+		{"before opening on the day clocks go back", splitShift, time.Date(2026, 11, 2, 8, 30, 0, 0, newYork), false},
+		{"holiday is in the routing timezone", splitShift, time.Date(2026, 12, 26, 3, 0, 0, 0, time.UTC), false},
+		{"no business hours", alwaysOpen, time.Date(2026, 10, 18, 3, 0, 0, 0, newYork), true},
+		{"no business hours on a holiday", alwaysOpen, time.Date(2027, 1, 1, 12, 0, 0, 0, newYork), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.routing.IsOpen(tt.at, newYork); got != tt.want {
+				t.Errorf("IsOpen(%s) = %v, want %v", tt.at.In(newYork), got, tt.want)
+			}
+		})
+	}
+}
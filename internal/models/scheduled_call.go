@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+const (
+	ScheduledCallStatusScheduled = "scheduled"
+	ScheduledCallStatusPlaced    = "placed"
+	ScheduledCallStatusFailed    = "failed"
+	ScheduledCallStatusCancelled = "cancelled"
+)
+
+const (
+	ScheduledCallSourceAPI      = "api"
+	ScheduledCallSourceCallback = "callback"
+)
+
+// ScheduledCall is an outbound call to be placed at a later time, either requested through the API or
+// booked by an agent when a caller asks to be called back
+type ScheduledCall struct {
+	BaseModel
+	UserId          uint              `json:"user_id" gorm:"index"`
+	AgentId         uint              `json:"agent_id" gorm:"index"`
+	To              string            `json:"to"`
+	Context         string            `json:"context"`
+	Variables       map[string]string `json:"variables" gorm:"serializer:json"`
+	UserSpeaksFirst bool              `json:"user_speaks_first"`
+	Timezone        string            `json:"timezone,omitempty"`
+	ScheduledAt     time.Time         `json:"scheduled_at" gorm:"index"`
+	Status          string            `json:"status" gorm:"index"`
+	Source          string            `json:"source"`
+
+	// SourceCallId is the call during which a callback was booked
+	SourceCallId uint `json:"source_call_id,omitempty" gorm:"index"`
+
+	// CallId is the call placed once the scheduled time came
+	CallId    uint   `json:"call_id,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+}
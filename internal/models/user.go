@@ -6,11 +6,13 @@ type User struct {
 	HashedPassword string `json:"-"`
 	Password       string `json:"-" gorm:"-"`
 
-	StripeCustomerID string `json"-"`
+	StripeCustomerID string `json:"-"`
 
 	Plan string `json:"plan"`
 	Details PlanDetails `json:"details" gorm:"serializer:json"`
 
+	// CallingHours limits outbound calls to these hours in the recipient's timezone, unset means 08:00-21:00
+	CallingHours CallingWindow `json:"calling_hours" gorm:"serializer:json"`
 }
 
 type PlanDetails struct {
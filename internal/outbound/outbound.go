@@ -0,0 +1,229 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/compliance"
+	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/jobs"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/prompts"
+	"github.com/twilio/twilio-go"
+	openapi "github.com/twilio/twilio-go/rest/api/v2010"
+	"gorm.io/gorm"
+)
+
+const PlaceCallJobType = "place_call"
+
+// Request describes an outbound call from an agent's phone number
+type Request struct {
+	To              string            `json:"to"`
+	Context         string            `json:"context"`
+	Variables       map[string]string `json:"variables"`
+	UserSpeaksFirst bool              `json:"user_speaks_first"`
+
+	// Timezone is the recipient's timezone, used to enforce calling hours
+	Timezone string `json:"timezone,omitempty"`
+
+	// MachineDetection asks Twilio to detect answering machines, the result is stored in the call's AnsweredBy
+	MachineDetection bool `json:"machine_detection,omitempty"`
+
+	CampaignId        uint `json:"campaign_id,omitempty"`
+	CampaignContactId uint `json:"campaign_contact_id,omitempty"`
+}
+
+type placeCallPayload struct {
+	ScheduledCallId uint `json:"scheduled_call_id"`
+}
+
+// Validate checks that the agent's prompts can be rendered for the request
+func Validate(agent *models.Agent, req Request) error {
+	_, err := prompts.Resolve(agent, &models.Call{ClientNumber: req.To, Variables: req.Variables}, time.Now())
+	return err
+}
+
+// CheckCompliance returns a *compliance.DoNotCallError or *compliance.CallingHoursError when the agent's
+// user may not place the call right now
+func CheckCompliance(db *gorm.DB, agent *models.Agent, req Request) error {
+	var user models.User
+	if err := db.First(&user, agent.UserId).Error; err != nil {
+		return fmt.Errorf("error loading user: %w", err)
+	}
+	return compliance.Check(db, &user, compliance.Recipient{
+		PhoneNumber: req.To,
+		Timezone:    req.Timezone,
+		Fallback:    prompts.Location(agent),
+	}, time.Now())
+}
+
+// Place dials the request's number through Twilio and records the call. When the call was dialed but
+// couldn't be recorded the call is returned along with the error so callers don't dial again.
+// Calls to numbers on the do not call list or outside of calling hours are refused.
+func Place(cfg *config.Config, db *gorm.DB, agent *models.Agent, req Request) (*models.Call, error) {
+	to, ok := compliance.NormalizePhoneNumber(req.To)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a valid phone number", req.To)
+	}
+	req.To = to
+
+	// Make sure every variable the agent's prompts need is present before dialing
+	if err := Validate(agent, req); err != nil {
+		return nil, err
+	}
+	if err := CheckCompliance(db, agent, req); err != nil {
+		return nil, err
+	}
+
+	client := twilio.NewRestClientWithParams(twilio.ClientParams{
+		Username: cfg.TwilioAccountSid,
+		Password: cfg.TwilioAccountAuthToken,
+	})
+
+	params := &openapi.CreateCallParams{}
+	params.SetTo(req.To)
+	params.SetFrom(agent.PhoneNumber)
+	params.SetUrl(cfg.TwilioMLUrl)
+	if cfg.TwilioStatusCallbackUrl != "" {
+		params.SetStatusCallback(cfg.TwilioStatusCallbackUrl)
+		params.SetStatusCallbackEvent([]string{"completed"})
+	}
+	if req.MachineDetection {
+		params.SetMachineDetection("Enable")
+	}
+	resp, err := client.Api.CreateCall(params)
+	if err != nil {
+		return nil, fmt.Errorf("error creating twilio call: %w", err)
+	}
+
+	call := &models.Call{
+		AgentId:           agent.ID,
+		Context:           req.Context,
+		Variables:         req.Variables,
+		Sid:               *resp.Sid,
+		StartedAt:         time.Now(),
+		UserSpeaksFirst:   req.UserSpeaksFirst,
+		CampaignId:        req.CampaignId,
+		CampaignContactId: req.CampaignContactId,
+	}
+	if err := db.Create(call).Error; err != nil {
+		return call, fmt.Errorf("error saving call: %w", err)
+	}
+
+	return call, nil
+}
+
+// Schedule saves the scheduled call and queues it to be placed at its scheduled time
+func Schedule(db *gorm.DB, scheduled *models.ScheduledCall) error {
+	to, ok := compliance.NormalizePhoneNumber(scheduled.To)
+	if !ok {
+		return fmt.Errorf("%q is not a valid phone number", scheduled.To)
+	}
+	scheduled.To = to
+	scheduled.Status = models.ScheduledCallStatusScheduled
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(scheduled).Error; err != nil {
+			return err
+		}
+		_, err := jobs.Enqueue(tx, PlaceCallJobType, placeCallPayload{ScheduledCallId: scheduled.ID},
+			jobs.RunAt(scheduled.ScheduledAt),
+			jobs.ForUser(scheduled.UserId),
+		)
+		return err
+	})
+}
+
+// NewPlaceCallJobHandler returns the job handler that places scheduled calls
+func NewPlaceCallJobHandler(cfg *config.Config, db *gorm.DB) jobs.Handler {
+	return func(ctx context.Context, job *models.Job) error {
+		var payload placeCallPayload
+		if err := jobs.DecodePayload(job, &payload); err != nil {
+			return jobs.Permanent(err)
+		}
+
+		var scheduled models.ScheduledCall
+		if err := db.First(&scheduled, payload.ScheduledCallId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		// Cancelled, or already placed by an earlier attempt
+		if scheduled.Status != models.ScheduledCallStatusScheduled {
+			return nil
+		}
+
+		var agent models.Agent
+		if err := db.First(&agent, scheduled.AgentId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.S.Warnf("agent %d was deleted before scheduled call %d was placed", scheduled.AgentId, scheduled.ID)
+				return failScheduledCall(db, &scheduled, errors.New("agent was deleted"))
+			}
+			return err
+		}
+
+		call, err := Place(cfg, db, &agent, Request{
+			To:              scheduled.To,
+			Context:         scheduled.Context,
+			Variables:       scheduled.Variables,
+			UserSpeaksFirst: scheduled.UserSpeaksFirst,
+			Timezone:        scheduled.Timezone,
+		})
+		if call == nil {
+			// Move the call to the next time calling hours allow
+			var hours *compliance.CallingHoursError
+			if errors.As(err, &hours) {
+				return reschedule(db, &scheduled, hours.NextAllowed)
+			}
+
+			var missing *prompts.MissingVariablesError
+			var dnc *compliance.DoNotCallError
+			if errors.As(err, &missing) || errors.As(err, &dnc) || jobs.IsFinalAttempt(job) {
+				if err := failScheduledCall(db, &scheduled, err); err != nil {
+					return err
+				}
+				return jobs.Permanent(err)
+			}
+			return err
+		}
+
+		// The call was dialed even if it couldn't be saved, retrying would call the recipient twice
+		job.CallId = call.ID
+		scheduled.Status = models.ScheduledCallStatusPlaced
+		scheduled.CallId = call.ID
+		scheduled.LastError = ""
+		if err != nil {
+			scheduled.LastError = err.Error()
+		}
+		if saveErr := db.Save(&scheduled).Error; saveErr != nil {
+			return jobs.Permanent(saveErr)
+		}
+		if err != nil {
+			return jobs.Permanent(err)
+		}
+		return nil
+	}
+}
+
+func reschedule(db *gorm.DB, scheduled *models.ScheduledCall, at time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(scheduled).Update("scheduled_at", at).Error; err != nil {
+			return err
+		}
+		_, err := jobs.Enqueue(tx, PlaceCallJobType, placeCallPayload{ScheduledCallId: scheduled.ID},
+			jobs.RunAt(at),
+			jobs.ForUser(scheduled.UserId),
+		)
+		return err
+	})
+}
+
+func failScheduledCall(db *gorm.DB, scheduled *models.ScheduledCall, err error) error {
+	scheduled.Status = models.ScheduledCallStatusFailed
+	scheduled.LastError = err.Error()
+	return db.Save(scheduled).Error
+}
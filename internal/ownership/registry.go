@@ -0,0 +1,236 @@
+package ownership
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// Owners refresh their calls this often
+	heartbeatInterval = 15 * time.Second
+
+	// Owners that haven't heartbeated for this long are treated as gone
+	ownerTTL = 1 * time.Minute
+
+	forwardTimeout = 5 * time.Second
+)
+
+// Commands
+const (
+	CommandSetContext = "set_context"
+)
+
+// ErrNotLive is returned for commands to calls no instance is running
+var ErrNotLive = errors.New("call isn't live on any instance")
+
+// Command is an instruction for a live call's orchestrator
+type Command struct {
+	Type    string `json:"type"`
+	Context string `json:"context,omitempty"`
+}
+
+// Handler applies a command to a call this instance is running
+type Handler func(command Command) error
+
+// Registry maps live calls to the instances running them and routes commands to them
+type Registry struct {
+	db         *gorm.DB
+	instanceId string
+	address    string
+	token      string
+	client     *http.Client
+
+	mu    sync.Mutex
+	calls map[uint]Handler
+}
+
+func NewRegistry(cfg *config.Config, db *gorm.DB) *Registry {
+	hostname, _ := os.Hostname()
+
+	instanceId := cfg.InstanceId
+	if instanceId == "" {
+		instanceId = hostname
+	}
+	address := cfg.InstanceAddress
+	if address == "" {
+		address = fmt.Sprintf("http://%s:%s", hostname, cfg.Port)
+	}
+
+	return &Registry{
+		db:         db,
+		instanceId: instanceId,
+		address:    address,
+		token:      cfg.InternalToken,
+		client:     &http.Client{Timeout: forwardTimeout},
+		calls:      map[uint]Handler{},
+	}
+}
+
+// Claim records this instance as the call's owner, commands for the call are passed to handler
+func (r *Registry) Claim(callId uint, handler Handler) error {
+	r.mu.Lock()
+	r.calls[callId] = handler
+	r.mu.Unlock()
+
+	owner := models.CallOwner{
+		CallId:      callId,
+		InstanceId:  r.instanceId,
+		Address:     r.address,
+		HeartbeatAt: time.Now(),
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "call_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"instance_id", "address", "heartbeat_at"}),
+	}).Create(&owner).Error
+}
+
+// Release gives up ownership once the call is over
+func (r *Registry) Release(callId uint) error {
+	r.mu.Lock()
+	delete(r.calls, callId)
+	r.mu.Unlock()
+
+	return r.db.Where("call_id = ? AND instance_id = ?", callId, r.instanceId).Delete(&models.CallOwner{}).Error
+}
+
+// Run heartbeats the calls this instance owns until ctx is cancelled
+func (r *Registry) Run(ctx context.Context) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.heartbeat()
+		}
+	}
+}
+
+func (r *Registry) heartbeat() {
+	r.mu.Lock()
+	callIds := make([]uint, 0, len(r.calls))
+	for callId := range r.calls {
+		callIds = append(callIds, callId)
+	}
+	r.mu.Unlock()
+
+	if len(callIds) == 0 {
+		return
+	}
+	err := r.db.Model(&models.CallOwner{}).
+		Where("call_id IN ? AND instance_id = ?", callIds, r.instanceId).
+		Update("heartbeat_at", time.Now()).Error
+	if err != nil {
+		logger.S.Errorf("error heartbeating %d calls: %v", len(callIds), err)
+	}
+}
+
+// Dispatch sends the command to the call's orchestrator, on this instance or forwarded to the one running it
+func (r *Registry) Dispatch(ctx context.Context, callId uint, command Command) error {
+	if handler := r.local(callId); handler != nil {
+		return handler(command)
+	}
+
+	var owner models.CallOwner
+	err := r.db.Where("call_id = ? AND heartbeat_at > ?", callId, time.Now().Add(-ownerTTL)).First(&owner).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotLive
+	}
+	if err != nil {
+		return err
+	}
+	if owner.InstanceId == r.instanceId {
+		// The call ended here after the lookup
+		return ErrNotLive
+	}
+	return r.forward(ctx, &owner, command)
+}
+
+func (r *Registry) local(callId uint) Handler {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[callId]
+}
+
+func (r *Registry) forward(ctx context.Context, owner *models.CallOwner, command Command) error {
+	if r.token == "" {
+		return fmt.Errorf("call %d is running on instance %s and forwarding is off without an internal token", owner.CallId, owner.InstanceId)
+	}
+
+	body, err := json.Marshal(command)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/internal/calls/%d/commands", owner.Address, owner.CallId)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+r.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error forwarding command to instance %s: %w", owner.InstanceId, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotLive
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("instance %s returned status code %d for the command", owner.InstanceId, resp.StatusCode)
+	}
+	return nil
+}
+
+// HandleCommand applies a command forwarded by another instance to a call running on this one
+func (r *Registry) HandleCommand(w http.ResponseWriter, req *http.Request) {
+	token := []byte("Bearer " + r.token)
+	if r.token == "" || subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), token) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	callId, err := strconv.ParseUint(mux.Vars(req)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid call id", http.StatusBadRequest)
+		return
+	}
+
+	var command Command
+	if err := json.NewDecoder(req.Body).Decode(&command); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Forwarded commands are only applied here, never forwarded again
+	handler := r.local(uint(callId))
+	if handler == nil {
+		http.Error(w, "Call not found", http.StatusNotFound)
+		return
+	}
+	if err := handler(command); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,180 @@
+package prompts
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/models"
+)
+
+// placeholderPattern matches {{name}} style placeholders, allowing whitespace inside the braces
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*\}\}`)
+
+var variableNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Builtin variable names that are always available to templates
+const (
+	CurrentDate      = "current_date"
+	CurrentTime      = "current_time"
+	CurrentWeekday   = "current_weekday"
+	CurrentDateTime  = "current_datetime"
+	Timezone         = "timezone"
+	AgentName        = "agent_name"
+	AgentPhoneNumber = "agent_phone_number"
+	ClientNumber     = "client_number"
+)
+
+var builtins = map[string]struct{}{
+	CurrentDate:      {},
+	CurrentTime:      {},
+	CurrentWeekday:   {},
+	CurrentDateTime:  {},
+	Timezone:         {},
+	AgentName:        {},
+	AgentPhoneNumber: {},
+	ClientNumber:     {},
+}
+
+// MissingVariablesError is returned when a template references variables that have no value
+type MissingVariablesError struct {
+	Names []string
+}
+
+func (e *MissingVariablesError) Error() string {
+	return fmt.Sprintf("missing required variables: %s", strings.Join(e.Names, ", "))
+}
+
+// IsBuiltin reports whether name is a builtin variable
+func IsBuiltin(name string) bool {
+	_, ok := builtins[name]
+	return ok
+}
+
+// Placeholders returns the unique variable names referenced in the template, in order of appearance
+func Placeholders(template string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, match := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// Render replaces every placeholder in the template with its value, missing variables render as empty strings
+func Render(template string, vars map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+		return vars[name]
+	})
+}
+
+// Location returns the agent's configured timezone, falling back to UTC
+func Location(agent *models.Agent) *time.Location {
+	if agent.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(agent.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Builtins computes the builtin variables for a call at the given time
+func Builtins(agent *models.Agent, call *models.Call, now time.Time) map[string]string {
+	loc := Location(agent)
+	local := now.In(loc)
+
+	vars := map[string]string{
+		CurrentDate:      local.Format("Monday, January 2, 2006"),
+		CurrentTime:      local.Format("3:04 PM MST"),
+		CurrentWeekday:   local.Weekday().String(),
+		CurrentDateTime:  local.Format(time.RFC3339),
+		Timezone:         loc.String(),
+		AgentName:        agent.Name,
+		AgentPhoneNumber: agent.PhoneNumber,
+	}
+	if call != nil {
+		vars[ClientNumber] = call.ClientNumber
+	}
+	return vars
+}
+
+// Resolve merges agent defaults, the call's variables and the builtins into a single set of values.
+// Builtins can't be overridden by call variables. An error listing every missing variable is returned
+// when a required variable, or a variable referenced by the agent's prompts or flow nodes, has no value.
+func Resolve(agent *models.Agent, call *models.Call, now time.Time) (map[string]string, error) {
+	vars := map[string]string{}
+	for _, variable := range agent.Variables {
+		if variable.Default != "" {
+			vars[variable.Name] = variable.Default
+		}
+	}
+
+	var provided map[string]string
+	if call != nil {
+		provided = call.Variables
+	}
+	for name, value := range provided {
+		vars[name] = value
+	}
+
+	for name, value := range Builtins(agent, call, now) {
+		vars[name] = value
+	}
+
+	missing := map[string]bool{}
+	for _, variable := range agent.Variables {
+		if _, ok := provided[variable.Name]; variable.Required && !ok {
+			missing[variable.Name] = true
+		}
+	}
+	templates := []string{agent.SystemPrompt, agent.InitialMessage}
+	if agent.Flow != nil {
+		for _, node := range agent.Flow.Nodes {
+			templates = append(templates, node.Prompt)
+		}
+	}
+	for _, template := range templates {
+		for _, name := range Placeholders(template) {
+			if _, ok := vars[name]; !ok {
+				missing[name] = true
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return vars, &MissingVariablesError{Names: names}
+	}
+
+	return vars, nil
+}
+
+// ValidateVariables checks an agent's variable definitions
+func ValidateVariables(variables []models.PromptVariable) error {
+	seen := map[string]bool{}
+	for _, variable := range variables {
+		if !variableNamePattern.MatchString(variable.Name) {
+			return fmt.Errorf("invalid variable name %q, names may only contain letters, numbers and underscores", variable.Name)
+		}
+		if IsBuiltin(variable.Name) {
+			return fmt.Errorf("variable %q conflicts with a builtin variable", variable.Name)
+		}
+		if seen[variable.Name] {
+			return fmt.Errorf("variable %q is defined more than once", variable.Name)
+		}
+		seen[variable.Name] = true
+	}
+	return nil
+}
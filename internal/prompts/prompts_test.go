@@ -0,0 +1,141 @@
+package prompts
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/models"
+)
+
+func TestRender(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		template string
+		vars     map[string]string
+		want     string
+	}{
+		{name: "placeholders", template: "Hi {{name}}, it's {{ current_date }}", vars: map[string]string{"name": "Ann", "current_date": "Monday"}, want: "Hi Ann, it's Monday"},
+		{name: "repeated placeholder", template: "{{name}} and {{name}}", vars: map[string]string{"name": "Ann"}, want: "Ann and Ann"},
+		{name: "missing variable renders empty", template: "Hi {{name}}!", vars: map[string]string{}, want: "Hi !"},
+		{name: "values aren't rendered again", template: "Hi {{name}}", vars: map[string]string{"name": "{{secret}}", "secret": "s3cret"}, want: "Hi {{secret}}"},
+		{name: "values are literal", template: "Pay {{amount}}", vars: map[string]string{"amount": "$1 ${name} \\1"}, want: "Pay $1 ${name} \\1"},
+		{name: "invalid names are left alone", template: "{{1st}} {{first name}} { {name} } {name}", vars: map[string]string{"name": "Ann"}, want: "{{1st}} {{first name}} { {name} } {name}"},
+		{name: "triple braces keep the outer braces", template: "{{{name}}}", vars: map[string]string{"name": "Ann"}, want: "{Ann}"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.template, tt.vars); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	now := time.Date(2024, time.March, 4, 15, 30, 0, 0, time.UTC)
+
+	for _, tt := range []struct {
+		name        string
+		agent       models.Agent
+		call        *models.Call
+		want        map[string]string
+		wantMissing []string
+	}{
+		{
+			name: "defaults fill in variables the call doesn't have",
+			agent: models.Agent{
+				SystemPrompt: "Greet {{name}} about {{topic}}",
+				Variables:    []models.PromptVariable{{Name: "name", Default: "there"}, {Name: "topic", Default: "billing"}},
+			},
+			call: &models.Call{Variables: map[string]string{"topic": "shipping"}},
+			want: map[string]string{"name": "there", "topic": "shipping"},
+		},
+		{
+			name: "required variables have to come from the call",
+			agent: models.Agent{
+				SystemPrompt: "Hello",
+				Variables:    []models.PromptVariable{{Name: "order_id", Required: true, Default: "unused"}, {Name: "name", Required: true}},
+			},
+			call:        &models.Call{Variables: map[string]string{}},
+			wantMissing: []string{"name", "order_id"},
+		},
+		{
+			name: "an empty value counts as provided",
+			agent: models.Agent{
+				SystemPrompt: "Order {{order_id}}",
+				Variables:    []models.PromptVariable{{Name: "order_id", Required: true}},
+			},
+			call: &models.Call{Variables: map[string]string{"order_id": ""}},
+			want: map[string]string{"order_id": ""},
+		},
+		{
+			name: "placeholders without a value are missing",
+			agent: models.Agent{
+				SystemPrompt:   "Help {{name}}",
+				InitialMessage: "Calling about {{topic}}",
+				Flow:           &models.Flow{Nodes: []models.FlowNode{{Name: "start", Prompt: "Ask about {{plan}}"}}},
+			},
+			call:        &models.Call{Variables: map[string]string{"name": "Ann"}},
+			wantMissing: []string{"plan", "topic"},
+		},
+		{
+			name:  "builtins can't be overridden",
+			agent: models.Agent{Name: "Support", SystemPrompt: "You are {{agent_name}}, today is {{current_weekday}}"},
+			call:  &models.Call{ClientNumber: "+15550000001", Variables: map[string]string{"agent_name": "Mallory"}},
+			want:  map[string]string{AgentName: "Support", CurrentWeekday: "Monday", ClientNumber: "+15550000001"},
+		},
+		{
+			name:  "no call",
+			agent: models.Agent{SystemPrompt: "Hello {{client_number}}", Variables: []models.PromptVariable{{Name: "name", Default: "there"}}},
+			// The client number is only known once there's a call
+			wantMissing: []string{"client_number"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			vars, err := Resolve(&tt.agent, tt.call, now)
+
+			var missing *MissingVariablesError
+			if tt.wantMissing != nil {
+				if !errors.As(err, &missing) {
+					t.Fatalf("got error %v, want missing variables %v", err, tt.wantMissing)
+				}
+				if !reflect.DeepEqual(missing.Names, tt.wantMissing) {
+					t.Errorf("got missing variables %v, want %v", missing.Names, tt.wantMissing)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				if got, ok := vars[name]; !ok || got != want {
+					t.Errorf("got %s %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateVariables(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		variables []models.PromptVariable
+		wantErr   bool
+	}{
+		{name: "valid", variables: []models.PromptVariable{{Name: "name"}, {Name: "order_id2"}, {Name: "_internal"}}},
+		{name: "none"},
+		{name: "empty name", variables: []models.PromptVariable{{Name: ""}}, wantErr: true},
+		{name: "starts with a digit", variables: []models.PromptVariable{{Name: "1st"}}, wantErr: true},
+		{name: "spaces", variables: []models.PromptVariable{{Name: "first name"}}, wantErr: true},
+		{name: "braces", variables: []models.PromptVariable{{Name: "{{name}}"}}, wantErr: true},
+		{name: "builtin", variables: []models.PromptVariable{{Name: CurrentDate}}, wantErr: true},
+		{name: "duplicate", variables: []models.PromptVariable{{Name: "name"}, {Name: "name", Default: "there"}}, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateVariables(tt.variables); (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package recordings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/jobs"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"gorm.io/gorm"
+)
+
+const ArchiveJobType = "archive_recording"
+
+// Twilio takes a little while to finalize a recording after the call ends
+const archiveDelay = 30 * time.Second
+
+type archivePayload struct {
+	CallId uint `json:"call_id"`
+}
+
+// URL returns the Twilio media URL of a recording
+func URL(cfg *config.Config, recordingSid string) string {
+	return "https://api.twilio.com/2010-04-01/Accounts/" + cfg.TwilioAccountSid + "/Recordings/" + recordingSid + ".mp3"
+}
+
+// Download fetches a recording's mp3 from Twilio. The caller closes the response body.
+func Download(ctx context.Context, cfg *config.Config, recordingSid string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, URL(cfg, recordingSid), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(cfg.TwilioAccountSid, cfg.TwilioAccountAuthToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("twilio returned status code %d for recording %s", resp.StatusCode, recordingSid)
+	}
+	return resp, nil
+}
+
+// EnqueueArchive schedules copying the call's recording into the archive directory. It does nothing when
+// archiving isn't configured or the call wasn't recorded.
+func EnqueueArchive(cfg *config.Config, db *gorm.DB, agent *models.Agent, call *models.Call) error {
+	if cfg.RecordingArchiveDir == "" || call.RecordingSid == "" {
+		return nil
+	}
+
+	_, err := jobs.Enqueue(db, ArchiveJobType, archivePayload{CallId: call.ID},
+		jobs.RunAt(time.Now().Add(archiveDelay)),
+		jobs.ForUser(agent.UserId),
+		jobs.ForCall(call.ID),
+	)
+	return err
+}
+
+// NewArchiveJobHandler returns the job handler that downloads recordings into the archive directory
+func NewArchiveJobHandler(cfg *config.Config, db *gorm.DB) jobs.Handler {
+	return func(ctx context.Context, job *models.Job) error {
+		var payload archivePayload
+		if err := jobs.DecodePayload(job, &payload); err != nil {
+			return jobs.Permanent(err)
+		}
+
+		var call models.Call
+		if err := db.First(&call, payload.CallId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.S.Warnf("call %d was deleted before its recording was archived", payload.CallId)
+				return nil
+			}
+			return err
+		}
+		if call.RecordingSid == "" || call.RecordingPath != "" {
+			return nil
+		}
+
+		resp, err := Download(ctx, cfg, call.RecordingSid)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		path, err := write(cfg.RecordingArchiveDir, strconv.FormatUint(uint64(call.ID), 10)+".mp3", resp.Body)
+		if err != nil {
+			return err
+		}
+
+		return db.Model(&call).Update("recording_path", path).Error
+	}
+}
+
+// write stores the recording through a temporary file so a failed download never leaves a partial recording behind
+func write(dir string, name string, r io.Reader) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(dir, name+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+const DeleteJobType = "delete_recordings"
+
+// Recording is where a call's recording is kept, at Twilio and in the archive
+type Recording struct {
+	Sid  string `json:"sid,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+type deletePayload struct {
+	Recordings []Recording `json:"recordings"`
+}
+
+// EnqueueDelete schedules deleting the recordings from Twilio and the archive, e.g. for privacy requests
+func EnqueueDelete(db *gorm.DB, userId uint, recordings []Recording) error {
+	if len(recordings) == 0 {
+		return nil
+	}
+	_, err := jobs.Enqueue(db, DeleteJobType, deletePayload{Recordings: recordings}, jobs.ForUser(userId))
+	return err
+}
+
+// NewDeleteJobHandler returns the job handler that deletes recordings. Recordings that are already gone
+// count as deleted so retries pick up where a failed attempt stopped.
+func NewDeleteJobHandler(cfg *config.Config) jobs.Handler {
+	return func(ctx context.Context, job *models.Job) error {
+		var payload deletePayload
+		if err := jobs.DecodePayload(job, &payload); err != nil {
+			return jobs.Permanent(err)
+		}
+
+		for _, recording := range payload.Recordings {
+			if recording.Path != "" {
+				if err := os.Remove(recording.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return err
+				}
+			}
+			if recording.Sid != "" {
+				if err := deleteFromTwilio(ctx, cfg, recording.Sid); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func deleteFromTwilio(ctx context.Context, cfg *config.Config, recordingSid string) error {
+	url := "https://api.twilio.com/2010-04-01/Accounts/" + cfg.TwilioAccountSid + "/Recordings/" + recordingSid + ".json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(cfg.TwilioAccountSid, cfg.TwilioAccountAuthToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("twilio returned status code %d deleting recording %s", resp.StatusCode, recordingSid)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package routing
+
+import (
+	"strings"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/models"
+)
+
+// Match returns the first of the agent's routing rules that applies to a call from caller at now, or nil
+// when the agent should answer
+func Match(agent *models.Agent, caller string, now time.Time) *models.RoutingRule {
+	if agent.Routing == nil {
+		return nil
+	}
+	routing := agent.Routing
+	loc := location(agent)
+
+	for i := range routing.Rules {
+		rule := &routing.Rules[i]
+		if !matchesTime(routing, rule.When, now, loc) {
+			continue
+		}
+		if len(rule.Callers) > 0 && !matchesCaller(rule.Callers, caller) {
+			continue
+		}
+		return rule
+	}
+	return nil
+}
+
+func matchesTime(routing *models.InboundRouting, when string, now time.Time, loc *time.Location) bool {
+	switch when {
+	case models.RouteWhenOpen:
+		return routing.IsOpen(now, loc)
+	case models.RouteWhenClosed:
+		return !routing.IsOpen(now, loc)
+	case models.RouteWhenHoliday:
+		return routing.IsHoliday(now, loc)
+	default:
+		return true
+	}
+}
+
+func matchesCaller(callers []string, caller string) bool {
+	for _, pattern := range callers {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(caller, prefix) {
+				return true
+			}
+		} else if caller == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// location is the routing timezone, falling back to the agent's timezone and then UTC
+func location(agent *models.Agent) *time.Location {
+	for _, name := range []string{agent.Routing.Timezone, agent.Timezone} {
+		if name == "" {
+			continue
+		}
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
@@ -0,0 +1,81 @@
+package routing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/models"
+)
+
+func TestMatch(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := &models.Agent{
+		Timezone: "America/New_York",
+		Routing: &models.InboundRouting{
+			BusinessHours: []models.CallingWindow{{Start: "09:00", End: "17:00", Days: []string{"mon", "tue", "wed", "thu", "fri"}}},
+			Holidays:      []string{"12-25"},
+			Rules: []models.RoutingRule{
+				{Name: "vip", Callers: []string{"+1415555*"}, Target: models.RoutingTarget{Type: models.RouteToAgent, AgentId: 2}},
+				{Name: "holiday", When: models.RouteWhenHoliday, Target: models.RoutingTarget{Type: models.RouteToVoicemail}},
+				{Name: "after hours", When: models.RouteWhenClosed, Target: models.RoutingTarget{Type: models.RouteToForward, ForwardingNumber: "+14155550199"}},
+				{Name: "blocked", Callers: []string{"+12125550100"}, Target: models.RoutingTarget{Type: models.RouteToReject}},
+			},
+		},
+	}
+
+	open := time.Date(2026, 10, 14, 10, 0, 0, 0, newYork)
+	tests := []struct {
+		name   string
+		agent  *models.Agent
+		caller string
+		at     time.Time
+		want   string
+	}{
+		{"caller prefix matches at any time", agent, "+14155550123", time.Date(2026, 10, 17, 23, 0, 0, 0, newYork), "vip"},
+		{"prefix doesn't match other numbers", agent, "+14165550123", open, ""},
+		{"business hours go to the agent", agent, "+13125550123", open, ""},
+		{"holiday rule comes before closed", agent, "+13125550123", time.Date(2026, 12, 25, 10, 0, 0, 0, newYork), "holiday"},
+		{"evening", agent, "+13125550123", time.Date(2026, 10, 14, 18, 0, 0, 0, newYork), "after hours"},
+		{"weekend", agent, "+13125550123", time.Date(2026, 10, 17, 10, 0, 0, 0, newYork), "after hours"},
+		{"exact caller during business hours", agent, "+12125550100", open, "blocked"},
+		{"exact caller after hours hits the earlier rule", agent, "+12125550100", time.Date(2026, 10, 14, 18, 0, 0, 0, newYork), "after hours"},
+		{"agent without routing", &models.Agent{}, "+13125550123", open, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := Match(tt.agent, tt.caller, tt.at)
+			got := ""
+			if rule != nil {
+				got = rule.Name
+			}
+			if got != tt.want {
+				t.Errorf("Match(%s, %s) = %q, want %q", tt.caller, tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchUsesTheRoutingTimezone(t *testing.T) {
+	agent := &models.Agent{
+		Timezone: "America/New_York",
+		Routing: &models.InboundRouting{
+			Timezone:      "America/Los_Angeles",
+			BusinessHours: []models.CallingWindow{{Start: "09:00", End: "17:00"}},
+			Rules:         []models.RoutingRule{{Name: "closed", When: models.RouteWhenClosed, Target: models.RoutingTarget{Type: models.RouteToVoicemail}}},
+		},
+	}
+
+	// 10:00 in New York is 07:00 in Los Angeles
+	if rule := Match(agent, "+13125550123", time.Date(2026, 10, 14, 14, 0, 0, 0, time.UTC)); rule == nil {
+		t.Error("call before opening in the routing timezone wasn't routed")
+	}
+
+	// Without a routing timezone the agent's is used
+	agent.Routing.Timezone = ""
+	if rule := Match(agent, "+13125550123", time.Date(2026, 10, 14, 14, 0, 0, 0, time.UTC)); rule != nil {
+		t.Errorf("call during business hours in the agent's timezone was routed by %q", rule.Name)
+	}
+}
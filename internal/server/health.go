@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	healthCheckTimeout = 3 * time.Second
+
+	// Vendors are checked at most this often so readiness probes don't hammer them
+	vendorCheckInterval = 30 * time.Second
+)
+
+// Vendors calls can't be handled without. Any HTTP response counts as reachable. An unreachable vendor only marks
+// the server degraded, every instance shares the vendors so taking this one out of rotation wouldn't help.
+var vendorURLs = map[string]string{
+	"deepgram":   "https://api.deepgram.com",
+	"openai":     "https://api.openai.com",
+	"elevenlabs": "https://api.elevenlabs.io",
+	"cartesia":   "https://api.cartesia.ai",
+	"twilio":     "https://api.twilio.com",
+}
+
+type readinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Health reports whether the server is alive and whether it should be sent new calls
+type Health struct {
+	db     *gorm.DB
+	client *http.Client
+
+	mu            sync.Mutex
+	draining      bool
+	vendorChecks  map[string]string
+	vendorChecked time.Time
+}
+
+func NewHealth(db *gorm.DB) *Health {
+	return &Health{
+		db:     db,
+		client: &http.Client{Timeout: healthCheckTimeout},
+	}
+}
+
+// Drain fails readiness so no new calls are sent to the server
+func (h *Health) Drain() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.draining = true
+}
+
+func (h *Health) Draining() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.draining
+}
+
+// HandleHealthz reports that the process is up
+func (h *Health) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// HandleReadyz reports whether the server can take calls: it isn't draining and the database answers. Unreachable
+// vendors are reported as degraded without failing readiness.
+func (h *Health) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	response := readinessResponse{Status: "ok", Checks: map[string]string{}}
+	if h.Draining() {
+		response.Status = "draining"
+	}
+
+	response.Checks["db"] = "ok"
+	if err := h.pingDB(ctx); err != nil {
+		logger.S.Warnf("readiness check failed for db: %v", err)
+		response.Checks["db"] = err.Error()
+		response.Status = "unavailable"
+	}
+
+	for vendor, result := range h.checkVendors(ctx) {
+		response.Checks[vendor] = result
+		if result != "ok" && response.Status == "ok" {
+			response.Status = "degraded"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if response.Status == "draining" || response.Status == "unavailable" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *Health) pingDB(ctx context.Context) error {
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// checkVendors returns the result of the last vendor checks, rerunning them once they're stale
+func (h *Health) checkVendors(ctx context.Context) map[string]string {
+	h.mu.Lock()
+	if h.vendorChecks != nil && time.Since(h.vendorChecked) < vendorCheckInterval {
+		checks := h.vendorChecks
+		h.mu.Unlock()
+		return checks
+	}
+	h.mu.Unlock()
+
+	checks := make(map[string]string, len(vendorURLs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for vendor, url := range vendorURLs {
+		wg.Add(1)
+		go func(vendor string, url string) {
+			defer wg.Done()
+
+			result := "ok"
+			if err := h.reach(ctx, url); err != nil {
+				logger.S.Warnf("readiness check failed for %s: %v", vendor, err)
+				result = "degraded: " + err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			checks[vendor] = result
+		}(vendor, url)
+	}
+	wg.Wait()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.vendorChecks = checks
+	h.vendorChecked = time.Now()
+	return checks
+}
+
+func (h *Health) reach(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+)
+
+func TestReadyz(t *testing.T) {
+	if logger.S == nil {
+		logger.InitLogger("test")
+	}
+
+	allOk := map[string]string{}
+	openaiDown := map[string]string{}
+	for vendor := range vendorURLs {
+		allOk[vendor] = "ok"
+		openaiDown[vendor] = "ok"
+	}
+	openaiDown["openai"] = "degraded: connection refused"
+
+	for _, tt := range []struct {
+		name       string
+		vendors    map[string]string
+		draining   bool
+		closeDB    bool
+		wantCode   int
+		wantStatus string
+	}{
+		{name: "ready", vendors: allOk, wantCode: http.StatusOK, wantStatus: "ok"},
+		{name: "vendor unreachable", vendors: openaiDown, wantCode: http.StatusOK, wantStatus: "degraded"},
+		{name: "draining", vendors: allOk, draining: true, wantCode: http.StatusServiceUnavailable, wantStatus: "draining"},
+		{name: "draining with a vendor unreachable", vendors: openaiDown, draining: true, wantCode: http.StatusServiceUnavailable, wantStatus: "draining"},
+		{name: "database down", vendors: openaiDown, closeDB: true, wantCode: http.StatusServiceUnavailable, wantStatus: "unavailable"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := InitDB(&config.Config{DBDriver: config.DBDriverSQLite, DBPath: filepath.Join(t.TempDir(), "flyflow.db")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sqlDB, err := db.DB()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sqlDB.Close()
+			if tt.closeDB {
+				sqlDB.Close()
+			}
+
+			// Fresh vendor results are reused rather than checked over the network
+			h := NewHealth(db)
+			h.vendorChecks = tt.vendors
+			h.vendorChecked = time.Now()
+			if tt.draining {
+				h.Drain()
+			}
+
+			rec := httptest.NewRecorder()
+			h.HandleReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			var response readinessResponse
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.wantCode || response.Status != tt.wantStatus {
+				t.Errorf("got %d %s, want %d %s", rec.Code, response.Status, tt.wantCode, tt.wantStatus)
+			}
+			if response.Checks["openai"] != tt.vendors["openai"] {
+				t.Errorf("got openai check %q, want %q", response.Checks["openai"], tt.vendors["openai"])
+			}
+		})
+	}
+}
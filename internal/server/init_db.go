@@ -3,32 +3,38 @@ package server
 import (
 	"fmt"
 	"github.com/flyflow-devs/flyflow/internal/config"
-	"github.com/flyflow-devs/flyflow/internal/logger"
-	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"log"
 )
 
-func InitDB(cfg *config.Config, automigrate bool) *gorm.DB {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable", cfg.DBHost, cfg.DBUser, cfg.DBPass, cfg.DBName, cfg.DBPort)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// InitDB connects to the configured database, the schema is managed by the db migrate commands
+func InitDB(cfg *config.Config) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch cfg.DBDriver {
+	case config.DBDriverPostgres:
+		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable", cfg.DBHost, cfg.DBUser, cfg.DBPass, cfg.DBName, cfg.DBPort)
+		dialector = postgres.Open(dsn)
+	case config.DBDriverSQLite:
+		// Writers wait for each other instead of failing
+		dialector = sqlite.Open(cfg.DBPath + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	default:
+		return nil, fmt.Errorf("unknown database driver %s", cfg.DBDriver)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	// Automigrate
-	if automigrate {
-		if err := db.AutoMigrate(
-			&models.APIKey{},
-			&models.User{},
-			&models.Agent{},
-			&models.Call{},
-		); err != nil {
-			logger.S.Fatal("failed to migrate db", err)
+	// Every connection to an in-memory database gets its own database, so share a single connection
+	if cfg.DBDriver == config.DBDriverSQLite && cfg.DBPath == ":memory:" {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
 		}
+		sqlDB.SetMaxOpenConns(1)
 	}
 
-
-	return db
+	return db, nil
 }
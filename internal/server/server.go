@@ -3,7 +3,9 @@ package server
 import (
 	"github.com/flyflow-devs/flyflow/internal/api"
 	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/ownership"
 	"github.com/flyflow-devs/flyflow/internal/streaming"
+	"github.com/flyflow-devs/flyflow/internal/telemetry"
 	"gorm.io/gorm"
 	"net/http"
 	"sync"
@@ -18,6 +20,10 @@ type Server struct {
 	DB     *gorm.DB
 	Cfg    *config.Config
 	WG *sync.WaitGroup
+	Health *Health
+	Registry *ownership.Registry
+
+	twilioHandler *streaming.TwilioHandler
 }
 
 func NewServer(Config *config.Config, DB *gorm.DB) *Server {
@@ -26,11 +32,19 @@ func NewServer(Config *config.Config, DB *gorm.DB) *Server {
 		Cfg:    Config,
 		DB:     DB,
 		WG: &sync.WaitGroup{},
+		Health: NewHealth(DB),
+		Registry: ownership.NewRegistry(Config, DB),
 	}
 	s.routes()
 	return s
 }
 
+// Drain stops the server taking new calls while the calls in progress finish
+func (s *Server) Drain() {
+	s.Health.Drain()
+	s.twilioHandler.Drain()
+}
+
 func (s *Server) routes() {
 	// CORS setup
 	corsMiddleware := handlers.CORS(
@@ -47,14 +61,30 @@ func (s *Server) routes() {
 		w.WriteHeader(http.StatusOK)
 	})
 
+	s.Router.HandleFunc("/healthz", s.Health.HandleHealthz).Methods(http.MethodGet)
+	s.Router.HandleFunc("/readyz", s.Health.HandleReadyz).Methods(http.MethodGet)
+
+	// Commands forwarded from other instances to the calls running here
+	s.Router.HandleFunc("/internal/calls/{id}/commands", s.Registry.HandleCommand).Methods(http.MethodPost)
+
+	// Prometheus metrics
+	if s.Cfg.MetricsEnabled {
+		s.Router.Handle("/metrics", telemetry.Handler()).Methods(http.MethodGet)
+	}
+
 	// Twilio routes
-	twilioHandler := streaming.NewTwilioHandler(s.Cfg, s.DB, s.WG)
+	twilioHandler := streaming.NewTwilioHandler(s.Cfg, s.DB, s.WG, s.Registry)
+	s.twilioHandler = twilioHandler
 	s.Router.HandleFunc("/twilio/stream", twilioHandler.HandleTwilioStream).Methods(http.MethodGet)
 	s.Router.HandleFunc("/twilio/ml", twilioHandler.HandleTwilioML).Methods(http.MethodPost)
 	s.Router.HandleFunc("/twilio/ml/redirect", twilioHandler.HandleForwardCall).Methods(http.MethodPost)
+	s.Router.HandleFunc("/twilio/status", twilioHandler.HandleTwilioStatus).Methods(http.MethodPost)
+	s.Router.HandleFunc("/twilio/voicemail/greeting", twilioHandler.HandleVoicemailGreeting).Methods(http.MethodGet, http.MethodPost)
+	s.Router.HandleFunc("/twilio/voicemail/recorded", twilioHandler.HandleVoicemailRecorded).Methods(http.MethodPost)
+	s.Router.HandleFunc("/twilio/voicemail/fallback", twilioHandler.HandleVoicemailFallback).Methods(http.MethodPost)
 
 	// API routes
-	apiHandler := api.NewAPI(s.Cfg, s.DB)
+	apiHandler := api.NewAPI(s.Cfg, s.DB, s.Registry)
 	s.Router.HandleFunc("/v1/call", apiHandler.CreateCall).Methods(http.MethodPost)
 	s.Router.HandleFunc("/v1/call", apiHandler.GetCall).Methods(http.MethodGet)
 	s.Router.HandleFunc("/v1/call/context", apiHandler.SetCallContext).Methods(http.MethodPost)
@@ -62,11 +92,43 @@ func (s *Server) routes() {
 	s.Router.HandleFunc("/v1/call/recording/{id}.mp3", apiHandler.GetRecording).Methods(http.MethodGet)
 	s.Router.HandleFunc("/v1/call/{id}", apiHandler.DeleteCall).Methods(http.MethodDelete)
 
+	s.Router.HandleFunc("/v1/dnc", apiHandler.AddDoNotCall).Methods(http.MethodPost)
+	s.Router.HandleFunc("/v1/dnc", apiHandler.ListDoNotCall).Methods(http.MethodGet)
+	s.Router.HandleFunc("/v1/dnc", apiHandler.DeleteDoNotCall).Methods(http.MethodDelete)
+	s.Router.HandleFunc("/v1/dnc/import", apiHandler.ImportDoNotCall).Methods(http.MethodPost)
+
+	s.Router.HandleFunc("/v1/caller-memory", apiHandler.GetCallerMemory).Methods(http.MethodGet)
+	s.Router.HandleFunc("/v1/caller-memory", apiHandler.DeleteCallerMemory).Methods(http.MethodDelete)
+
+	s.Router.HandleFunc("/v1/scheduled-call", apiHandler.GetScheduledCall).Methods(http.MethodGet)
+	s.Router.HandleFunc("/v1/scheduled-call", apiHandler.CancelScheduledCall).Methods(http.MethodDelete)
+	s.Router.HandleFunc("/v1/scheduled-calls", apiHandler.ListScheduledCalls).Methods(http.MethodGet)
+
 	s.Router.HandleFunc("/v1/agent", apiHandler.UpsertAgent).Methods(http.MethodPost)
 	s.Router.HandleFunc("/v1/agent", apiHandler.GetAgent).Methods(http.MethodGet)
 	s.Router.HandleFunc("/v1/agent", apiHandler.DeleteAgent).Methods(http.MethodDelete)
 	s.Router.HandleFunc("/v1/agents", apiHandler.ListAgents).Methods(http.MethodGet)
 
+	s.Router.HandleFunc("/v1/knowledge-base", apiHandler.UpsertKnowledgeBase).Methods(http.MethodPost)
+	s.Router.HandleFunc("/v1/knowledge-base", apiHandler.GetKnowledgeBase).Methods(http.MethodGet)
+	s.Router.HandleFunc("/v1/knowledge-base", apiHandler.DeleteKnowledgeBase).Methods(http.MethodDelete)
+	s.Router.HandleFunc("/v1/knowledge-bases", apiHandler.ListKnowledgeBases).Methods(http.MethodGet)
+	s.Router.HandleFunc("/v1/knowledge-base/document", apiHandler.UploadDocument).Methods(http.MethodPost)
+	s.Router.HandleFunc("/v1/knowledge-base/document", apiHandler.DeleteDocument).Methods(http.MethodDelete)
+
+	s.Router.HandleFunc("/v1/campaign", apiHandler.UpsertCampaign).Methods(http.MethodPost)
+	s.Router.HandleFunc("/v1/campaign", apiHandler.GetCampaign).Methods(http.MethodGet)
+	s.Router.HandleFunc("/v1/campaign", apiHandler.DeleteCampaign).Methods(http.MethodDelete)
+	s.Router.HandleFunc("/v1/campaigns", apiHandler.ListCampaigns).Methods(http.MethodGet)
+	s.Router.HandleFunc("/v1/campaign/contacts", apiHandler.UploadCampaignContacts).Methods(http.MethodPost)
+	s.Router.HandleFunc("/v1/campaign/contacts", apiHandler.ListCampaignContacts).Methods(http.MethodGet)
+	s.Router.HandleFunc("/v1/campaign/start", apiHandler.StartCampaign).Methods(http.MethodPost)
+	s.Router.HandleFunc("/v1/campaign/pause", apiHandler.PauseCampaign).Methods(http.MethodPost)
+	s.Router.HandleFunc("/v1/campaign/cancel", apiHandler.CancelCampaign).Methods(http.MethodPost)
+
+	s.Router.HandleFunc("/v1/job", apiHandler.GetJob).Methods(http.MethodGet)
+	s.Router.HandleFunc("/v1/jobs", apiHandler.ListJobs).Methods(http.MethodGet)
+
 	s.Router.HandleFunc("/v1/filler-words", apiHandler.GetFillerWords).Methods(http.MethodGet)
 
 	// Web routes
@@ -86,4 +148,5 @@ func (s *Server) routes() {
 
 	s.Router.HandleFunc("/v1/user", apiHandler.GetUser).Methods(http.MethodGet)
 	s.Router.HandleFunc("/v1/user/plan", apiHandler.SetPlan).Methods(http.MethodPost)
+	s.Router.HandleFunc("/v1/user/calling-hours", apiHandler.SetCallingHours).Methods(http.MethodPost)
 }
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/analysis"
+	"github.com/flyflow-devs/flyflow/internal/campaigns"
+	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/endpointing"
+	"github.com/flyflow-devs/flyflow/internal/jobs"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/outbound"
+	"github.com/flyflow-devs/flyflow/internal/recordings"
+	"github.com/flyflow-devs/flyflow/internal/voicemail"
+	"github.com/flyflow-devs/flyflow/internal/webhook"
+	"gorm.io/gorm"
+)
+
+// NewWorker returns a background job worker with every job handler registered
+func NewWorker(cfg *config.Config, db *gorm.DB) *jobs.Worker {
+	worker := jobs.NewWorker(db, cfg.WorkerConcurrency)
+	worker.Register(webhook.JobType, webhook.NewJobHandler(db))
+	worker.Register(analysis.JobType, analysis.NewJobHandler(cfg, db))
+	worker.Register(recordings.ArchiveJobType, recordings.NewArchiveJobHandler(cfg, db))
+	worker.Register(recordings.DeleteJobType, recordings.NewDeleteJobHandler(cfg))
+	worker.Register(outbound.PlaceCallJobType, outbound.NewPlaceCallJobHandler(cfg, db))
+	worker.Register(campaigns.DialerJobType, campaigns.NewDialerJobHandler(cfg, db))
+	worker.Register(voicemail.TranscribeJobType, voicemail.NewTranscribeJobHandler(cfg, db))
+
+	if cfg.EndpointingRetentionDays > 0 {
+		retention := time.Duration(cfg.EndpointingRetentionDays) * 24 * time.Hour
+		worker.Every("endpointing decision pruning", time.Hour, func(ctx context.Context) error {
+			pruned, err := endpointing.Prune(db.WithContext(ctx), retention)
+			if pruned > 0 {
+				logger.S.Infof("pruned %d endpointing decisions older than %d days", pruned, cfg.EndpointingRetentionDays)
+			}
+			return err
+		})
+	}
+	return worker
+}
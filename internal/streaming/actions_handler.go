@@ -4,7 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/flyflow-devs/flyflow/internal/analysis"
+	"github.com/flyflow-devs/flyflow/internal/compliance"
+	"github.com/flyflow-devs/flyflow/internal/llm"
 	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/outbound"
+	"github.com/flyflow-devs/flyflow/internal/prompts"
 	"github.com/sashabaranov/go-openai"
 	"github.com/twilio/twilio-go"
 	openapi "github.com/twilio/twilio-go/rest/api/v2010"
@@ -13,41 +19,33 @@ import (
 	"time"
 )
 
+const (
+	// Callbacks can be booked at most this far ahead
+	maxCallbackDelay = 90 * 24 * time.Hour
+
+	callbackSummaryTimeout = 15 * time.Second
+)
+
 func (c *CallOrchestrator) handleActions() {
 	var messages []openai.ChatCompletionMessage
 
 	openaiConfig := openai.DefaultConfig(c.cfg.OpenAIAPIKey)
 	openaiClient := openai.NewClientWithConfig(openaiConfig)
 
-	// Build the tools list
-	tools := []openai.Tool{}
-	for _, action := range c.agent.Actions {
-		parameters := map[string]interface{}{
-			"type": "object",
-			"properties": map[string]interface{}{
-				"ForwardingNumber": map[string]interface{}{
-					"type":        "string",
-					"description": "The phone number to forward the call to",
-				},
-			},
-			"required": []string{"ForwardingNumber"},
-		}
-
-		tools = append(tools, openai.Tool{
-			Type: openai.ToolTypeFunction,
-			Function: &openai.FunctionDefinition{
-				Name:        action.Name,
-				Description: fmt.Sprintf("Instructions: %v \n\nForwarding Number: %v", action.Instructions, action.ForwardingNumber),
-				Parameters:  parameters,
-			},
-		})
-	}
+	var tools []openai.Tool
+	var toolsAgent *models.Agent
 
 	for {
 		if c.done {
 			break
 		}
 
+		// Rebuild the tools list when the call is handed off to another agent
+		if agent := c.currentAgent(); agent != toolsAgent {
+			toolsAgent = agent
+			tools = c.actionTools(agent)
+		}
+
 		if len(c.call.Transcript) > len(messages) {
 			ctx := context.Background()
 			resp, err := openaiClient.CreateChatCompletion(
@@ -67,7 +65,7 @@ func (c *CallOrchestrator) handleActions() {
 				for _, toolCall := range resp.Choices[0].Message.ToolCalls {
 					switch toolCall.Function.Name {
 					case "hangup":
-						if err := c.hangupCall(); err != nil {
+						if err := c.hangupCall("agent_hangup"); err != nil {
 							logger.S.Errorf("error hanging up call: %v", err)
 						}
 					case "forward":
@@ -82,6 +80,44 @@ func (c *CallOrchestrator) handleActions() {
 						if err := c.forwardCall(args.ForwardingNumber); err != nil {
 							logger.S.Errorf("error forwarding call: %v", err)
 						}
+					case "schedule_callback":
+						var args struct {
+							ScheduledAt string `json:"scheduled_at"`
+							Reason      string `json:"reason"`
+						}
+						if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+							logger.S.Errorf("error parsing schedule_callback arguments: %v", err)
+							continue
+						}
+						if err := c.scheduleCallback(args.ScheduledAt, args.Reason); err != nil {
+							logger.S.Errorf("error scheduling callback: %v", err)
+						}
+					case "handoff":
+						var args struct {
+							Agent  string `json:"agent"`
+							Reason string `json:"reason"`
+						}
+						if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+							logger.S.Errorf("error parsing handoff arguments: %v", err)
+							continue
+						}
+						// The new agent takes over the conversation loop's state, so the loop makes the switch
+						c.changeState(func() {
+							if err := c.handoff(args.Agent, args.Reason); err != nil {
+								logger.S.Errorf("error handing off call: %v", err)
+							}
+						})
+					case "add_to_dnc":
+						var args struct {
+							Reason string `json:"reason"`
+						}
+						if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+							logger.S.Errorf("error parsing add_to_dnc arguments: %v", err)
+							continue
+						}
+						if err := c.addToDoNotCallList(args.Reason); err != nil {
+							logger.S.Errorf("error adding caller to the do not call list: %v", err)
+						}
 					default:
 						logger.S.Warnf("unknown action: %s", toolCall.Function.Name)
 					}
@@ -95,7 +131,172 @@ func (c *CallOrchestrator) handleActions() {
 	}
 }
 
-func (c *CallOrchestrator) hangupCall() error {
+// actionTools builds the tools list for the agent's actions
+func (c *CallOrchestrator) actionTools(agent *models.Agent) []openai.Tool {
+	tools := []openai.Tool{}
+	for _, action := range agent.Actions {
+		tools = append(tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        action.Name,
+				Description: c.actionDescription(action),
+				Parameters:  c.actionParameters(action),
+			},
+		})
+	}
+	return tools
+}
+
+// actionDescription tells the model when to use the action
+func (c *CallOrchestrator) actionDescription(action models.Action) string {
+	switch action.Name {
+	case "add_to_dnc":
+		return fmt.Sprintf("Instructions: %v \n\nUse this when the caller asks not to be called again.", action.Instructions)
+	case "handoff":
+		return fmt.Sprintf("Instructions: %v \n\nUse this to pass the conversation to another agent who is better suited to help the caller.", action.Instructions)
+	case "schedule_callback":
+		now := time.Now().In(prompts.Location(c.agent))
+		return fmt.Sprintf("Instructions: %v \n\nUse this when the caller asks to be called back later. The current time is %s.",
+			action.Instructions, now.Format("Monday, January 2, 2006 15:04 MST"))
+	default:
+		return fmt.Sprintf("Instructions: %v \n\nForwarding Number: %v", action.Instructions, action.ForwardingNumber)
+	}
+}
+
+// actionParameters returns the JSON schema of the arguments the model passes to the action
+func (c *CallOrchestrator) actionParameters(action models.Action) map[string]interface{} {
+	switch action.Name {
+	case "handoff":
+		names := []string{}
+		for _, agent := range c.handoffTargets(action) {
+			names = append(names, agent.Name)
+		}
+		return map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"agent": map[string]interface{}{
+					"type":        "string",
+					"enum":        names,
+					"description": "The agent to pass the conversation to",
+				},
+				"reason": map[string]interface{}{
+					"type":        "string",
+					"description": "Why the caller is being passed to the agent",
+				},
+			},
+			"required": []string{"agent"},
+		}
+	case "add_to_dnc":
+		return map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"reason": map[string]interface{}{
+					"type":        "string",
+					"description": "What the caller said when opting out",
+				},
+			},
+		}
+	case "schedule_callback":
+		return map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"scheduled_at": map[string]interface{}{
+					"type":        "string",
+					"description": "When to call back as an RFC 3339 timestamp including the UTC offset, e.g. 2024-05-01T15:00:00-07:00",
+				},
+				"reason": map[string]interface{}{
+					"type":        "string",
+					"description": "Why the caller wants to be called back",
+				},
+			},
+			"required": []string{"scheduled_at"},
+		}
+	default:
+		return map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"ForwardingNumber": map[string]interface{}{
+					"type":        "string",
+					"description": "The phone number to forward the call to",
+				},
+			},
+			"required": []string{"ForwardingNumber"},
+		}
+	}
+}
+
+// scheduleCallback books an outbound call back to the caller with a summary of this conversation as context
+func (c *CallOrchestrator) scheduleCallback(scheduledAt string, reason string) error {
+	if c.callback != nil {
+		return fmt.Errorf("a callback was already scheduled for %s", c.callback.ScheduledAt)
+	}
+
+	at, err := time.Parse(time.RFC3339, scheduledAt)
+	if err != nil {
+		return fmt.Errorf("invalid callback time %q: %w", scheduledAt, err)
+	}
+	if !at.After(time.Now()) {
+		return fmt.Errorf("callback time %s is in the past", at)
+	}
+	if at.After(time.Now().Add(maxCallbackDelay)) {
+		return fmt.Errorf("callback time %s is too far in the future", at)
+	}
+	if c.call.ClientNumber == "" {
+		return fmt.Errorf("the caller's number is unknown")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callbackSummaryTimeout)
+	defer cancel()
+
+	client, model := llm.NewClient(c.cfg, analysis.Model(c.cfg, c.agent))
+	summary, err := analysis.Summarize(ctx, client, model, c.call.Transcript, "")
+	if err != nil {
+		logger.S.Errorf("error summarizing call for callback: %v", err)
+	}
+
+	callbackContext := "This is a callback the caller asked for during a previous call."
+	if reason != "" {
+		callbackContext += "\nReason for the callback: " + reason
+	}
+	if summary != "" {
+		callbackContext += "\nSummary of the previous call: " + summary
+	}
+
+	scheduled := &models.ScheduledCall{
+		UserId:       c.agent.UserId,
+		AgentId:      c.agent.ID,
+		To:           c.call.ClientNumber,
+		Context:      callbackContext,
+		Variables:    c.call.Variables,
+		ScheduledAt:  at,
+		Source:       models.ScheduledCallSourceCallback,
+		SourceCallId: c.call.ID,
+	}
+	if err := outbound.Schedule(c.db, scheduled); err != nil {
+		return err
+	}
+	c.callback = scheduled
+
+	c.EmitEvent("callback_scheduled", scheduled)
+	return nil
+}
+
+// addToDoNotCallList opts the caller out of future calls from any of the user's agents
+func (c *CallOrchestrator) addToDoNotCallList(reason string) error {
+	if c.call.ClientNumber == "" {
+		return fmt.Errorf("the caller's number is unknown")
+	}
+
+	return compliance.AddToDoNotCallList(c.db, []models.DoNotCallEntry{{
+		UserId:      c.agent.UserId,
+		PhoneNumber: c.call.ClientNumber,
+		Source:      models.DoNotCallSourceAgent,
+		Reason:      reason,
+		CallId:      c.call.ID,
+	}})
+}
+
+func (c *CallOrchestrator) hangupCall(reason string) error {
 	// Wait until the agent has stopped speaking to forward
 	for len(c.marks) > 0 {
 		time.Sleep(1 * time.Second)
@@ -115,7 +316,7 @@ func (c *CallOrchestrator) hangupCall() error {
 		return err
 	}
 
-	c.call.DisconnectReason = "agent_hangup"
+	c.call.DisconnectReason = reason
 	c.doneChan <- true
 	return nil
 }
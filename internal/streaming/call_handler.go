@@ -2,14 +2,21 @@ package streaming
 
 import (
 	"context"
-	"encoding/json"
+	"github.com/flyflow-devs/flyflow/internal/analysis"
 	"github.com/flyflow-devs/flyflow/internal/classifier"
 	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/knowledge"
 	"github.com/flyflow-devs/flyflow/internal/logger"
 	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/ownership"
+	"github.com/flyflow-devs/flyflow/internal/prompts"
+	"github.com/flyflow-devs/flyflow/internal/recordings"
+	"github.com/flyflow-devs/flyflow/internal/telemetry"
 	"github.com/gorilla/websocket"
 	"github.com/sashabaranov/go-openai"
 	"github.com/twilio/twilio-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
 	"gorm.io/gorm"
 	"sync"
@@ -21,6 +28,11 @@ type CallOrchestrator struct {
 	db *gorm.DB
 	conn *websocket.Conn
 
+	// Spans of the call and of the caller's current turn, vendor requests are their children
+	ctx     context.Context
+	span    trace.Span
+	turnCtx context.Context
+
 	// Done signals that the call is over
 	done     bool
 	doneChan chan bool
@@ -29,6 +41,9 @@ type CallOrchestrator struct {
 	agent *models.Agent
 	call  *models.Call
 
+	// Guards agent, the conversation loop swaps it on handoff while other goroutines read it
+	agentLock sync.RWMutex
+
 	// Channels for streaming call data
 	audioChan          chan string
 	rtcAudioChan       chan string
@@ -42,11 +57,35 @@ type CallOrchestrator struct {
 	// Metadata
 	streamSid     string
 	callSid       string
+	parameters    map[string]interface{}
+	phoneNumber   string
 	metrics       *Metrics
 	userLastSpoke time.Time
+	vadFailed     bool
+	sttStartedAt  time.Time
+	agentLastSpoke time.Time
 	lastFinalizedMessage time.Time
 
 	classifier *classifier.Classifier
+	registry   *ownership.Registry
+
+	// Knowledge base retrieval
+	knowledgeIndex     *knowledge.Index
+	embeddingClient    *openai.Client
+	lastKnowledgeQuery string
+	lastKnowledge      string
+
+	// Summary of previous calls with the caller when the agent has caller memory
+	callerMemory string
+
+	// Callback booked by the schedule_callback action, only one is booked per call
+	callback *models.ScheduledCall
+
+	// First sentence of the next response, synthesized while the caller was talking
+	speculativeAudio *synthesizedAudio
+
+	// Changes other goroutines make to the flow node, agent or transcript, applied by the conversation loop
+	stateChanges chan func()
 
 	marks map[string]interface{}
 	outgoingWebsocketLock sync.Mutex
@@ -55,13 +94,17 @@ type CallOrchestrator struct {
 	turn string
 }
 
-func NewCallOrchestrator(cfg *config.Config, db *gorm.DB, conn *websocket.Conn, classifier *classifier.Classifier) *CallOrchestrator {
+func NewCallOrchestrator(cfg *config.Config, db *gorm.DB, conn *websocket.Conn, classifier *classifier.Classifier, registry *ownership.Registry) *CallOrchestrator {
 
 	return &CallOrchestrator{
 		cfg: cfg,
 		db: db,
 		conn: conn,
 
+		ctx:     context.Background(),
+		span:    trace.SpanFromContext(context.Background()),
+		turnCtx: context.Background(),
+
 		doneChan: make(chan bool),
 		done: false,
 
@@ -77,9 +120,13 @@ func NewCallOrchestrator(cfg *config.Config, db *gorm.DB, conn *websocket.Conn,
 		metrics: NewMetrics(),
 
 		userLastSpoke: time.Now(),
+		agentLastSpoke: time.Now(),
 		lastFinalizedMessage: time.Now(),
 
 		classifier: classifier,
+		registry: registry,
+
+		stateChanges: make(chan func(), stateChangeBuffer),
 
 		marks: make(map[string]interface{}),
 
@@ -95,6 +142,7 @@ func (c *CallOrchestrator) OrchestrateCall() {
 	startMessage := <- c.startChan
 	c.streamSid = startMessage.StreamSid
 	c.callSid = startMessage.Start.CallSid
+	c.parameters = startMessage.Start.CustomParameters
 
 	call, err := c.fetchCall()
 	if err != nil {
@@ -108,7 +156,23 @@ func (c *CallOrchestrator) OrchestrateCall() {
 	if err := c.upsertCall(call); err != nil {
 		logger.S.Errorf("failed to set agent: %v", err)
 	}
+	c.ctx, c.span = telemetry.StartSpan(context.Background(), "call",
+		attribute.Int64("call.id", int64(c.call.ID)),
+		attribute.Int64("agent.id", int64(c.agent.ID)),
+		attribute.String("call.sid", c.callSid),
+	)
+	c.turnCtx = c.ctx
+	telemetry.ActiveCalls.Inc()
+
+	// Let API requests on any instance reach the call
+	if err := c.registry.Claim(c.call.ID, c.handleCommand); err != nil {
+		logger.S.Errorf("error claiming call %v: %v", c.call.ID, err)
+	}
+	c.renderPrompts()
+	c.loadKnowledge()
+	c.loadCallerMemory()
 	c.startCall()
+	c.startFlow()
 
 
 	// Async handle the parts of the conversation
@@ -117,10 +181,9 @@ func (c *CallOrchestrator) OrchestrateCall() {
 	go c.handleOutgoingAudio()
 	go c.handleInterruption()
 	go c.handleToolCalls()
-	go c.handleUpdatingContext()
 	go c.handleActions()
 	go c.handleWebRTC()
-	//go c.handleReminders()
+	go c.handleIdle()
 
 	// Have the agent speak the initial message to the user
 	if c.agent.InitialMessage != "" && !c.call.UserSpeaksFirst {
@@ -171,75 +234,42 @@ func (c *CallOrchestrator) endCall() error {
 	c.call.EndedAt = time.Now()
 	c.call.TimeSeconds = time.Since(c.call.StartedAt).Seconds()
 	c.call.AverageLatency = c.metrics.getAverageLatency()
+	c.call.SpeculationHitRate = c.metrics.getSpeculationHitRate()
+	c.call.TurnLatencies = c.metrics.getTurnLatencies()
 
-	c.calculateSentiment()
+	if err := c.registry.Release(c.call.ID); err != nil {
+		logger.S.Errorf("error releasing call %v: %v", c.call.ID, err)
+	}
+
+	telemetry.ActiveCalls.Dec()
+	telemetry.CallDuration.Observe(c.call.TimeSeconds)
+	c.metrics.observeTurn()
+	c.span.SetAttributes(attribute.String("call.disconnect_reason", c.call.DisconnectReason))
+	defer c.span.End()
 
 	c.call.InProgress = false
+	c.call.AnalysisStatus = models.AnalysisStatusPending
 
 	if err := c.saveCall(); err != nil {
 		return err
 	}
 
-	c.EmitEvent("call_ended", nil)
-
-	return nil
-}
-
-func (c *CallOrchestrator) calculateSentiment() {
-	openaiConfig := openai.DefaultConfig(c.cfg.OpenAIAPIKey)
-	openaiClient := openai.NewClientWithConfig(openaiConfig)
-
-	type response struct {
-		Sentiment uint `json:"sentiment"`
+	// Analysis runs in the background, the call_ended event is emitted once it's done
+	if err := analysis.Enqueue(c.db, c.agent, c.call); err != nil {
+		logger.S.Errorf("failed to enqueue analysis for call %v: %v", c.call.ID, err)
+		c.EmitEvent("call_ended", nil)
 	}
 
-	transcript, _ := json.Marshal(c.call.Transcript)
-
-	ctx := context.Background()
-	resp, err := openaiClient.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model:    "gpt-4o",
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role: "system",
-					Content: `
-						You are an expert at scoring sentiment from conversations. 
-
-						INSTRUCTIONS 
-						- Score the sentiment of the conversation 1-10
-						- Return json and ONLY json (no markup etc) in the format {"sentiment": <score uint 1-10>}
-						- Bias your scores towards a positive sentiment and only score negative if the transcript is truly negative. Even transcripts that are not explicitly positive should be scored as positive 
-
-						SENTIMENT SCORES
-						1-3 Negative 
-						3-7 Neutral
-						7-10 Positive
-					`,
-				},
-				{
-					Role: "user",
-					Content: string(transcript),
-				},
-			},
-		},
-	)
-
-	if err != nil {
-		logger.S.Errorf("error computing sentiment from openai: %v", err)
+	if err := recordings.EnqueueArchive(c.cfg, c.db, c.agent, c.call); err != nil {
+		logger.S.Errorf("failed to enqueue recording archival for call %v: %v", c.call.ID, err)
 	}
 
-	sentiment := response{}
-
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &sentiment); err != nil {
-		logger.S.Errorf("error unmarshalling sentiment score, content: %v, error: %v", resp.Choices[0].Message.Content, err)
-	}
-
-	c.call.Sentiment = sentiment.Sentiment
+	return nil
 }
 
 func (c *CallOrchestrator) saveCall() error {
-	return c.db.Save(c.call).Error
+	// The status columns are owned by the Twilio status callback
+	return c.db.Omit("status", "answered_by").Save(c.call).Error
 }
 
 func (c *CallOrchestrator) upsertCall(twilioCall *twilioApi.ApiV2010Call) error {
@@ -250,11 +280,12 @@ func (c *CallOrchestrator) upsertCall(twilioCall *twilioApi.ApiV2010Call) error
 	result := c.db.Where("sid = ?", c.callSid).First(&call)
 
 	var clientPhone string
-	if c.agent.PhoneNumber != toPhoneNumber {
+	if c.phoneNumber != toPhoneNumber {
 		clientPhone = toPhoneNumber
-	} else if c.agent.PhoneNumber != fromPhoneNumber {
+	} else if c.phoneNumber != fromPhoneNumber {
 		clientPhone = fromPhoneNumber
 	}
+	routingRule, _ := c.parameters["routing_rule"].(string)
 
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
@@ -262,6 +293,7 @@ func (c *CallOrchestrator) upsertCall(twilioCall *twilioApi.ApiV2010Call) error
 				AgentId: c.agent.ID,
 				Sid:     c.callSid,
 				ClientNumber: clientPhone,
+				RoutingRule: routingRule,
 			}
 
 			// Set the client number based on the phone number not associated with the agent
@@ -293,6 +325,24 @@ func (c *CallOrchestrator) fetchCall() (*twilioApi.ApiV2010Call, error) {
 	return call, err
 }
 
+// renderPrompts fills in the {{variable}} placeholders in the agent's system prompt, initial message and flow nodes
+func (c *CallOrchestrator) renderPrompts() {
+	vars, err := prompts.Resolve(c.agent, c.call, time.Now())
+	if err != nil {
+		logger.S.Warnf("rendering prompts for call %v: %v", c.call.ID, err)
+	}
+
+	c.agent.SystemPrompt = prompts.Render(c.agent.SystemPrompt, vars)
+	c.agent.InitialMessage = prompts.Render(c.agent.InitialMessage, vars)
+	c.agent.HandoffMessage = prompts.Render(c.agent.HandoffMessage, vars)
+	if c.agent.Flow != nil {
+		for i := range c.agent.Flow.Nodes {
+			c.agent.Flow.Nodes[i].Prompt = prompts.Render(c.agent.Flow.Nodes[i].Prompt, vars)
+		}
+	}
+}
+
+// setAgent finds the agent that owns the call's phone number, or the agent the call was routed to
 func (c *CallOrchestrator) setAgent(call *twilioApi.ApiV2010Call) error {
 	toPhoneNumber := call.To
 	fromPhoneNumber := call.From
@@ -303,6 +353,17 @@ func (c *CallOrchestrator) setAgent(call *twilioApi.ApiV2010Call) error {
 	if result.Error != nil {
 		return result.Error
 	}
+	c.phoneNumber = agent.PhoneNumber
+
+	// Routing rules can hand the number's calls to another of the user's agents
+	if agentId, ok := c.parameters["agent_id"].(string); ok && agentId != "" {
+		var routed models.Agent
+		if err := c.db.Where("id = ? AND user_id = ?", agentId, agent.UserId).First(&routed).Error; err != nil {
+			logger.S.Warnf("routed agent %v not found, agent %v is answering: %v", agentId, agent.ID, err)
+		} else {
+			agent = routed
+		}
+	}
 
 	c.agent = &agent
 
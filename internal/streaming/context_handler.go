@@ -1,23 +1,20 @@
 package streaming
 
 import (
+	"fmt"
+
 	"github.com/flyflow-devs/flyflow/internal/logger"
-	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/ownership"
 )
 
-func (c *CallOrchestrator) handleUpdatingContext() {
-	for {
-		if c.done {
-			break
-		}
-
-		var callWithContext models.Call
-		result := c.db.Where("sid = ?", c.call.Sid).First(&callWithContext)
-		if result.Error != nil {
-			logger.S.Errorf("error getting call context: %v", result.Error)
-			continue
-		}
-
-		c.call.Context = callWithContext.Context
+// handleCommand applies a command sent to the call through the API, from this instance or forwarded by another
+func (c *CallOrchestrator) handleCommand(command ownership.Command) error {
+	switch command.Type {
+	case ownership.CommandSetContext:
+		logger.S.Infof("updating context of call %v", c.call.ID)
+		c.call.Context = command.Context
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q", command.Type)
 	}
-}
\ No newline at end of file
+}
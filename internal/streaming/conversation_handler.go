@@ -1,48 +1,36 @@
 package streaming
 
 import (
-	"context"
 	"fmt"
+	"github.com/flyflow-devs/flyflow/internal/endpointing"
+	"github.com/flyflow-devs/flyflow/internal/llm"
 	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/telemetry"
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"time"
 )
 
-type LLM struct {
-	BaseURL string
-	Model   string
-	APIKey  string
-}
+// Number of state changes that can wait for the conversation loop
+const stateChangeBuffer = 16
 
-func (c *CallOrchestrator) getLLM(model string) LLM {
-	llms := map[string]LLM{
-		"gpt-4o": {
-			BaseURL: "https://api.openai.com/v1",
-			Model: "gpt-4o",
-			APIKey: c.cfg.OpenAIAPIKey,
-		},
-		"flyflow-voice": {
-			BaseURL: "https://api.fireworks.ai/inference/v1",
-			Model: "accounts/fireworks/models/llama-v3-70b-instruct",
-			APIKey: c.cfg.FireworksAPIKey,
-		},
-	}
-	llm, ok := llms[model]
-	if !ok {
-		return llms["gpt-4o"]
-	}
-	return llm
+func (c *CallOrchestrator) getLLM(model string) llm.LLM {
+	return llm.Get(c.cfg, model)
 }
 
 func (c *CallOrchestrator) handleLLM() {
+	llmAgent := c.agent
 	llm := c.getLLM(c.agent.LLMModel)
 	openaiConfig := openai.DefaultConfig(llm.APIKey)
 	openaiConfig.BaseURL = llm.BaseURL
 	openaiClient := openai.NewClientWithConfig(openaiConfig)
+	endpointer := endpointing.New(c.cfg, c.classifier, c.agent)
 
 	c.call.Transcript = append(c.call.Transcript, openai.ChatCompletionMessage{
 		Role:    openai.ChatMessageRoleSystem,
-		Content: c.agent.SystemPrompt,
+		Content: c.systemPrompt(),
 	})
 
 	if c.agent.InitialMessage != "" {
@@ -52,11 +40,6 @@ func (c *CallOrchestrator) handleLLM() {
 		})
 	}
 
-	threshold := c.agent.SmartEndpointingThreshold
-	if threshold == 0 {
-		threshold = 70
-	}
-
 	transcript := ""
 	previousFillerWord := ""
 
@@ -65,13 +48,15 @@ func (c *CallOrchestrator) handleLLM() {
 			break
 		}
 
+		c.applyStateChanges()
+
 		// Ignore any messages when it's the assistants turn to talk
 		if c.turn == "assistant" {
 			continue
 		}
 
 		if transcript == "" {
-			transcript, _ = <-c.transcriptionsChan
+			transcript = c.nextTranscript()
 			c.call.Transcript = append(c.call.Transcript, openai.ChatCompletionMessage{
 				Role:    openai.ChatMessageRoleUser,
 				Content: transcript,
@@ -80,35 +65,45 @@ func (c *CallOrchestrator) handleLLM() {
 
 		c.generatingText = true
 
-		ctx := context.Background()
-
-		c.call.Transcript[0].Content = fmt.Sprintf("%s \n\nExtra Context \n\n %s", c.agent.SystemPrompt, c.call.Context)
+		var turnSpan trace.Span
+		c.turnCtx, turnSpan = telemetry.StartSpan(c.ctx, "turn")
+
+		// Switch models when the call has been handed off to another agent
+		if c.agent != llmAgent {
+			llmAgent = c.agent
+			llm = c.getLLM(c.agent.LLMModel)
+			openaiConfig = openai.DefaultConfig(llm.APIKey)
+			openaiConfig.BaseURL = llm.BaseURL
+			openaiClient = openai.NewClientWithConfig(openaiConfig)
+			endpointer = endpointing.New(c.cfg, c.classifier, c.agent)
+		}
 
-		probabilityChan := make(chan uint)
-		go c.smartEndpointing(c.call.Transcript[1:], probabilityChan)
+		threshold := c.agent.SmartEndpointingThreshold
+		if threshold == 0 {
+			threshold = endpointing.DefaultThreshold
+		}
 
-		completionChan := make(chan string)
+		decisionChan := make(chan *models.EndpointingDecision, 1)
 		go func() {
-			resp, err := openaiClient.CreateChatCompletion(
-				ctx,
-				openai.ChatCompletionRequest{
-					Model:    llm.Model,
-					Messages: c.call.Transcript,
-				},
-			)
-			if err != nil {
-				logger.S.Error("error getting openai response ", err)
-				completionChan <- ""
-			}
-
-			completionChan <- resp.Choices[0].Message.Content
+			decisionChan <- c.endpoint(endpointer, threshold)
 		}()
 
+		// Flow conditions are checked alongside endpointing and only applied once the turn is committed
+		evaluation := c.evaluateFlowTransitions(openaiClient, llm.Model)
+
+		// Knowledge is looked up alongside endpointing too, the response is speculated with the last turn's
+		knowledge := c.lastKnowledge
+		retrieval := c.startKnowledgeRetrieval()
+		c.updateSystemPrompt(knowledge)
 
-		probability := <-probabilityChan
-		logger.S.Infof("Smart endpointing probability: %d", probability)
+		// Start on the response while endpointing decides whether the caller is done
+		spec := c.speculate(openaiClient, llm.Model, llm.Vendor)
 
-		if probability >= threshold {
+		decision := <-decisionChan
+		c.metrics.recordEndpointing(time.Duration(decision.LatencyMs) * time.Millisecond)
+
+		if decision.Probability >= threshold {
+			c.logEndpointing(decision)
 			c.turn = "assistant"
 			if c.agent.FillerWords {
 				fillerWord := c.classifier.GetFillerWord(transcript, c.agent.FillerWordsWhitelist, previousFillerWord)
@@ -118,24 +113,31 @@ func (c *CallOrchestrator) handleLLM() {
 					c.responseChan <- fillerWord
 				}
 			}
-			fullMessage := <- completionChan
-			c.responseChan <- fullMessage
-			c.call.Transcript = append(c.call.Transcript, openai.ChatCompletionMessage{
-				Role:    openai.ChatMessageRoleAssistant,
-				Content: fullMessage,
-			})
+			spec = c.respeculateForTurn(evaluation, retrieval, spec, knowledge, openaiClient, llm.Model, llm.Vendor)
+			c.respond(spec)
 			transcript = ""
+			turnSpan.End()
 		} else {
-			timer := time.NewTimer(calcBackoff(threshold, probability, transcript))
+			backoff := endpointing.Wait(endpointer, threshold, decision.Probability, decision.Transcript)
+			decision.WaitMs = backoff.Milliseconds()
+			timer := time.NewTimer(backoff)
 			select {
 			case newTranscript := <-c.transcriptionsChan:
 				logger.S.Infof("new transcript: %v", newTranscript)
+				decision.Continued = true
+				c.logEndpointing(decision)
+				spec.discard(c.metrics)
+				evaluation.discard()
+				retrieval.discard()
+				turnSpan.SetAttributes(attribute.Bool("turn.continued", true))
+				turnSpan.End()
 				c.call.Transcript = append(c.call.Transcript, openai.ChatCompletionMessage{
 					Role:    openai.ChatMessageRoleUser,
 					Content: newTranscript,
 				})
 				timer.Stop()
 			case <-timer.C:
+				c.logEndpointing(decision)
 				c.turn = "assistant"
 				if c.agent.FillerWords {
 					fillerWord := c.classifier.GetFillerWord(transcript, c.agent.FillerWordsWhitelist, previousFillerWord)
@@ -145,13 +147,10 @@ func (c *CallOrchestrator) handleLLM() {
 						c.responseChan <- fillerWord
 					}
 				}
-				fullMessage := <- completionChan
-				c.responseChan <- fullMessage
-				c.call.Transcript = append(c.call.Transcript, openai.ChatCompletionMessage{
-					Role:    openai.ChatMessageRoleAssistant,
-					Content: fullMessage,
-				})
+				spec = c.respeculateForTurn(evaluation, retrieval, spec, knowledge, openaiClient, llm.Model, llm.Vendor)
+				c.respond(spec)
 				transcript = ""
+				turnSpan.End()
 			}
 		}
 
@@ -159,9 +158,64 @@ func (c *CallOrchestrator) handleLLM() {
 	}
 }
 
-func calcBackoff(threshold uint, probability uint, userSentence string) time.Duration {
-	// Calculate the initial backoff
-	backoff := time.Duration(500*(threshold-probability)/10) * time.Millisecond
+// updateSystemPrompt rebuilds the system prompt for the current node with the call's context, the retrieved
+// knowledge and the caller's history
+func (c *CallOrchestrator) updateSystemPrompt(knowledge string) {
+	c.call.Transcript[0].Content = fmt.Sprintf("%s \n\nExtra Context \n\n %s", c.systemPrompt(), c.call.Context)
+	if knowledge != "" {
+		c.call.Transcript[0].Content += fmt.Sprintf("\n\nKnowledge Base (use these excerpts to answer if they are relevant)\n\n%s", knowledge)
+	}
+	if c.callerMemory != "" {
+		c.call.Transcript[0].Content += fmt.Sprintf("\n\nCaller History (your previous calls with this caller, oldest first)\n\n%s", c.callerMemory)
+	}
+}
+
+// respeculateForTurn applies the flow transition and the knowledge retrieved for the committed turn. The
+// speculated response was written before either was in, so when they change the prompt it's generated again.
+func (c *CallOrchestrator) respeculateForTurn(evaluation *flowEvaluation, retrieval *knowledgeRetrieval, spec *speculation, knowledge string, client *openai.Client, model string, vendor string) *speculation {
+	changed := c.applyFlowTransition(evaluation)
+	if retrieved, ok := c.finishKnowledgeRetrieval(retrieval); ok && retrieved != knowledge {
+		knowledge = retrieved
+		changed = true
+	}
+	if !changed {
+		return spec
+	}
+	spec.discard(c.metrics)
+	c.updateSystemPrompt(knowledge)
+	return c.speculate(client, model, vendor)
+}
+
+// nextTranscript waits for the caller to say something, applying state changes while the call is quiet
+func (c *CallOrchestrator) nextTranscript() string {
+	for {
+		select {
+		case transcript := <-c.transcriptionsChan:
+			return transcript
+		case change := <-c.stateChanges:
+			change()
+		}
+	}
+}
+
+// changeState hands a change to the conversation loop. The loop reads the flow node, agent and transcript
+// throughout a turn, so other goroutines never change them directly.
+func (c *CallOrchestrator) changeState(change func()) {
+	select {
+	case c.stateChanges <- change:
+	default:
+		logger.S.Errorf("dropping state change for call %v, the conversation loop is behind", c.call.ID)
+	}
+}
 
-	return backoff
-}
\ No newline at end of file
+// applyStateChanges applies the changes handed to the conversation loop since the last turn
+func (c *CallOrchestrator) applyStateChanges() {
+	for {
+		select {
+		case change := <-c.stateChanges:
+			change()
+		default:
+			return
+		}
+	}
+}
@@ -3,10 +3,13 @@ package streaming
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	api "github.com/deepgram/deepgram-go-sdk/pkg/api/live/v1/interfaces"
 	"github.com/deepgram/deepgram-go-sdk/pkg/client/interfaces"
 	client "github.com/deepgram/deepgram-go-sdk/pkg/client/live"
 	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/telemetry"
 	"strconv"
 	"time"
 )
@@ -15,25 +18,50 @@ func (c *CallOrchestrator) Message(mr *api.MessageResponse) error {
 	if mr.Channel.Alternatives[0].Confidence > 0 {
 		logger.S.Infof("transcript: %v", mr.Channel.Alternatives[0].Transcript)
 	}
+	transcript := mr.Channel.Alternatives[0].Transcript
+	mode := c.bargeInMode()
+
 	// Don't send transcripts while we're generating a response
-	if mr.Channel.Alternatives[0].Transcript != "" && mr.IsFinal == true {
+	if transcript != "" && mr.IsFinal == true {
+		// Acknowledgements while the agent is speaking don't take the turn
+		if c.isBackchannel(transcript, mr.Duration) {
+			return nil
+		}
 		c.userSpeaking = false
-		c.interruptionChan <- true
+		switch {
+		// The agent finishes what it's saying
+		case mode == models.BargeInModeNever && len(c.marks) > 0:
+		// With VAD, barge-in has already cut the agent off if the caller talked over it
+		case !c.usesVAD():
+			c.interruptionChan <- true
+		// Too short for VAD to barge in on its own in smart mode
+		case mode == models.BargeInModeSmart && len(c.marks) > 0:
+			c.call.Interruptions++
+			c.interruptionChan <- true
+		}
 		c.metrics.startProcessing()
+		c.recordSTTFinalization(mr)
 		c.userLastSpoke = time.Now()
 		c.lastFinalizedMessage = time.Now()
 		if c.turn != "assistant" {
-			c.transcriptionsChan <- mr.Channel.Alternatives[0].Transcript
+			c.transcriptionsChan <- transcript
 		}
 	}
 
-	if mr.Channel.Alternatives[0].Transcript != "" && mr.IsFinal == false && mr.Channel.Alternatives[0].Confidence > 0.5 {
+	interim := transcript != "" && mr.IsFinal == false && mr.Channel.Alternatives[0].Confidence > 0.5
+	if interim && (!c.usesVAD() || (mode == models.BargeInModeSmart && len(c.marks) > 0)) {
 		// Sometimes deepgram sends an unfinalized and then finalized transcript in rapid order,
-		if time.Since(c.lastFinalizedMessage) > 2 * time.Second {
-			c.userSpeaking = true
-			c.interruptionChan <- true
-			c.userLastSpoke = time.Now()
-			c.turn = "user"
+		if time.Since(c.lastFinalizedMessage) > 2 * time.Second && !c.isBackchannel(transcript, mr.Duration) {
+			if len(c.marks) > 0 {
+				if mode != models.BargeInModeNever {
+					c.bargeIn()
+				}
+			} else {
+				c.userSpeaking = true
+				c.interruptionChan <- true
+				c.userLastSpoke = time.Now()
+				c.turn = "user"
+			}
 		}
 
 	}
@@ -41,6 +69,16 @@ func (c *CallOrchestrator) Message(mr *api.MessageResponse) error {
 	return nil
 }
 
+// recordSTTFinalization measures how long after the caller stopped talking the final transcript arrived.
+// Audio is streamed in real time, so its offsets line up with the time since the first chunk was sent.
+func (c *CallOrchestrator) recordSTTFinalization(mr *api.MessageResponse) {
+	if c.sttStartedAt.IsZero() {
+		return
+	}
+	speechEnd := c.sttStartedAt.Add(time.Duration((mr.Start + mr.Duration) * float64(time.Second)))
+	c.metrics.recordSTTFinalization(max(time.Since(speechEnd), 0))
+}
+
 func (c *CallOrchestrator) Open(ocr *api.OpenResponse) error {
 	return nil
 }
@@ -64,6 +102,7 @@ func (c *CallOrchestrator) Close(ocr *api.CloseResponse) error {
 func (c *CallOrchestrator) Error(er *api.ErrorResponse) error {
 	// handle the error
 	logger.S.Errorf("error from deepgram: %v", er.Message)
+	telemetry.VendorRequests.WithLabelValues("deepgram", "transcribe", telemetry.OutcomeError).Inc()
 	return nil
 }
 
@@ -121,12 +160,15 @@ func (c *CallOrchestrator) handleTranscripts() {
 	}
 
 	// connect the websocket to Deepgram
+	_, end := telemetry.StartVendorCall(c.ctx, "deepgram", "connect")
 	wsconn := dgClient.Connect()
 	if wsconn == nil {
+		end(errors.New("deepgram client connection failed"))
 		logger.S.Errorf("deepgram client connection failed")
 		c.doneChan <- true
 		return
 	}
+	end(nil)
 
 	for {
 		if c.done {
@@ -145,5 +187,8 @@ func (c *CallOrchestrator) handleTranscripts() {
 			logger.S.Error("error writing to the deegram client", err)
 			continue
 		}
+		if c.sttStartedAt.IsZero() {
+			c.sttStartedAt = time.Now()
+		}
 	}
 }
\ No newline at end of file
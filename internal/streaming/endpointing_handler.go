@@ -0,0 +1,74 @@
+package streaming
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/endpointing"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/telemetry"
+	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// callerTurn returns what the caller said since the agent last spoke and what the agent said before that
+func (c *CallOrchestrator) callerTurn() (string, string) {
+	var parts []string
+	i := len(c.call.Transcript) - 1
+	for ; i > 0 && c.call.Transcript[i].Role == openai.ChatMessageRoleUser; i-- {
+		parts = append([]string{c.call.Transcript[i].Content}, parts...)
+	}
+
+	assistantMessage := ""
+	if i > 0 && c.call.Transcript[i].Role == openai.ChatMessageRoleAssistant {
+		assistantMessage = c.call.Transcript[i].Content
+	}
+	return strings.Join(parts, " "), assistantMessage
+}
+
+// endpoint asks the endpointer whether the caller has finished their turn. The agent responds anyway when
+// the endpointer fails or takes too long.
+func (c *CallOrchestrator) endpoint(endpointer endpointing.Endpointer, threshold uint) *models.EndpointingDecision {
+	transcript, assistantMessage := c.callerTurn()
+	decision := &models.EndpointingDecision{
+		CallId:           c.call.ID,
+		AgentId:          c.agent.ID,
+		Endpointer:       endpointer.Name(),
+		Transcript:       transcript,
+		AssistantMessage: assistantMessage,
+		Threshold:        threshold,
+	}
+
+	ctx, cancel := context.WithTimeout(c.turnCtx, endpointing.Timeout)
+	defer cancel()
+	ctx, span := telemetry.StartSpan(ctx, "endpointing", attribute.String("endpointer", endpointer.Name()))
+
+	start := time.Now()
+	probability, err := endpointer.Probability(ctx, endpointing.Turn{
+		Messages:   append([]openai.ChatCompletionMessage{}, c.call.Transcript[1:]...),
+		Transcript: transcript,
+	})
+	decision.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		logger.S.Warnf("%s endpointing failed after %v ms, responding: %v", endpointer.Name(), decision.LatencyMs, err)
+		decision.Failed = true
+		probability = 100
+	}
+	decision.Probability = probability
+	span.SetAttributes(attribute.Int("probability", int(probability)))
+	telemetry.EndSpan(span, err)
+
+	logger.S.Infof("%s endpointing probability %d in %v ms", endpointer.Name(), probability, decision.LatencyMs)
+	return decision
+}
+
+// logEndpointing stores the decision once its outcome is known so it can be used for training
+func (c *CallOrchestrator) logEndpointing(decision *models.EndpointingDecision) {
+	go func() {
+		if err := c.db.Create(decision).Error; err != nil {
+			logger.S.Errorf("error logging endpointing decision for call %v: %v", decision.CallId, err)
+		}
+	}()
+}
@@ -0,0 +1,295 @@
+package streaming
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// Longest the transition decision runs, it starts alongside endpointing
+	flowTransitionTimeout = 1500 * time.Millisecond
+
+	// How long a committed response waits for a decision that's still running, it's skipped after that
+	flowTransitionWait = 300 * time.Millisecond
+
+	// Number of recent transcript messages the transition decision sees
+	flowTransitionHistory = 8
+
+	flowTransitionFunction = "transition"
+)
+
+// startFlow puts the call in the flow's initial node
+func (c *CallOrchestrator) startFlow() {
+	if c.agent.Flow == nil {
+		return
+	}
+
+	// Calls that reconnect keep the node they were in
+	if c.call.CurrentNode != "" && c.agent.Flow.Node(c.call.CurrentNode) != nil {
+		return
+	}
+
+	c.transitionTo(c.agent.Flow.Start(), "call_started")
+}
+
+// currentNode returns the flow node the call is in, or nil if the agent has no flow
+func (c *CallOrchestrator) currentNode() *models.FlowNode {
+	if c.agent.Flow == nil {
+		return nil
+	}
+	return c.agent.Flow.Node(c.call.CurrentNode)
+}
+
+// systemPrompt combines the agent's system prompt with the prompt of the current flow node
+func (c *CallOrchestrator) systemPrompt() string {
+	node := c.currentNode()
+	if node == nil {
+		return c.agent.SystemPrompt
+	}
+	return fmt.Sprintf("%s\n\nCurrent Step: %s\n\n%s", c.agent.SystemPrompt, node.Name, node.Prompt)
+}
+
+// currentTools returns the agent's tools along with the tools of the current flow node
+func (c *CallOrchestrator) currentTools() []openai.Tool {
+	node := c.currentNode()
+	if node == nil {
+		return c.agent.Tools
+	}
+	return append(append([]openai.Tool{}, c.agent.Tools...), node.Tools...)
+}
+
+// flowHasTools reports whether any node in the agent's flow defines tools
+func (c *CallOrchestrator) flowHasTools() bool {
+	if c.agent.Flow == nil {
+		return false
+	}
+	for _, node := range c.agent.Flow.Nodes {
+		if len(node.Tools) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CallOrchestrator) transitionTo(target string, reason string) {
+	from := c.call.CurrentNode
+	transition := models.NodeTransition{
+		From:   from,
+		To:     target,
+		Reason: reason,
+		At:     time.Now(),
+	}
+
+	c.call.CurrentNode = target
+	c.call.NodeTransitions = append(c.call.NodeTransitions, transition)
+	logger.S.Infof("flow transition from %q to %q: %s", from, target, reason)
+
+	c.EmitEvent("node_transition", transition)
+}
+
+// flowTransition is a move to another node decided from the transcript
+type flowTransition struct {
+	target string
+	reason string
+}
+
+// flowEvaluation is a transition decision running alongside endpointing
+type flowEvaluation struct {
+	result chan *flowTransition
+	cancel context.CancelFunc
+}
+
+// discard abandons the decision once the caller keeps talking
+func (e *flowEvaluation) discard() {
+	if e != nil {
+		e.cancel()
+	}
+}
+
+// evaluateFlowTransitions asks the agent's LLM whether any of the current node's conditions have been met.
+// It runs alongside endpointing on a snapshot of the transcript and sends the transition to take, or nil,
+// on the evaluation's channel. The evaluation is nil when the node has no conditions.
+func (c *CallOrchestrator) evaluateFlowTransitions(client *openai.Client, model string) *flowEvaluation {
+	node := c.currentNode()
+	if node == nil {
+		return nil
+	}
+
+	var conditions []models.FlowTransition
+	var targets []string
+	for _, transition := range node.Transitions {
+		if transition.Condition != "" {
+			conditions = append(conditions, transition)
+			targets = append(targets, transition.Target)
+		}
+	}
+	if len(conditions) == 0 {
+		return nil
+	}
+
+	var instructions strings.Builder
+	instructions.WriteString(fmt.Sprintf("You are tracking the progress of a phone conversation that is currently in the step %q.\n\n", node.Name))
+	instructions.WriteString("Call the transition function only if one of the following conditions has been met, otherwise reply with the word none.\n\n")
+	for _, transition := range conditions {
+		instructions.WriteString(fmt.Sprintf("- Move to %q when: %s\n", transition.Target, transition.Condition))
+	}
+
+	messages := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleSystem, Content: instructions.String()}}
+	history := c.call.Transcript[1:]
+	if len(history) > flowTransitionHistory {
+		history = history[len(history)-flowTransitionHistory:]
+	}
+	messages = append(messages, history...)
+
+	ctx, cancel := context.WithTimeout(c.turnCtx, flowTransitionTimeout)
+	evaluation := &flowEvaluation{result: make(chan *flowTransition, 1), cancel: cancel}
+	go func() {
+		defer cancel()
+		evaluation.result <- c.decideFlowTransition(ctx, client, model, messages, targets)
+	}()
+	return evaluation
+}
+
+func (c *CallOrchestrator) decideFlowTransition(ctx context.Context, client *openai.Client, model string, messages []openai.ChatCompletionMessage, targets []string) *flowTransition {
+	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model:    model,
+		Messages: messages,
+		Tools: []openai.Tool{{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        flowTransitionFunction,
+				Description: "Move the conversation to the next step",
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"target": map[string]interface{}{
+							"type": "string",
+							"enum": targets,
+						},
+						"reason": map[string]interface{}{
+							"type":        "string",
+							"description": "Which condition was met",
+						},
+					},
+					"required": []string{"target"},
+				},
+			},
+		}},
+	})
+	if err != nil {
+		if ctx.Err() == nil || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			logger.S.Errorf("error evaluating flow transitions: %v", err)
+		}
+		return nil
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil
+	}
+	for _, toolCall := range resp.Choices[0].Message.ToolCalls {
+		if toolCall.Function.Name != flowTransitionFunction {
+			continue
+		}
+
+		var args struct {
+			Target string `json:"target"`
+			Reason string `json:"reason"`
+		}
+		if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+			logger.S.Errorf("error parsing flow transition arguments: %v", err)
+			return nil
+		}
+
+		reason := "condition"
+		if args.Reason != "" {
+			reason = fmt.Sprintf("condition: %s", args.Reason)
+		}
+		return &flowTransition{target: args.Target, reason: reason}
+	}
+	return nil
+}
+
+// applyFlowTransition moves the call once the evaluation of the committed turn is in, reporting whether it
+// did so the response can be regenerated with the new node's prompt. A decision that isn't in shortly after
+// the turn is committed is abandoned rather than holding up the response.
+func (c *CallOrchestrator) applyFlowTransition(evaluation *flowEvaluation) bool {
+	if evaluation == nil {
+		return false
+	}
+	defer evaluation.cancel()
+
+	var transition *flowTransition
+	select {
+	case transition = <-evaluation.result:
+	case <-time.After(flowTransitionWait):
+		logger.S.Warnf("flow transition decision for call %v took too long, skipping it", c.call.ID)
+		return false
+	}
+	if transition == nil {
+		return false
+	}
+	if c.agent.Flow == nil || c.agent.Flow.Node(transition.target) == nil {
+		logger.S.Warnf("flow transition to unknown node %q", transition.target)
+		return false
+	}
+
+	c.transitionTo(transition.target, transition.reason)
+	return true
+}
+
+// handleFlowToolCalls hands the tool calls to the conversation loop, which fires the current node's tool
+// call transitions
+func (c *CallOrchestrator) handleFlowToolCalls(toolCalls []openai.ToolCall) {
+	c.changeState(func() {
+		c.applyFlowToolCalls(toolCalls)
+	})
+}
+
+func (c *CallOrchestrator) applyFlowToolCalls(toolCalls []openai.ToolCall) {
+	node := c.currentNode()
+	if node == nil {
+		return
+	}
+
+	for _, toolCall := range toolCalls {
+		for _, transition := range node.Transitions {
+			if transition.ToolCall == "" || transition.ToolCall != toolCall.Function.Name {
+				continue
+			}
+			if !argumentsMatch(toolCall.Function.Arguments, transition.Arguments) {
+				continue
+			}
+
+			c.transitionTo(transition.Target, fmt.Sprintf("tool_call: %s", toolCall.Function.Name))
+			return
+		}
+	}
+}
+
+// argumentsMatch reports whether every expected argument is present in the tool call with the expected value
+func argumentsMatch(arguments string, expected map[string]string) bool {
+	if len(expected) == 0 {
+		return true
+	}
+
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return false
+	}
+
+	for name, value := range expected {
+		actual, ok := args[name]
+		if !ok || fmt.Sprint(actual) != value {
+			return false
+		}
+	}
+	return true
+}
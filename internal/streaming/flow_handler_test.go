@@ -0,0 +1,78 @@
+package streaming
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/sashabaranov/go-openai"
+)
+
+func newFlowOrchestrator(t *testing.T) *CallOrchestrator {
+	t.Helper()
+	if logger.S == nil {
+		logger.InitLogger("test")
+	}
+	flow := &models.Flow{Nodes: []models.FlowNode{
+		{Name: "greeting", Transitions: []models.FlowTransition{{Target: "booking", ToolCall: "book_table"}}},
+		{Name: "booking"},
+	}}
+	return &CallOrchestrator{
+		agent:        &models.Agent{Flow: flow},
+		call:         &models.Call{CurrentNode: "greeting"},
+		stateChanges: make(chan func(), stateChangeBuffer),
+	}
+}
+
+func TestApplyFlowTransition(t *testing.T) {
+	c := newFlowOrchestrator(t)
+	if c.applyFlowTransition(nil) {
+		t.Fatal("applied a transition without an evaluation")
+	}
+
+	// A decision that's in when the turn is committed is applied
+	ctx, cancel := context.WithCancel(context.Background())
+	evaluation := &flowEvaluation{result: make(chan *flowTransition, 1), cancel: cancel}
+	evaluation.result <- &flowTransition{target: "booking", reason: "condition"}
+	if !c.applyFlowTransition(evaluation) || c.call.CurrentNode != "booking" {
+		t.Fatalf("got node %q, want booking", c.call.CurrentNode)
+	}
+	if ctx.Err() == nil {
+		t.Error("evaluation wasn't cancelled once applied")
+	}
+
+	// A decision that's still running is abandoned instead of holding up the response
+	c.call.CurrentNode = "greeting"
+	ctx, cancel = context.WithCancel(context.Background())
+	evaluation = &flowEvaluation{result: make(chan *flowTransition, 1), cancel: cancel}
+	start := time.Now()
+	if c.applyFlowTransition(evaluation) || c.call.CurrentNode != "greeting" {
+		t.Fatalf("applied a decision that never came in, node is %q", c.call.CurrentNode)
+	}
+	if waited := time.Since(start); waited > flowTransitionTimeout {
+		t.Errorf("waited %v for the decision", waited)
+	}
+	if ctx.Err() == nil {
+		t.Error("abandoned evaluation wasn't cancelled")
+	}
+}
+
+func TestFlowToolCallsAreAppliedByTheConversationLoop(t *testing.T) {
+	c := newFlowOrchestrator(t)
+	toolCalls := []openai.ToolCall{{Function: openai.FunctionCall{Name: "book_table", Arguments: "{}"}}}
+
+	c.handleFlowToolCalls(toolCalls)
+	if c.call.CurrentNode != "greeting" {
+		t.Fatalf("tool call moved the call to %q outside of the conversation loop", c.call.CurrentNode)
+	}
+
+	c.applyStateChanges()
+	if c.call.CurrentNode != "booking" {
+		t.Errorf("got node %q after applying state changes, want booking", c.call.CurrentNode)
+	}
+	if len(c.call.NodeTransitions) != 1 || c.call.NodeTransitions[0].Reason != "tool_call: book_table" {
+		t.Errorf("got transitions %+v", c.call.NodeTransitions)
+	}
+}
@@ -0,0 +1,118 @@
+package streaming
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/sashabaranov/go-openai"
+)
+
+// handoffTargets returns the agents the handoff action can pass the call to
+func (c *CallOrchestrator) handoffTargets(action models.Action) []models.Agent {
+	if len(action.AgentIds) == 0 {
+		return nil
+	}
+
+	var agents []models.Agent
+	err := c.db.Where("id IN ? AND user_id = ? AND id <> ?", action.AgentIds, c.agent.UserId, c.agent.ID).
+		Order("id").
+		Find(&agents).Error
+	if err != nil {
+		logger.S.Errorf("error loading handoff agents: %v", err)
+	}
+	return agents
+}
+
+// currentAgent returns the agent running the call, for goroutines other than the conversation loop
+func (c *CallOrchestrator) currentAgent() *models.Agent {
+	c.agentLock.RLock()
+	defer c.agentLock.RUnlock()
+	return c.agent
+}
+
+// canHandoff reports whether the agent has a handoff action
+func (c *CallOrchestrator) canHandoff() bool {
+	for _, action := range c.agent.Actions {
+		if action.Name == "handoff" {
+			return true
+		}
+	}
+	return false
+}
+
+// handoff passes the conversation to another of the user's agents. The new agent keeps the transcript and
+// takes over the voice, prompt, tools and actions for the rest of the call.
+func (c *CallOrchestrator) handoff(agentName string, reason string) error {
+	var target *models.Agent
+	for _, action := range c.agent.Actions {
+		if action.Name != "handoff" {
+			continue
+		}
+		for _, agent := range c.handoffTargets(action) {
+			if agent.Name == agentName {
+				target = &agent
+				break
+			}
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("agent %q is not a handoff target of agent %v", agentName, c.agent.ID)
+	}
+
+	previous := c.agent
+	handoff := models.AgentHandoff{
+		FromAgentId: previous.ID,
+		ToAgentId:   target.ID,
+		Reason:      reason,
+		At:          time.Now(),
+	}
+	logger.S.Infof("handing call %v off from agent %v to agent %v: %s", c.call.ID, previous.ID, target.ID, reason)
+
+	c.agentLock.Lock()
+	c.agent = target
+	c.renderPrompts()
+	c.agentLock.Unlock()
+
+	// Knowledge, caller memory and the flow all belong to the agent
+	c.knowledgeIndex = nil
+	c.lastKnowledgeQuery = ""
+	c.lastKnowledge = ""
+	c.loadKnowledge()
+	c.callerMemory = ""
+	c.loadCallerMemory()
+	c.call.CurrentNode = ""
+	if c.agent.Flow != nil {
+		c.transitionTo(c.agent.Flow.Start(), "handoff")
+	}
+
+	note := fmt.Sprintf("The call was handed off from %s to you (%s).", previous.Name, target.Name)
+	if reason != "" {
+		note += " Reason: " + reason
+	}
+	c.call.Transcript = append(c.call.Transcript, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: note,
+	})
+	c.call.Handoffs = append(c.call.Handoffs, handoff)
+	_ = c.saveCall()
+
+	c.EmitEvent("handoff", handoff)
+
+	// Announce the new agent in its own voice
+	announcement := c.agent.HandoffMessage
+	if announcement == "" {
+		announcement = c.agent.InitialMessage
+	}
+	if announcement != "" {
+		c.call.Transcript = append(c.call.Transcript, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleAssistant,
+			Content: announcement,
+		})
+		c.metrics.startProcessing()
+		c.responseChan <- announcement
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package streaming
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	defaultReminderMessage = "Are you still there?"
+	defaultGoodbyeMessage  = "It seems like you've stepped away, so I'll end the call here. Goodbye!"
+	defaultMaxCallMessage  = "We've reached the time limit for this call, so I'll need to end it here. Goodbye!"
+
+	// Unanswered reminders before hanging up when the policy doesn't set max_reminders
+	defaultMaxReminders = 2
+
+	reminderTimeout = 5 * time.Second
+
+	// How long to wait for the agent to start saying goodbye before hanging up anyway
+	goodbyeStartTimeout = 5 * time.Second
+)
+
+// idleAction is what the idle policy calls for on a tick
+type idleAction int
+
+const (
+	idleWait idleAction = iota
+	idleRemind
+	idleGoodbye
+	idleMaxDuration
+)
+
+// idleTracker runs an agent's idle policy, counting the reminders the caller hasn't answered
+type idleTracker struct {
+	reminders    int
+	lastReminder time.Time
+}
+
+// next decides what to do at now. quiet is whether it's the caller's turn with nobody talking, silence only
+// counts then. A reminder is counted as sent when next returns idleRemind.
+func (t *idleTracker) next(policy models.IdlePolicy, now time.Time, startedAt time.Time, userLastSpoke time.Time, agentLastSpoke time.Time, quiet bool) idleAction {
+	if policy.MaxCallSeconds > 0 && now.Sub(startedAt) > time.Duration(policy.MaxCallSeconds)*time.Second {
+		return idleMaxDuration
+	}
+
+	if policy.ReminderAfterSeconds <= 0 || !quiet {
+		return idleWait
+	}
+
+	// Answering a reminder starts the count over
+	if userLastSpoke.After(t.lastReminder) {
+		t.reminders = 0
+	}
+
+	silentSince := userLastSpoke
+	if agentLastSpoke.After(silentSince) {
+		silentSince = agentLastSpoke
+	}
+	if t.lastReminder.After(silentSince) {
+		silentSince = t.lastReminder
+	}
+	if now.Sub(silentSince) < time.Duration(policy.ReminderAfterSeconds)*time.Second {
+		return idleWait
+	}
+
+	maxReminders := policy.MaxReminders
+	if maxReminders == 0 {
+		maxReminders = defaultMaxReminders
+	}
+	if t.reminders >= maxReminders {
+		return idleGoodbye
+	}
+
+	t.reminders++
+	t.lastReminder = now
+	return idleRemind
+}
+
+// handleIdle checks in with callers who go quiet, hangs up on callers who stop responding and ends calls
+// that run past the agent's time limit
+func (c *CallOrchestrator) handleIdle() {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	var tracker idleTracker
+	for range ticker.C {
+		if c.done {
+			return
+		}
+		policy := c.currentAgent().IdlePolicy
+
+		quiet := c.turn == "user" && !c.userSpeaking && !c.generatingText && len(c.marks) == 0
+		switch tracker.next(policy, time.Now(), c.call.StartedAt, c.userLastSpoke, c.agentLastSpoke, quiet) {
+		case idleMaxDuration:
+			message := policy.MaxCallMessage
+			if message == "" {
+				message = defaultMaxCallMessage
+			}
+			c.sayGoodbye(message, "max_duration")
+			return
+		case idleGoodbye:
+			message := policy.GoodbyeMessage
+			if message == "" {
+				message = defaultGoodbyeMessage
+			}
+			c.sayGoodbye(message, "idle_timeout")
+			return
+		case idleRemind:
+			c.agentLastSpoke = tracker.lastReminder
+
+			message := policy.ReminderMessage
+			if message == "" {
+				message = c.writeReminder()
+			}
+			c.EmitEvent("idle_reminder", map[string]interface{}{"reminder": tracker.reminders, "message": message})
+			c.say(message)
+		}
+	}
+}
+
+// writeReminder has the LLM write a short check in that fits the conversation
+func (c *CallOrchestrator) writeReminder() string {
+	agent := c.currentAgent()
+	llm := c.getLLM(agent.LLMModel)
+	openaiConfig := openai.DefaultConfig(llm.APIKey)
+	openaiConfig.BaseURL = llm.BaseURL
+	openaiClient := openai.NewClientWithConfig(openaiConfig)
+
+	messages := append([]openai.ChatCompletionMessage{}, c.call.Transcript...)
+	messages = append(messages, openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleSystem,
+		Content: fmt.Sprintf("The caller has been silent for %d seconds. Write one short sentence checking whether they're still there, "+
+			"picking up where the conversation left off.", agent.IdlePolicy.ReminderAfterSeconds),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), reminderTimeout)
+	defer cancel()
+
+	resp, err := openaiClient.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model:    llm.Model,
+		Messages: messages,
+	})
+	if err != nil || len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
+		logger.S.Warnf("error writing idle reminder, using the default: %v", err)
+		return defaultReminderMessage
+	}
+	return resp.Choices[0].Message.Content
+}
+
+// say speaks the message and adds it to the transcript
+func (c *CallOrchestrator) say(message string) {
+	c.turn = "assistant"
+	c.call.Transcript = append(c.call.Transcript, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleAssistant,
+		Content: message,
+	})
+	c.metrics.startProcessing()
+	c.responseChan <- message
+}
+
+// sayGoodbye speaks the message and hangs up once the agent has finished saying it
+func (c *CallOrchestrator) sayGoodbye(message string, reason string) {
+	logger.S.Infof("ending call %v: %s", c.call.ID, reason)
+	c.say(message)
+
+	// The audio is streamed in the background, give it a moment to start so hanging up waits for it
+	deadline := time.Now().Add(goodbyeStartTimeout)
+	for len(c.marks) == 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if err := c.hangupCall(reason); err != nil {
+		logger.S.Errorf("error hanging up call: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package streaming
+
+import (
+	"testing"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/models"
+)
+
+func TestIdleTracker(t *testing.T) {
+	start := time.Now()
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+
+	tests := []struct {
+		name   string
+		policy models.IdlePolicy
+		// Seconds into the call the caller last spoke before each tick, and the tick's time
+		ticks []struct{ spoke, now int }
+		want  []idleAction
+	}{
+		{
+			name:   "reminders off",
+			policy: models.IdlePolicy{},
+			ticks:  []struct{ spoke, now int }{{0, 60}, {0, 600}},
+			want:   []idleAction{idleWait, idleWait},
+		},
+		{
+			name:   "max reminders defaults to two",
+			policy: models.IdlePolicy{ReminderAfterSeconds: 10},
+			ticks:  []struct{ spoke, now int }{{0, 5}, {0, 10}, {0, 15}, {0, 20}, {0, 30}},
+			want:   []idleAction{idleWait, idleRemind, idleWait, idleRemind, idleGoodbye},
+		},
+		{
+			name:   "one reminder",
+			policy: models.IdlePolicy{ReminderAfterSeconds: 10, MaxReminders: 1},
+			ticks:  []struct{ spoke, now int }{{0, 10}, {0, 20}},
+			want:   []idleAction{idleRemind, idleGoodbye},
+		},
+		{
+			name:   "answering a reminder starts the count over",
+			policy: models.IdlePolicy{ReminderAfterSeconds: 10, MaxReminders: 1},
+			ticks:  []struct{ spoke, now int }{{0, 10}, {15, 20}, {15, 25}, {15, 35}},
+			want:   []idleAction{idleRemind, idleWait, idleRemind, idleGoodbye},
+		},
+		{
+			name:   "max call length",
+			policy: models.IdlePolicy{ReminderAfterSeconds: 10, MaxCallSeconds: 30},
+			ticks:  []struct{ spoke, now int }{{25, 30}, {25, 31}},
+			want:   []idleAction{idleWait, idleMaxDuration},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tracker idleTracker
+			for i, tick := range tt.ticks {
+				got := tracker.next(tt.policy, at(tick.now), start, at(tick.spoke), start, true)
+				if got != tt.want[i] {
+					t.Fatalf("tick %d at %ds: got action %d, want %d", i, tick.now, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestIdleTrackerOnlyCountsQuietSilence(t *testing.T) {
+	start := time.Now()
+	policy := models.IdlePolicy{ReminderAfterSeconds: 10}
+
+	var tracker idleTracker
+	if got := tracker.next(policy, start.Add(time.Minute), start, start, start, false); got != idleWait {
+		t.Errorf("got action %d while the agent was talking, want to wait", got)
+	}
+	if got := tracker.next(policy, start.Add(time.Minute), start, start, start.Add(55*time.Second), true); got != idleWait {
+		t.Errorf("got action %d 5s after the agent spoke, want to wait", got)
+	}
+	if got := tracker.next(policy, start.Add(time.Minute), start, start, start, true); got != idleRemind {
+		t.Errorf("got action %d after a minute of silence, want a reminder", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/flyflow-devs/flyflow/internal/logger"
 	"github.com/gorilla/websocket"
+	"time"
 )
 
 type TwilioMessage struct {
@@ -82,6 +83,7 @@ func (c *CallOrchestrator) handleInboundAudio() {
 				// If we have no more marks - then it's the user's turn to talk
 				if len(c.marks) == 0 {
 					c.turn = "user"
+					c.agentLastSpoke = time.Now()
 				}
 			}
 		} else if messageType == websocket.BinaryMessage {
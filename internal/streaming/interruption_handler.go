@@ -1,6 +1,41 @@
 package streaming
 
-import "github.com/flyflow-devs/flyflow/internal/logger"
+import (
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+)
+
+// bargeInMode is the agent's barge-in mode, smart when unset
+func (c *CallOrchestrator) bargeInMode() string {
+	agent := c.currentAgent()
+	if agent.BargeIn.Mode == "" {
+		return models.BargeInModeSmart
+	}
+	return agent.BargeIn.Mode
+}
+
+// bargeIn cuts the agent off because the caller is talking over it
+func (c *CallOrchestrator) bargeIn() {
+	c.userSpeaking = true
+	c.userLastSpoke = time.Now()
+	c.turn = "user"
+	c.call.Interruptions++
+	c.interruptionChan <- true
+}
+
+// isBackchannel reports whether the caller's transcript is only an acknowledgement of the agent speaking
+func (c *CallOrchestrator) isBackchannel(transcript string, duration float64) bool {
+	if c.bargeInMode() != models.BargeInModeSmart || len(c.marks) == 0 {
+		return false
+	}
+	if c.classifier.IsBackchannel(transcript, time.Duration(duration*float64(time.Second))) {
+		logger.S.Infof("ignoring backchannel %q", transcript)
+		return true
+	}
+	return false
+}
 
 func (c *CallOrchestrator) handleInterruption() {
 	for {
@@ -22,7 +57,6 @@ func (c *CallOrchestrator) handleInterruption() {
 		c.outgoingWebsocketLock.Lock()
 		if err := c.conn.WriteJSON(message); err != nil {
 			logger.S.Errorf("Error writing Twilio message: %v", err)
-			continue
 		}
 		c.outgoingWebsocketLock.Unlock()
 	}
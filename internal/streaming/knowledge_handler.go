@@ -0,0 +1,132 @@
+package streaming
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/knowledge"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// Number of chunks injected into the prompt per user turn
+	knowledgeResults = 4
+
+	// Chunks less similar than this to the user's turn are never injected
+	knowledgeMinScore = 0.3
+
+	// The response waits for retrieval once the turn is committed, so give up quickly and answer without it
+	knowledgeTimeout = 1 * time.Second
+)
+
+// loadKnowledge builds the in-process index over the agent's knowledge bases
+func (c *CallOrchestrator) loadKnowledge() {
+	if len(c.agent.KnowledgeBaseIds) == 0 {
+		return
+	}
+
+	index, err := knowledge.LoadIndex(c.db, c.agent.KnowledgeBaseIds)
+	if err != nil {
+		logger.S.Errorf("error loading knowledge bases: %v", err)
+		return
+	}
+
+	c.knowledgeIndex = index
+	c.embeddingClient = openai.NewClientWithConfig(openai.DefaultConfig(c.cfg.OpenAIAPIKey))
+}
+
+// knowledgeRetrieval is a knowledge base lookup for the caller's turn running alongside endpointing
+type knowledgeRetrieval struct {
+	query           string
+	transcriptIndex int
+	results         chan []knowledge.Result
+	err             error
+	cancel          context.CancelFunc
+}
+
+// discard abandons the lookup once the caller keeps talking
+func (r *knowledgeRetrieval) discard() {
+	if r != nil {
+		r.cancel()
+	}
+}
+
+// startKnowledgeRetrieval embeds the caller's current turn and searches the agent's knowledge bases in the
+// background and sends the matching chunks on the retrieval's channel. The retrieval is nil when there's
+// nothing new to look up.
+func (c *CallOrchestrator) startKnowledgeRetrieval() *knowledgeRetrieval {
+	if c.knowledgeIndex == nil || c.knowledgeIndex.Len() == 0 {
+		return nil
+	}
+
+	query := c.currentUserTurn()
+	if query == "" || query == c.lastKnowledgeQuery {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(c.turnCtx, knowledgeTimeout)
+	r := &knowledgeRetrieval{
+		query:           query,
+		transcriptIndex: len(c.call.Transcript) - 1,
+		results:         make(chan []knowledge.Result, 1),
+		cancel:          cancel,
+	}
+	index, client := c.knowledgeIndex, c.embeddingClient
+	go func() {
+		defer cancel()
+		embeddings, err := knowledge.Embed(ctx, client, []string{query})
+		if err != nil {
+			r.err = err
+			r.results <- nil
+			return
+		}
+		r.results <- index.Search(embeddings[0], knowledgeResults, knowledgeMinScore)
+	}()
+	return r
+}
+
+// finishKnowledgeRetrieval waits for the lookup and returns the chunks it found, formatted for the system
+// prompt. ok is false when there was no lookup or it failed.
+func (c *CallOrchestrator) finishKnowledgeRetrieval(r *knowledgeRetrieval) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+	results := <-r.results
+	if r.err != nil {
+		logger.S.Errorf("error embedding user turn for retrieval: %v", r.err)
+		return "", false
+	}
+
+	retrieval := models.Retrieval{
+		TranscriptIndex: r.transcriptIndex,
+		Query:           r.query,
+		ChunkIds:        []uint{},
+	}
+	var sections []string
+	for i, result := range results {
+		retrieval.ChunkIds = append(retrieval.ChunkIds, result.Chunk.ID)
+		sections = append(sections, fmt.Sprintf("[%d] %s", i+1, result.Chunk.Content))
+	}
+	c.call.Retrievals = append(c.call.Retrievals, retrieval)
+
+	c.lastKnowledgeQuery = r.query
+	c.lastKnowledge = strings.Join(sections, "\n\n")
+	return c.lastKnowledge, true
+}
+
+// currentUserTurn joins the user messages received since the agent last spoke
+func (c *CallOrchestrator) currentUserTurn() string {
+	var parts []string
+	for i := len(c.call.Transcript) - 1; i >= 0; i-- {
+		message := c.call.Transcript[i]
+		if message.Role != openai.ChatMessageRoleUser {
+			break
+		}
+		parts = append([]string{message.Content}, parts...)
+	}
+	return strings.TrimSpace(strings.Join(parts, " "))
+}
@@ -0,0 +1,108 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flyflow-devs/flyflow/internal/knowledge"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/sashabaranov/go-openai"
+)
+
+func newKnowledgeOrchestrator(t *testing.T) *CallOrchestrator {
+	t.Helper()
+	if logger.S == nil {
+		logger.InitLogger("test")
+	}
+	return &CallOrchestrator{
+		turnCtx: context.Background(),
+		metrics: NewMetrics(),
+		agent:   &models.Agent{SystemPrompt: "You answer questions about the restaurant"},
+		call: &models.Call{Transcript: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: "You answer questions about the restaurant"},
+			{Role: openai.ChatMessageRoleUser, Content: "When are you open?"},
+		}},
+	}
+}
+
+// finishedRetrieval is a lookup for the current turn that found chunks, or failed with err
+func finishedRetrieval(err error, chunks ...string) *knowledgeRetrieval {
+	r := &knowledgeRetrieval{
+		query:           "When are you open?",
+		transcriptIndex: 1,
+		results:         make(chan []knowledge.Result, 1),
+		err:             err,
+		cancel:          func() {},
+	}
+	var results []knowledge.Result
+	for i, chunk := range chunks {
+		results = append(results, knowledge.Result{Chunk: models.DocumentChunk{BaseModel: models.BaseModel{ID: uint(i + 1)}, Content: chunk}})
+	}
+	r.results <- results
+	return r
+}
+
+func TestRespeculateForTurnKeepsTheSpeculationWhenTheKnowledgeIsUnchanged(t *testing.T) {
+	tests := []struct {
+		name           string
+		retrieval      *knowledgeRetrieval
+		wantRetrievals int
+	}{
+		{name: "no lookup", retrieval: nil},
+		{name: "same chunks as the last turn", retrieval: finishedRetrieval(nil, "Open 9 to 5"), wantRetrievals: 1},
+		{name: "lookup failed", retrieval: finishedRetrieval(errors.New("embedding timed out"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newKnowledgeOrchestrator(t)
+			cancelled := false
+			spec := &speculation{cancel: func() { cancelled = true }}
+
+			got := c.respeculateForTurn(nil, tt.retrieval, spec, "[1] Open 9 to 5", nil, "", "")
+			if got != spec || cancelled {
+				t.Error("the speculation was replaced")
+			}
+			if len(c.call.Retrievals) != tt.wantRetrievals {
+				t.Errorf("got %d retrievals recorded, want %d", len(c.call.Retrievals), tt.wantRetrievals)
+			}
+		})
+	}
+}
+
+func TestRespeculateForTurnUsesNewKnowledge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"choices": [{"index": 0, "delta": {"content": "We're open until 10."}}]}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+	config := openai.DefaultConfig("test")
+	config.BaseURL = server.URL
+	client := openai.NewClientWithConfig(config)
+
+	c := newKnowledgeOrchestrator(t)
+	cancelled := false
+	spec := &speculation{cancel: func() { cancelled = true }}
+
+	got := c.respeculateForTurn(nil, finishedRetrieval(nil, "Open 5 to 10 on Fridays"), spec, "[1] Open 9 to 5", client, "gpt-4o", "openai")
+	if got == spec || !cancelled {
+		t.Fatal("the speculation written with the old knowledge was kept")
+	}
+	<-got.done
+	if got.response != "We're open until 10." {
+		t.Errorf("got response %q", got.response)
+	}
+	if !strings.Contains(c.call.Transcript[0].Content, "[1] Open 5 to 10 on Fridays") {
+		t.Errorf("system prompt %q is missing the new knowledge", c.call.Transcript[0].Content)
+	}
+	if c.lastKnowledgeQuery != "When are you open?" || len(c.call.Retrievals) != 1 || c.call.Retrievals[0].ChunkIds[0] != 1 {
+		t.Errorf("got retrievals %+v for query %q", c.call.Retrievals, c.lastKnowledgeQuery)
+	}
+}
@@ -0,0 +1,20 @@
+package streaming
+
+import (
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/memory"
+)
+
+// loadCallerMemory looks up what the agent remembers from previous calls with the caller
+func (c *CallOrchestrator) loadCallerMemory() {
+	if !c.agent.CallerMemory || c.call.ClientNumber == "" {
+		return
+	}
+
+	profile, err := memory.Load(c.db, c.agent, c.call.ClientNumber, c.call.ID)
+	if err != nil {
+		logger.S.Errorf("error loading caller memory: %v", err)
+		return
+	}
+	c.callerMemory = profile.Prompt
+}
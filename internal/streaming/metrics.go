@@ -1,11 +1,28 @@
 package streaming
 
-import "time"
+import (
+	"sync"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/telemetry"
+)
 
 type Metrics struct {
+	mu        sync.Mutex
 	startedAt time.Time
 	Latencies []float64
 	processed bool
+
+	// Per stage breakdown of each turn, stages can finish after the first audio so the last turn stays open
+	Turns []models.TurnLatency
+
+	// When text was last sent to the voice provider
+	synthesisStartedAt time.Time
+
+	// Speculative responses started and how many of them were said
+	speculations    int
+	speculationHits int
 }
 
 func NewMetrics() *Metrics {
@@ -15,16 +32,128 @@ func NewMetrics() *Metrics {
 }
 
 func (m *Metrics) startProcessing() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Every stage of the previous turn is in by now
+	m.observeCurrentTurn()
+
 	m.startedAt = time.Now()
 	m.processed = false
+	m.Turns = append(m.Turns, models.TurnLatency{At: m.startedAt})
 }
 
-func (m *Metrics) stopProcessing() {
-	if !m.processed {
+// stopProcessing is called for every audio chunk written, it returns true for the first one of the turn
+func (m *Metrics) stopProcessing() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	first := !m.processed
+	if first {
 		latency := time.Since(m.startedAt).Seconds() * 1000
 		m.Latencies = append(m.Latencies, latency)
+		if turn := m.currentTurn(); turn != nil {
+			turn.TotalMs = latency
+			if !m.synthesisStartedAt.IsZero() {
+				turn.TTSFirstByteMs = milliseconds(time.Since(m.synthesisStartedAt))
+			}
+		}
 	}
 	m.processed = true
+	return first
+}
+
+// startSynthesis is called when a response is sent to the voice provider
+func (m *Metrics) startSynthesis() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.synthesisStartedAt = time.Now()
+}
+
+// skipSynthesis is called when a response's audio was synthesized ahead of time
+func (m *Metrics) skipSynthesis() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.synthesisStartedAt = time.Time{}
+}
+
+func (m *Metrics) recordSTTFinalization(delay time.Duration) {
+	m.record(func(turn *models.TurnLatency) { turn.STTFinalizationMs = milliseconds(delay) })
+}
+
+func (m *Metrics) recordEndpointing(latency time.Duration) {
+	m.record(func(turn *models.TurnLatency) { turn.EndpointingMs = milliseconds(latency) })
+}
+
+func (m *Metrics) recordLLM(firstToken time.Duration, total time.Duration) {
+	m.record(func(turn *models.TurnLatency) {
+		turn.LLMFirstTokenMs = milliseconds(firstToken)
+		turn.LLMTotalMs = milliseconds(total)
+	})
+}
+
+func (m *Metrics) recordNetworkWrite(latency time.Duration) {
+	m.record(func(turn *models.TurnLatency) { turn.NetworkWriteMs = milliseconds(latency) })
+}
+
+func (m *Metrics) record(update func(turn *models.TurnLatency)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if turn := m.currentTurn(); turn != nil {
+		update(turn)
+	}
+}
+
+// observeTurn reports the current turn's latencies to Prometheus, for the last turn of a call
+func (m *Metrics) observeTurn() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observeCurrentTurn()
+}
+
+func (m *Metrics) observeCurrentTurn() {
+	turn := m.currentTurn()
+	if turn == nil || turn.TotalMs == 0 {
+		return
+	}
+	for stage, value := range map[string]float64{
+		"stt_finalization": turn.STTFinalizationMs,
+		"endpointing":      turn.EndpointingMs,
+		"llm_first_token":  turn.LLMFirstTokenMs,
+		"llm_total":        turn.LLMTotalMs,
+		"tts_first_byte":   turn.TTSFirstByteMs,
+		"network_write":    turn.NetworkWriteMs,
+		"total":            turn.TotalMs,
+	} {
+		if value > 0 {
+			telemetry.TurnLatency.WithLabelValues(stage).Observe(value)
+		}
+	}
+}
+
+func (m *Metrics) currentTurn() *models.TurnLatency {
+	if len(m.Turns) == 0 {
+		return nil
+	}
+	return &m.Turns[len(m.Turns)-1]
+}
+
+// getTurnLatencies returns the turns that reached the caller
+func (m *Metrics) getTurnLatencies() []models.TurnLatency {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	turns := []models.TurnLatency{}
+	for _, turn := range m.Turns {
+		if turn.TotalMs > 0 {
+			turns = append(turns, turn)
+		}
+	}
+	return turns
+}
+
+func milliseconds(d time.Duration) float64 {
+	return d.Seconds() * 1000
 }
 
 func (m *Metrics) getAverageLatency() float64 {
@@ -38,4 +167,21 @@ func (m *Metrics) getAverageLatency() float64 {
 	}
 
 	return sum / float64(len(m.Latencies))
-}
\ No newline at end of file
+}
+
+func (m *Metrics) speculationStarted() {
+	m.speculations++
+	m.speculationHits++
+}
+
+func (m *Metrics) speculationDiscarded() {
+	m.speculationHits--
+}
+
+// getSpeculationHitRate is the share of speculative responses that were said rather than discarded
+func (m *Metrics) getSpeculationHitRate() float64 {
+	if m.speculations == 0 {
+		return 0
+	}
+	return float64(m.speculationHits) / float64(m.speculations)
+}
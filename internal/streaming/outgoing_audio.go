@@ -1,9 +1,12 @@
 package streaming
 
 import (
+	"context"
 	"encoding/base64"
+	"fmt"
 	"github.com/flyflow-devs/flyflow/internal/clients"
 	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/telemetry"
 	"github.com/flyflow-devs/flyflow/internal/voices"
 	"github.com/google/uuid"
 	"io"
@@ -27,47 +30,61 @@ func (c *CallOrchestrator) handleOutgoingAudio() {
 			break
 		}
 		response, _ := <-c.responseChan
-
-		if isElevenLabsVoice(c.agent.VoiceId) {
+		agent := c.currentAgent()
+
+		if audio := c.speculativeAudio; audio != nil && audio.text == response {
+			c.speculativeAudio = nil
+			c.metrics.skipSynthesis()
+			c.playAudio(audio.audio)
+		} else if isElevenLabsVoice(agent.VoiceId) {
+			c.metrics.startSynthesis()
 			c.streamElevenLabsAudio(response)
-		} else if isCartesiaVoice(c.agent.VoiceId) {
+		} else if isCartesiaVoice(agent.VoiceId) {
+			c.metrics.startSynthesis()
 			c.streamCartesiaAudio(cartesiaClient, response)
 		} else {
-			logger.S.Errorf("Unknown voice service for voice ID: %s", c.agent.VoiceId)
+			logger.S.Errorf("Unknown voice service for voice ID: %s", agent.VoiceId)
 		}
 	}
 }
 
 func (c *CallOrchestrator) streamElevenLabsAudio(response string) {
+	agent := c.currentAgent()
 	model := "eleven_turbo_v2_5"
-	if c.agent.Language != "en-US" && c.agent.Language != "" {
+	if agent.Language != "en-US" && agent.Language != "" {
 		model = "eleven_turbo_v2_5"
 	}
 
-	if err := elevenlabs.TextToSpeechStream(
+	_, end := telemetry.StartVendorCall(c.turnCtx, "elevenlabs", "text_to_speech")
+	err := elevenlabs.TextToSpeechStream(
 		c,
-		voices.Voices[c.agent.VoiceId],
+		voices.Voices[agent.VoiceId],
 		elevenlabs.TextToSpeechRequest{
 			Text:    response,
 			ModelID: model,
 		},
 		elevenlabs.OutputFormat("ulaw_8000"),
-		elevenlabs.LatencyOptimizations(int(c.agent.VoiceOptimization))); err != nil {
+		elevenlabs.LatencyOptimizations(int(agent.VoiceOptimization)))
+	end(err)
+	if err != nil {
 		logger.S.Errorf("error streaming speech from eleven labs: %v", err)
 	}
 }
 
 func (c *CallOrchestrator) streamCartesiaAudio(client *clients.CartesiaClient, response string) {
+	agent := c.currentAgent()
 	modelID := "sonic-english"
 	language := "en"
 
-	if c.agent.Language != "en-US" && c.agent.Language != "" {
+	if agent.Language != "en-US" && agent.Language != "" {
 		modelID = "sonic-multilingual"
-		language = mapLanguageCode(c.agent.Language)
+		language = mapLanguageCode(agent.Language)
 	}
 
-	stream, err := client.StreamSpeechBytes(modelID, response, voices.CartesiaVoices[c.agent.VoiceId], language)
+	_, end := telemetry.StartVendorCall(c.turnCtx, "cartesia", "text_to_speech")
+	stream, err := client.StreamSpeechBytes(modelID, response, voices.CartesiaVoices[agent.VoiceId], language)
 	if err != nil {
+		end(err)
 		logger.S.Errorf("error streaming speech from Cartesia: %v", err)
 		return
 	}
@@ -77,10 +94,12 @@ func (c *CallOrchestrator) streamCartesiaAudio(client *clients.CartesiaClient, r
 	for {
 		n, err := stream.Read(buffer)
 		if err == io.EOF {
+			end(nil)
 			c.writeToTwilio(buffer[:n])
 			break
 		}
 		if err != nil {
+			end(err)
 			logger.S.Errorf("Error reading Cartesia audio stream: %v", err)
 			break
 		}
@@ -89,12 +108,61 @@ func (c *CallOrchestrator) streamCartesiaAudio(client *clients.CartesiaClient, r
 	}
 }
 
+// synthesize generates the whole response's speech up front
+func (c *CallOrchestrator) synthesize(ctx context.Context, response string) (audio []byte, err error) {
+	agent := c.currentAgent()
+	if isElevenLabsVoice(agent.VoiceId) {
+		ctx, end := telemetry.StartVendorCall(ctx, "elevenlabs", "text_to_speech")
+		defer func() { end(err) }()
+
+		client := elevenlabs.NewClient(ctx, c.cfg.ElevenLabsAPIKey, 1*time.Minute)
+		return client.TextToSpeech(
+			voices.Voices[agent.VoiceId],
+			elevenlabs.TextToSpeechRequest{
+				Text:    response,
+				ModelID: "eleven_turbo_v2_5",
+			},
+			elevenlabs.OutputFormat("ulaw_8000"),
+			elevenlabs.LatencyOptimizations(int(agent.VoiceOptimization)))
+	}
+
+	if !isCartesiaVoice(agent.VoiceId) {
+		return nil, fmt.Errorf("unknown voice service for voice ID: %s", agent.VoiceId)
+	}
+
+	modelID := "sonic-english"
+	language := "en"
+	if agent.Language != "en-US" && agent.Language != "" {
+		modelID = "sonic-multilingual"
+		language = mapLanguageCode(agent.Language)
+	}
+
+	_, end := telemetry.StartVendorCall(ctx, "cartesia", "text_to_speech")
+	defer func() { end(err) }()
+
+	stream, err := clients.NewCartesiaClient(c.cfg).StreamSpeechBytes(modelID, response, voices.CartesiaVoices[agent.VoiceId], language)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	return io.ReadAll(stream)
+}
+
+// playAudio writes already synthesized speech in the same sized chunks as streamed speech
+func (c *CallOrchestrator) playAudio(audio []byte) {
+	for len(audio) > 0 {
+		n := min(len(audio), 4096)
+		c.writeToTwilio(audio[:n])
+		audio = audio[n:]
+	}
+}
+
 func (c *CallOrchestrator) writeToTwilio(p []byte) {
 	if c.userSpeaking {
 		return
 	}
 
-	c.metrics.stopProcessing()
+	first := c.metrics.stopProcessing()
 	encodedMessage := base64.StdEncoding.EncodeToString(p)
 
 	message := TwilioMessage{
@@ -118,9 +186,13 @@ func (c *CallOrchestrator) writeToTwilio(p []byte) {
 	c.outgoingWebsocketLock.Lock()
 	defer c.outgoingWebsocketLock.Unlock()
 
+	writeStart := time.Now()
 	if err := c.conn.WriteJSON(message); err != nil {
 		logger.S.Errorf("Error writing Twilio message: %v", err)
 	}
+	if first {
+		c.metrics.recordNetworkWrite(time.Since(writeStart))
+	}
 	c.marks[markUUIDString] = struct{}{}
 	if err := c.conn.WriteJSON(mark); err != nil {
 		logger.S.Errorf("Error writing Twilio message: %v", err)
@@ -0,0 +1,181 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/telemetry"
+	"github.com/sashabaranov/go-openai"
+)
+
+// speculation is a response generated while endpointing decides whether the caller has finished talking,
+// it's cancelled when the caller keeps going
+type speculation struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	response string
+	err      error
+
+	// How long the LLM took to its first token and to the whole response
+	firstToken time.Duration
+	total      time.Duration
+
+	// audio is the first sentence of the response already synthesized, for agents with speculative TTS
+	audio *synthesizedAudio
+}
+
+// synthesizedAudio is speech generated ahead of being played
+type synthesizedAudio struct {
+	text  string
+	audio []byte
+}
+
+// speculate starts generating a response to the transcript so far
+func (c *CallOrchestrator) speculate(client *openai.Client, model string, vendor string) *speculation {
+	ctx, cancel := context.WithCancel(c.turnCtx)
+	s := &speculation{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	messages := append([]openai.ChatCompletionMessage{}, c.call.Transcript...)
+	c.metrics.speculationStarted()
+
+	go func() {
+		defer close(s.done)
+
+		var synthesis chan *synthesizedAudio
+		if c.currentAgent().SpeculativeTTS {
+			synthesis = make(chan *synthesizedAudio, 1)
+		}
+		synthesizing := false
+		synthesizeFirst := func(first string) {
+			synthesizing = true
+			go func() {
+				audio, err := c.synthesize(ctx, first)
+				if err != nil {
+					if ctx.Err() == nil {
+						logger.S.Errorf("error synthesizing speculative audio: %v", err)
+					}
+					synthesis <- nil
+					return
+				}
+				synthesis <- &synthesizedAudio{text: first, audio: audio}
+			}()
+		}
+
+		start := time.Now()
+		llmCtx, end := telemetry.StartVendorCall(ctx, vendor, "chat_completion")
+		stream, err := client.CreateChatCompletionStream(
+			llmCtx,
+			openai.ChatCompletionRequest{
+				Model:    model,
+				Messages: messages,
+			},
+		)
+		if err != nil {
+			end(err)
+			s.err = err
+			if ctx.Err() == nil {
+				logger.S.Error("error getting openai response ", err)
+			}
+			return
+		}
+		defer stream.Close()
+
+		var response strings.Builder
+		for {
+			chunk, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				end(err)
+				s.err = err
+				if ctx.Err() == nil {
+					logger.S.Error("error getting openai response ", err)
+				}
+				if synthesizing {
+					<-synthesis
+				}
+				return
+			}
+			if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+				if s.firstToken == 0 {
+					s.firstToken = time.Since(start)
+				}
+				response.WriteString(chunk.Choices[0].Delta.Content)
+
+				// The first sentence is synthesized as soon as it's complete, while the rest is still streaming
+				if synthesis != nil && !synthesizing {
+					if first, rest := splitFirstSentence(response.String()); rest != "" {
+						synthesizeFirst(first)
+					}
+				}
+			}
+		}
+		end(nil)
+		s.total = time.Since(start)
+		s.response = response.String()
+
+		if synthesis == nil || s.response == "" {
+			return
+		}
+		if !synthesizing {
+			first, _ := splitFirstSentence(s.response)
+			synthesizeFirst(first)
+		}
+		s.audio = <-synthesis
+	}()
+
+	return s
+}
+
+// discard cancels a speculation the caller talked over
+func (s *speculation) discard(m *Metrics) {
+	s.cancel()
+	m.speculationDiscarded()
+}
+
+// respond waits for the speculation and says it. When no response was generated nothing is said and the turn
+// goes back to the caller.
+func (c *CallOrchestrator) respond(s *speculation) {
+	<-s.done
+	s.cancel()
+	if s.err != nil || s.response == "" {
+		logger.S.Warnf("no response generated for call %v, handing the turn back to the caller: %v", c.call.ID, s.err)
+		c.turn = "user"
+		return
+	}
+	c.metrics.recordLLM(s.firstToken, s.total)
+
+	if s.audio != nil {
+		_, rest := splitFirstSentence(s.response)
+		c.speculativeAudio = s.audio
+		c.responseChan <- s.audio.text
+		if rest != "" {
+			c.responseChan <- rest
+		}
+	} else {
+		c.responseChan <- s.response
+	}
+
+	c.call.Transcript = append(c.call.Transcript, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleAssistant,
+		Content: s.response,
+	})
+}
+
+// splitFirstSentence splits off the response's first sentence so it can be synthesized on its own
+func splitFirstSentence(response string) (string, string) {
+	for i := 0; i < len(response)-1; i++ {
+		if strings.ContainsRune(".?!", rune(response[i])) && response[i+1] == ' ' {
+			return response[:i+1], strings.TrimSpace(response[i+1:])
+		}
+	}
+	return response, ""
+}
@@ -0,0 +1,60 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+)
+
+func finishedSpeculation(response string, err error) *speculation {
+	s := &speculation{cancel: func() {}, done: make(chan struct{}), response: response, err: err}
+	close(s.done)
+	return s
+}
+
+func TestRespond(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     *speculation
+		wantSaid []string
+		wantTurn string
+	}{
+		{name: "response", spec: finishedSpeculation("We're open until 10.", nil), wantSaid: []string{"We're open until 10."}, wantTurn: "assistant"},
+		{name: "completion failed", spec: finishedSpeculation("", errors.New("rate limited")), wantTurn: "user"},
+		{name: "empty completion", spec: finishedSpeculation("", nil), wantTurn: "user"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if logger.S == nil {
+				logger.InitLogger("test")
+			}
+			c := &CallOrchestrator{
+				turnCtx:      context.Background(),
+				metrics:      NewMetrics(),
+				call:         &models.Call{},
+				responseChan: make(chan string, 2),
+				turn:         "assistant",
+			}
+
+			c.respond(tt.spec)
+			close(c.responseChan)
+			var said []string
+			for response := range c.responseChan {
+				said = append(said, response)
+			}
+			if len(said) != len(tt.wantSaid) || (len(said) > 0 && said[0] != tt.wantSaid[0]) {
+				t.Errorf("said %q, want %q", said, tt.wantSaid)
+			}
+			if c.turn != tt.wantTurn {
+				t.Errorf("got turn %q, want %q", c.turn, tt.wantTurn)
+			}
+			if len(c.call.Transcript) != len(tt.wantSaid) {
+				t.Errorf("got transcript %+v", c.call.Transcript)
+			}
+		})
+	}
+}
@@ -7,7 +7,8 @@ import (
 )
 
 func (c *CallOrchestrator) handleToolCalls() {
-	if len(c.agent.Tools) == 0 {
+	// Agents the call can be handed off to may have tools of their own
+	if len(c.agent.Tools) == 0 && !c.flowHasTools() && !c.canHandoff() {
 		logger.S.Info("no tools found, exiting")
 		return
 	}
@@ -22,14 +23,15 @@ func (c *CallOrchestrator) handleToolCalls() {
 			break
 		}
 
-		if len(c.call.Transcript) > len(messages) && c.call.Transcript[len(c.call.Transcript)-1].Role == "user" {
+		tools := c.currentTools()
+		if len(tools) > 0 && len(c.call.Transcript) > len(messages) && c.call.Transcript[len(c.call.Transcript)-1].Role == "user" {
 			ctx := context.Background()
 			resp, err := openaiClient.CreateChatCompletion(
 				ctx,
 				openai.ChatCompletionRequest{
 					Model:    "gpt-4o",
 					Messages: c.call.Transcript,
-					Tools: c.agent.Tools,
+					Tools: tools,
 				},
 			)
 			if err != nil {
@@ -37,8 +39,9 @@ func (c *CallOrchestrator) handleToolCalls() {
 				continue
 			}
 
-			if len(resp.Choices[0].Message.ToolCalls) > 0 {
+			if len(resp.Choices) > 0 && len(resp.Choices[0].Message.ToolCalls) > 0 {
 				c.EmitEvent("tool_call", resp.Choices[0].Message.ToolCalls)
+				c.handleFlowToolCalls(resp.Choices[0].Message.ToolCalls)
 			}
 
 			messages = c.call.Transcript
@@ -1,13 +1,21 @@
 package streaming
 
 import (
+	"github.com/flyflow-devs/flyflow/internal/campaigns"
 	"github.com/flyflow-devs/flyflow/internal/classifier"
+	"github.com/flyflow-devs/flyflow/internal/endpointing"
 	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/logger"
 	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/ownership"
+	"github.com/flyflow-devs/flyflow/internal/routing"
 	"github.com/gorilla/websocket"
 	"gorm.io/gorm"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/twilio/twilio-go/twiml"
 )
@@ -16,17 +24,39 @@ type TwilioHandler struct {
 	Cfg *config.Config
 	DB *gorm.DB
 	classifier *classifier.Classifier
+	registry *ownership.Registry
 	wg     *sync.WaitGroup
+
+	// Voicemail greetings by agent and routing rule
+	greetings sync.Map
+
+	// No new calls are taken once the server is draining
+	draining atomic.Bool
 }
 
-func NewTwilioHandler(cfg *config.Config, db *gorm.DB, wg *sync.WaitGroup) *TwilioHandler {
-	return &TwilioHandler{
+func NewTwilioHandler(cfg *config.Config, db *gorm.DB, wg *sync.WaitGroup, registry *ownership.Registry) *TwilioHandler {
+	h := &TwilioHandler{
 		Cfg: cfg,
 		DB: db,
 		classifier: classifier.NewClassifier(),
+		registry: registry,
 		wg: wg,
 
 	}
+
+	// The endpointing classifier starts from its seed examples until the logged decisions are loaded
+	go func() {
+		if err := endpointing.Train(db, h.classifier); err != nil {
+			logger.S.Errorf("error training endpointing classifier: %v", err)
+		}
+	}()
+
+	return h
+}
+
+// Drain refuses new call streams, the calls in progress carry on
+func (h *TwilioHandler) Drain() {
+	h.draining.Store(true)
 }
 
 func (h *TwilioHandler) HandleTwilioML(w http.ResponseWriter, r *http.Request) {
@@ -41,19 +71,24 @@ func (h *TwilioHandler) HandleTwilioML(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var elements []twiml.Element
-	if agent.VoicemailNumber != "" {
-		elements = append(elements, twiml.VoiceDial{
-			Number: agent.VoicemailNumber,
-		})
+
+	// Routing rules only apply to inbound calls to the agent's number
+	var rule *models.RoutingRule
+	if agent.PhoneNumber == to {
+		rule = routing.Match(&agent, from, time.Now())
 	}
 
-	elements = append(elements, twiml.VoiceConnect{
-		InnerElements: []twiml.Element{
-			twiml.VoiceStream{
-				Url: h.Cfg.TwilioStreamingURL,
-			},
-		},
-	})
+	if rule == nil {
+		if agent.VoicemailNumber != "" {
+			elements = append(elements, twiml.VoiceDial{
+				Number: agent.VoicemailNumber,
+			})
+		}
+		elements = append(elements, h.streamElement(&agent, agent.PhoneNumber == to, nil))
+	} else {
+		logger.S.Infof("routing call from %s to agent %v with rule %q", from, agent.ID, rule.Name)
+		elements = h.routeElements(&agent, rule)
+	}
 
 	resp, err := twiml.Voice(elements)
 	if err != nil {
@@ -72,7 +107,62 @@ func (h *TwilioHandler) HandleTwilioML(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// routeElements renders the TwiML for the routing rule's target
+func (h *TwilioHandler) routeElements(agent *models.Agent, rule *models.RoutingRule) []twiml.Element {
+	target := rule.Target
+	switch target.Type {
+	case models.RouteToForward:
+		return []twiml.Element{twiml.VoiceDial{Number: target.ForwardingNumber}}
+	case models.RouteToVoicemail:
+		return h.voicemailElements(agent, rule)
+	case models.RouteToReject:
+		return []twiml.Element{twiml.VoiceReject{}}
+	}
+
+	parameters := map[string]string{"routing_rule": rule.Name}
+	if target.AgentId != 0 && target.AgentId != agent.ID {
+		// The agent could have been deleted since the rule was saved, the number's agent answers instead
+		var count int64
+		err := h.DB.Model(&models.Agent{}).Where("id = ? AND user_id = ?", target.AgentId, agent.UserId).Count(&count).Error
+		if err != nil || count == 0 {
+			logger.S.Warnf("routing rule %q of agent %v targets missing agent %v", rule.Name, agent.ID, target.AgentId)
+		} else {
+			parameters["agent_id"] = strconv.FormatUint(uint64(target.AgentId), 10)
+		}
+	}
+	return []twiml.Element{h.streamElement(agent, true, parameters)}
+}
+
+// streamElement connects the call to the media stream, the parameters are passed along in the start message.
+// Inbound calls to agents with voicemail fall back to taking a message if the stream ends before the call starts.
+func (h *TwilioHandler) streamElement(agent *models.Agent, inbound bool, parameters map[string]string) twiml.Element {
+	var inner []twiml.Element
+	for name, value := range parameters {
+		inner = append(inner, twiml.VoiceParameter{Name: name, Value: value})
+	}
+
+	var action string
+	if inbound && agent.Voicemail != nil {
+		action = "/twilio/voicemail/fallback"
+	}
+
+	return twiml.VoiceConnect{
+		Action: action,
+		InnerElements: []twiml.Element{
+			twiml.VoiceStream{
+				Url:           h.Cfg.TwilioStreamingURL,
+				InnerElements: inner,
+			},
+		},
+	}
+}
+
 func (h *TwilioHandler) HandleTwilioStream(w http.ResponseWriter, r *http.Request) {
+	if h.draining.Load() {
+		http.Error(w, "Server is draining", http.StatusServiceUnavailable)
+		return
+	}
+
 	// Upgrade the HTTP connection to a WebSocket connection
 	conn, err := websocket.Upgrade(w, r, nil, 1024, 1024)
 	if err != nil {
@@ -86,11 +176,48 @@ func (h *TwilioHandler) HandleTwilioStream(w http.ResponseWriter, r *http.Reques
 	defer conn.Close()
 
 	// Orchestrate the call
-	orchestrator := NewCallOrchestrator(h.Cfg, h.DB, conn, h.classifier)
+	orchestrator := NewCallOrchestrator(h.Cfg, h.DB, conn, h.classifier, h.registry)
 
 	orchestrator.OrchestrateCall()
 }
 
+// HandleTwilioStatus receives the final status of outbound calls from Twilio
+func (h *TwilioHandler) HandleTwilioStatus(w http.ResponseWriter, r *http.Request) {
+	if !h.validTwilioRequest(r) {
+		http.Error(w, "Invalid signature", http.StatusForbidden)
+		return
+	}
+
+	callSid := r.FormValue("CallSid")
+	if callSid == "" {
+		http.Error(w, "Call SID not provided", http.StatusBadRequest)
+		return
+	}
+
+	var call models.Call
+	result := h.DB.Where("sid = ?", callSid).First(&call)
+	if result.Error != nil {
+		http.Error(w, "Call not found", http.StatusNotFound)
+		return
+	}
+
+	call.Status = r.FormValue("CallStatus")
+	call.AnsweredBy = r.FormValue("AnsweredBy")
+	if err := h.DB.Model(&call).Select("status", "answered_by").Updates(&call).Error; err != nil {
+		logger.S.Errorf("error saving status of call %v: %v", call.ID, err)
+		http.Error(w, "Failed to save call status", http.StatusInternalServerError)
+		return
+	}
+
+	if err := campaigns.RecordOutcome(h.DB, &call); err != nil {
+		logger.S.Errorf("error recording campaign outcome of call %v: %v", call.ID, err)
+		http.Error(w, "Failed to record campaign outcome", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (h *TwilioHandler) HandleForwardCall(w http.ResponseWriter, r *http.Request) {
 	to := r.FormValue("To")
 	from := r.FormValue("From")
@@ -0,0 +1,82 @@
+package streaming_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/flyflow-devs/flyflow/internal/apitest"
+	"github.com/flyflow-devs/flyflow/internal/models"
+)
+
+func TestTwilioStatusRequiresASignature(t *testing.T) {
+	h := apitest.New(t)
+	user, _ := h.CreateUser(t, "owner@example.com")
+	agent := models.Agent{UserId: user.ID, Name: "Sales", PhoneNumber: "+15550000000"}
+	if err := h.DB.Create(&agent).Error; err != nil {
+		t.Fatal(err)
+	}
+	call := models.Call{AgentId: agent.ID, Sid: "CA123", Status: "in-progress"}
+	if err := h.DB.Create(&call).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	form := url.Values{"CallSid": {"CA123"}, "CallStatus": {"no-answer"}}
+	apitest.Decode(t, h.DoTwilio(t, "/twilio/status", form, ""), http.StatusForbidden, nil)
+	forged := apitest.TwilioSignature("wrong-token", h.URL+"/twilio/status", form)
+	apitest.Decode(t, h.DoTwilio(t, "/twilio/status", form, forged), http.StatusForbidden, nil)
+
+	if err := h.DB.First(&call, call.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if call.Status != "in-progress" {
+		t.Fatalf("unsigned callback set the status to %q", call.Status)
+	}
+
+	apitest.Decode(t, h.DoTwilio(t, "/twilio/status", form, h.SignTwilio("/twilio/status", form)), http.StatusNoContent, nil)
+	if err := h.DB.First(&call, call.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if call.Status != "no-answer" {
+		t.Errorf("got status %q, want no-answer", call.Status)
+	}
+}
+
+func TestVoicemailCallbacksRequireASignature(t *testing.T) {
+	h := apitest.New(t)
+	user, _ := h.CreateUser(t, "owner@example.com")
+	agent := models.Agent{UserId: user.ID, Name: "Support", PhoneNumber: "+15550000000"}
+	if err := h.DB.Create(&agent).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	form := url.Values{
+		"To":                {agent.PhoneNumber},
+		"From":              {"+15551111111"},
+		"CallSid":           {"CA123"},
+		"RecordingSid":      {"RE123"},
+		"RecordingDuration": {"12"},
+	}
+	for _, path := range []string{"/twilio/voicemail/recorded", "/twilio/voicemail/fallback"} {
+		apitest.Decode(t, h.DoTwilio(t, path, form, ""), http.StatusForbidden, nil)
+		forged := apitest.TwilioSignature("wrong-token", h.URL+path, form)
+		apitest.Decode(t, h.DoTwilio(t, path, form, forged), http.StatusForbidden, nil)
+	}
+
+	var count int64
+	if err := h.DB.Model(&models.Call{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Fatalf("unsigned callbacks created %d voicemails", count)
+	}
+
+	apitest.Decode(t, h.DoTwilio(t, "/twilio/voicemail/recorded", form, h.SignTwilio("/twilio/voicemail/recorded", form)), http.StatusOK, nil)
+	var voicemail models.Call
+	if err := h.DB.Where("sid = ?", "CA123").First(&voicemail).Error; err != nil {
+		t.Fatalf("signed callback didn't save the voicemail: %v", err)
+	}
+	if voicemail.Type != models.CallTypeVoicemail || voicemail.ClientNumber != "+15551111111" {
+		t.Errorf("got a %q call from %q, want a voicemail from +15551111111", voicemail.Type, voicemail.ClientNumber)
+	}
+}
@@ -0,0 +1,53 @@
+package streaming
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/twilio/twilio-go/client"
+)
+
+// validTwilioRequest reports whether the request carries a valid X-Twilio-Signature. Twilio signs the public
+// URL it called, which behind a proxy isn't the URL this server sees, so the path is checked under the hosts
+// of the configured TwiML and status callback URLs as well as the one the request was sent to.
+func (h *TwilioHandler) validTwilioRequest(r *http.Request) bool {
+	signature := r.Header.Get("X-Twilio-Signature")
+	if h.Cfg.TwilioAccountAuthToken == "" || signature == "" {
+		return false
+	}
+	if err := r.ParseForm(); err != nil {
+		return false
+	}
+
+	params := make(map[string]string, len(r.PostForm))
+	for key, values := range r.PostForm {
+		params[key] = values[0]
+	}
+
+	validator := client.NewRequestValidator(h.Cfg.TwilioAccountAuthToken)
+	for _, base := range h.twilioBaseURLs(r) {
+		if validator.Validate(base+r.URL.RequestURI(), params, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+// twilioBaseURLs returns the schemes and hosts Twilio may have called this server on
+func (h *TwilioHandler) twilioBaseURLs(r *http.Request) []string {
+	var bases []string
+	for _, configured := range []string{h.Cfg.TwilioMLUrl, h.Cfg.TwilioStatusCallbackUrl} {
+		if u, err := url.Parse(configured); err == nil && u.Scheme != "" && u.Host != "" {
+			bases = append(bases, u.Scheme+"://"+u.Host)
+		}
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return append(bases, scheme+"://"+r.Host)
+}
@@ -0,0 +1,215 @@
+package streaming
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/clients"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/voicemail"
+	"github.com/flyflow-devs/flyflow/internal/voices"
+	"github.com/haguro/elevenlabs-go"
+	"github.com/twilio/twilio-go/twiml"
+)
+
+// greetingAudio is a voicemail greeting spoken in an agent's voice
+type greetingAudio struct {
+	voiceId     string
+	text        string
+	contentType string
+	audio       []byte
+}
+
+// voicemailElements plays the greeting and records the caller's message. URLs are relative to the TwiML
+// endpoint so Twilio calls back into this server. Greetings in the agent's voice are served from a signed
+// URL, without JWT_SECRET to sign it with Twilio reads the greeting instead.
+func (h *TwilioHandler) voicemailElements(agent *models.Agent, rule *models.RoutingRule) []twiml.Element {
+	var greeting twiml.Element
+	if (isElevenLabsVoice(agent.VoiceId) || isCartesiaVoice(agent.VoiceId)) && h.Cfg.JWTSecret != "" {
+		ruleName := ""
+		if rule != nil {
+			ruleName = rule.Name
+		}
+		query := voicemail.GreetingQuery(h.Cfg.JWTSecret, agent.ID, ruleName, time.Now())
+		greeting = twiml.VoicePlay{Url: "/twilio/voicemail/greeting?" + query.Encode()}
+	} else {
+		greeting = twiml.VoiceSay{Message: voicemail.Greeting(agent, rule)}
+	}
+
+	return []twiml.Element{
+		greeting,
+		twiml.VoiceRecord{
+			Action:    "/twilio/voicemail/recorded",
+			MaxLength: strconv.Itoa(voicemail.MaxLength(agent)),
+			PlayBeep:  "true",
+			Trim:      "trim-silence",
+		},
+		// Only reached when nothing was recorded
+		twiml.VoiceHangup{},
+	}
+}
+
+// HandleVoicemailGreeting serves the voicemail greeting in the agent's voice, only for URLs signed by voicemailElements
+func (h *TwilioHandler) HandleVoicemailGreeting(w http.ResponseWriter, r *http.Request) {
+	if !voicemail.VerifyGreetingQuery(h.Cfg.JWTSecret, r.URL.Query(), time.Now()) {
+		http.Error(w, "Invalid signature", http.StatusForbidden)
+		return
+	}
+
+	var agent models.Agent
+	if err := h.DB.First(&agent, r.URL.Query().Get("agent_id")).Error; err != nil {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+
+	var rule *models.RoutingRule
+	ruleName := r.URL.Query().Get("rule")
+	if ruleName != "" && agent.Routing != nil {
+		for i := range agent.Routing.Rules {
+			if agent.Routing.Rules[i].Name == ruleName {
+				rule = &agent.Routing.Rules[i]
+			}
+		}
+	}
+
+	greeting, err := h.greetingAudio(r.Context(), &agent, voicemail.Greeting(&agent, rule), ruleName)
+	if err != nil {
+		logger.S.Errorf("error generating voicemail greeting for agent %v: %v", agent.ID, err)
+		http.Error(w, "Failed to generate greeting", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", greeting.contentType)
+	w.Write(greeting.audio)
+}
+
+// greetingAudio returns the spoken greeting, greetings are kept in memory until the text or voice changes
+func (h *TwilioHandler) greetingAudio(ctx context.Context, agent *models.Agent, text string, ruleName string) (*greetingAudio, error) {
+	key := fmt.Sprintf("%d/%s", agent.ID, ruleName)
+	if cached, ok := h.greetings.Load(key); ok {
+		greeting := cached.(*greetingAudio)
+		if greeting.voiceId == agent.VoiceId && greeting.text == text {
+			return greeting, nil
+		}
+	}
+
+	greeting := &greetingAudio{voiceId: agent.VoiceId, text: text}
+	if isElevenLabsVoice(agent.VoiceId) {
+		client := elevenlabs.NewClient(ctx, h.Cfg.ElevenLabsAPIKey, 1*time.Minute)
+		audio, err := client.TextToSpeech(voices.Voices[agent.VoiceId], elevenlabs.TextToSpeechRequest{
+			Text:    text,
+			ModelID: "eleven_turbo_v2_5",
+		}, elevenlabs.OutputFormat("mp3_44100_128"))
+		if err != nil {
+			return nil, err
+		}
+		greeting.contentType = "audio/mpeg"
+		greeting.audio = audio
+	} else {
+		modelID := "sonic-english"
+		if agent.Language != "en-US" && agent.Language != "" {
+			modelID = "sonic-multilingual"
+		}
+
+		stream, err := clients.NewCartesiaClient(h.Cfg).SpeechWAV(modelID, text, voices.CartesiaVoices[agent.VoiceId], mapLanguageCode(agent.Language))
+		if err != nil {
+			return nil, err
+		}
+		defer stream.Close()
+
+		audio, err := io.ReadAll(stream)
+		if err != nil {
+			return nil, err
+		}
+		greeting.contentType = "audio/wav"
+		greeting.audio = audio
+	}
+
+	h.greetings.Store(key, greeting)
+	return greeting, nil
+}
+
+// HandleVoicemailRecorded saves the caller's message once Twilio has finished recording it
+func (h *TwilioHandler) HandleVoicemailRecorded(w http.ResponseWriter, r *http.Request) {
+	if !h.validTwilioRequest(r) {
+		http.Error(w, "Invalid signature", http.StatusForbidden)
+		return
+	}
+
+	to := r.FormValue("To")
+
+	var agent models.Agent
+	result := h.DB.Where("phone_number = ?", to).First(&agent)
+	if result.Error != nil {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+
+	duration, _ := strconv.Atoi(r.FormValue("RecordingDuration"))
+	if recordingSid := r.FormValue("RecordingSid"); recordingSid != "" && duration > 0 {
+		call, err := voicemail.Save(h.DB, &agent, voicemail.Message{
+			CallSid:      r.FormValue("CallSid"),
+			From:         r.FormValue("From"),
+			RecordingSid: recordingSid,
+			Duration:     duration,
+		})
+		if err != nil {
+			logger.S.Errorf("error saving voicemail for agent %v: %v", agent.ID, err)
+			http.Error(w, "Failed to save voicemail", http.StatusInternalServerError)
+			return
+		}
+		logger.S.Infof("saved voicemail %v for agent %v", call.ID, agent.ID)
+	}
+
+	h.writeTwiML(w, []twiml.Element{twiml.VoiceHangup{}})
+}
+
+// HandleVoicemailFallback takes a message when the stream to the agent ends before the call started,
+// e.g. because the agent couldn't be loaded
+func (h *TwilioHandler) HandleVoicemailFallback(w http.ResponseWriter, r *http.Request) {
+	if !h.validTwilioRequest(r) {
+		http.Error(w, "Invalid signature", http.StatusForbidden)
+		return
+	}
+
+	to := r.FormValue("To")
+
+	// Outbound calls and numbers without an agent just end
+	var agent models.Agent
+	result := h.DB.Where("phone_number = ?", to).First(&agent)
+	if result.Error != nil {
+		h.writeTwiML(w, []twiml.Element{twiml.VoiceHangup{}})
+		return
+	}
+
+	var count int64
+	err := h.DB.Model(&models.Call{}).
+		Where("sid = ? AND started_at > ?", r.FormValue("CallSid"), time.Time{}).
+		Count(&count).Error
+	if err != nil {
+		logger.S.Errorf("error looking up call %v: %v", r.FormValue("CallSid"), err)
+	}
+	if err != nil || count > 0 || agent.Voicemail == nil {
+		h.writeTwiML(w, []twiml.Element{twiml.VoiceHangup{}})
+		return
+	}
+
+	logger.S.Infof("agent %v was unavailable, taking a voicemail", agent.ID)
+	h.writeTwiML(w, h.voicemailElements(&agent, nil))
+}
+
+func (h *TwilioHandler) writeTwiML(w http.ResponseWriter, elements []twiml.Element) {
+	resp, err := twiml.Voice(elements)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(resp))
+}
@@ -4,12 +4,26 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/binary"
-	"log"
+	"math"
 
+	"github.com/flyflow-devs/flyflow/internal/classifier"
 	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
 	"github.com/maxhawkins/go-webrtcvad"
 )
 
+const (
+	defaultVADMode         = 3
+	defaultMinSpeechMs     = 400
+	defaultEnergyThreshold = 300
+
+	// Gaps in speech shorter than this are treated as part of the same utterance
+	vadSpeechGapMs = 100
+
+	// Silence after a barge-in that hands the turn back when no transcript arrives
+	vadReleaseMs = 1500
+)
+
 // µ-law decoding lookup table
 var muLawDecompressTable = [256]int16{
 	-32124, -31100, -30076, -29052, -28028, -27004, -25980, -24956,
@@ -95,27 +109,82 @@ func splitFrames(pcm []int16, frameSize int) [][]int16 {
 	return frames
 }
 
+// rms returns the root mean square level of the frame
+func rms(frame []int16) float64 {
+	if len(frame) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, sample := range frame {
+		sum += float64(sample) * float64(sample)
+	}
+	return math.Sqrt(sum / float64(len(frame)))
+}
+
+// usesVAD reports whether barge-in is detected from the call audio rather than from interim transcripts
+func (c *CallOrchestrator) usesVAD() bool {
+	return !c.vadFailed && c.currentAgent().BargeIn.Detection != models.BargeInDetectionTranscript
+}
+
+// handleWebRTC runs voice activity detection on the caller's audio and interrupts the agent when the
+// caller talks over it for long enough
 func (c *CallOrchestrator) handleWebRTC() {
 	vad, err := webrtcvad.New()
 	if err != nil {
-		log.Fatal(err)
-	}
+		logger.S.Errorf("error creating vad, barge-in falls back to transcripts: %v", err)
+		c.vadFailed = true
 
-	if err := vad.SetMode(3); err != nil {
-		log.Fatal(err)
+		// Keep draining the audio so the inbound audio loop isn't blocked
+		for !c.done {
+			<-c.rtcAudioChan
+		}
+		return
 	}
 
-	speakingFrames := 0
 	const frameDuration = 20 // 20 ms per frame
 	const sampleRate = 16000
 	const frameSize = sampleRate * frameDuration / 1000 // 320 samples per frame
 
+	speechMs := 0
+	silenceMs := 0
+	vadMode := -1
+
 	for {
 		if c.done {
 			break
 		}
 		mulawAudio := <-c.rtcAudioChan
 
+		// Settings change when the call is handed off to another agent
+		settings := c.currentAgent().BargeIn
+		mode := c.bargeInMode()
+		if !c.usesVAD() || mode == models.BargeInModeNever {
+			continue
+		}
+		aggressiveness := defaultVADMode
+		if settings.VADMode != nil {
+			aggressiveness = *settings.VADMode
+		}
+		if aggressiveness != vadMode {
+			if err := vad.SetMode(aggressiveness); err != nil {
+				logger.S.Errorf("error setting vad mode %d: %v", aggressiveness, err)
+				continue
+			}
+			vadMode = aggressiveness
+		}
+		minSpeechMs := settings.MinSpeechMs
+		if minSpeechMs == 0 {
+			minSpeechMs = defaultMinSpeechMs
+		}
+		// In smart mode shorter speech waits for the transcript to tell a backchannel from an interruption
+		if mode == models.BargeInModeSmart {
+			minSpeechMs = max(minSpeechMs, int(classifier.MaxBackchannelDuration.Milliseconds()))
+		}
+		energyThreshold := settings.EnergyThreshold
+		if energyThreshold == 0 {
+			energyThreshold = defaultEnergyThreshold
+		}
+
 		chunk, err := base64.StdEncoding.DecodeString(mulawAudio)
 		if err != nil {
 			logger.S.Error("Error decoding base64 payload:", err)
@@ -135,7 +204,6 @@ func (c *CallOrchestrator) handleWebRTC() {
 		for _, frame := range frames {
 			// Ensure the frame is the correct size for the VAD
 			if len(frame) != frameSize {
-				logger.S.Error("Invalid frame size", "expected", frameSize, "got", len(frame))
 				continue
 			}
 
@@ -144,31 +212,34 @@ func (c *CallOrchestrator) handleWebRTC() {
 			binary.Write(buf, binary.LittleEndian, frame)
 			byteFrame := buf.Bytes()
 
-			// Check if the frame is valid for the VAD
-			if ok := vad.ValidRateAndFrameLength(sampleRate, len(byteFrame)/2); !ok {
-				logger.S.Error("Invalid rate or frame length", "rate", sampleRate, "frame length", len(byteFrame)/2)
-				continue
-			}
-
 			// Process the frame with the VAD
 			active, err := vad.Process(sampleRate, byteFrame)
 			if err != nil {
-				log.Fatal(err)
+				logger.S.Errorf("error processing vad frame: %v", err)
+				continue
 			}
 
-			// Track speaking frames
-			if active {
-				speakingFrames++
-				//logger.S.Info("user talking")
+			if active && rms(frame) >= float64(energyThreshold) {
+				speechMs += frameDuration
+				silenceMs = 0
 			} else {
-				speakingFrames = 0
+				silenceMs += frameDuration
+				// Brief dips between syllables don't end the speech
+				if silenceMs >= vadSpeechGapMs {
+					speechMs = 0
+				}
+			}
+
+			// The caller stopped without saying anything that was transcribed, let the agent talk again
+			if c.userSpeaking && silenceMs >= vadReleaseMs {
+				c.userSpeaking = false
 			}
 
-			// Check if user has been speaking for 500 ms
-			if speakingFrames >= 460/frameDuration {
-				//c.interruptionChan <- true
-				logger.S.Info("detect user talking")
-				speakingFrames = 0 // reset counter
+			// Only speech over the agent is a barge-in
+			if len(c.marks) > 0 && !c.userSpeaking && speechMs >= minSpeechMs {
+				logger.S.Infof("caller barged in after %d ms of speech", speechMs)
+				c.bargeIn()
+				speechMs = 0
 			}
 		}
 	}
@@ -0,0 +1,66 @@
+package telemetry
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Vendor call outcomes
+const (
+	OutcomeSuccess   = "success"
+	OutcomeError     = "error"
+	OutcomeCancelled = "cancelled"
+)
+
+// Latencies of a voice turn go from tens of milliseconds to several seconds
+var latencyBuckets = []float64{10, 25, 50, 100, 200, 300, 500, 750, 1000, 1500, 2000, 3000, 5000, 10000}
+
+var (
+	// ActiveCalls is the number of calls this instance is handling, the default registry also reports goroutines
+	ActiveCalls = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "flyflow",
+		Name:      "active_calls",
+		Help:      "Calls currently being handled by this instance.",
+	})
+
+	CallDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "flyflow",
+		Name:      "call_duration_seconds",
+		Help:      "Length of finished calls.",
+		Buckets:   []float64{5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600},
+	})
+
+	VendorRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "flyflow",
+		Name:      "vendor_requests_total",
+		Help:      "Requests to speech, LLM and telephony vendors by outcome.",
+	}, []string{"vendor", "operation", "outcome"})
+
+	VendorLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "flyflow",
+		Name:      "vendor_request_duration_ms",
+		Help:      "Time taken by requests to vendors.",
+		Buckets:   latencyBuckets,
+	}, []string{"vendor", "operation"})
+
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "flyflow",
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook delivery attempts by event and outcome.",
+	}, []string{"event", "outcome"})
+
+	TurnLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "flyflow",
+		Name:      "turn_latency_ms",
+		Help:      "Time taken by each stage of the agent's turns.",
+		Buckets:   latencyBuckets,
+	}, []string{"stage"})
+)
+
+// Handler serves the metrics in the Prometheus format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,96 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/flyflow-devs/flyflow"
+
+// InitTracing exports spans to the configured OTLP collector. Without one spans are no-ops. The returned
+// function flushes the remaining spans.
+func InitTracing(ctx context.Context, cfg *config.Config) (func(context.Context) error, error) {
+	if cfg.TracingEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.TracingEndpoint)}
+	if cfg.TracingInsecure {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.DeploymentEnvironment(cfg.Env),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TracingSampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// StartSpan starts a span, as a child of the span in ctx if there is one
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan ends the span, marking it failed when err isn't nil
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// StartVendorCall starts a child span for a request to a vendor. The returned function ends the span and
+// records the request's outcome and latency.
+func StartVendorCall(ctx context.Context, vendor string, operation string) (context.Context, func(error)) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, vendor+"."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("vendor", vendor)),
+	)
+	start := time.Now()
+
+	return ctx, func(err error) {
+		outcome := OutcomeSuccess
+		switch {
+		// Requests we gave up on, e.g. speculative completions the caller talked over, aren't the vendor's fault
+		case errors.Is(err, context.Canceled):
+			outcome = OutcomeCancelled
+			err = nil
+		case err != nil:
+			outcome = OutcomeError
+		}
+		VendorRequests.WithLabelValues(vendor, operation, outcome).Inc()
+		VendorLatency.WithLabelValues(vendor, operation).Observe(float64(time.Since(start).Milliseconds()))
+		span.SetAttributes(attribute.String("outcome", outcome))
+		EndSpan(span, err)
+	}
+}
@@ -0,0 +1,50 @@
+package voicemail
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Signed greeting URLs stay valid long enough for Twilio to fetch the greeting during the call
+const greetingURLLifetime = 1 * time.Hour
+
+// GreetingQuery returns the signed query of the URL Twilio fetches an agent's spoken greeting from, so
+// greetings can't be synthesized for other agents by guessing their ids
+func GreetingQuery(secret string, agentId uint, ruleName string, now time.Time) url.Values {
+	query := url.Values{
+		"agent_id": {strconv.FormatUint(uint64(agentId), 10)},
+		"expires":  {strconv.FormatInt(now.Add(greetingURLLifetime).Unix(), 10)},
+	}
+	if ruleName != "" {
+		query.Set("rule", ruleName)
+	}
+	query.Set("signature", greetingSignature(secret, query))
+	return query
+}
+
+// VerifyGreetingQuery reports whether the query was signed with the secret and hasn't expired
+func VerifyGreetingQuery(secret string, query url.Values, now time.Time) bool {
+	if secret == "" {
+		return false
+	}
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || now.Unix() > expires {
+		return false
+	}
+	signature, err := hex.DecodeString(query.Get("signature"))
+	if err != nil {
+		return false
+	}
+	expected, _ := hex.DecodeString(greetingSignature(secret, query))
+	return hmac.Equal(signature, expected)
+}
+
+func greetingSignature(secret string, query url.Values) string {
+	mac := hmac.New(sha256.New, []byte("voicemail-greeting:"+secret))
+	mac.Write([]byte(query.Get("agent_id") + "\n" + query.Get("rule") + "\n" + query.Get("expires")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
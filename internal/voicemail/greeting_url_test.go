@@ -0,0 +1,39 @@
+package voicemail
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestGreetingQuerySignature(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	signed := GreetingQuery("secret", 42, "after hours", now)
+
+	tests := []struct {
+		name   string
+		secret string
+		modify func(query url.Values)
+		at     time.Time
+		want   bool
+	}{
+		{"signed query", "secret", func(url.Values) {}, now, true},
+		{"before it expires", "secret", func(url.Values) {}, now.Add(59 * time.Minute), true},
+		{"expired", "secret", func(url.Values) {}, now.Add(61 * time.Minute), false},
+		{"another agent", "secret", func(query url.Values) { query.Set("agent_id", "43") }, now, false},
+		{"another rule", "secret", func(query url.Values) { query.Set("rule", "holiday") }, now, false},
+		{"extended expiry", "secret", func(query url.Values) { query.Set("expires", "9999999999") }, now, false},
+		{"missing signature", "secret", func(query url.Values) { query.Del("signature") }, now, false},
+		{"another secret", "other", func(url.Values) {}, now, false},
+		{"no secret", "", func(url.Values) {}, now, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(signed.Encode())
+			tt.modify(query)
+			if got := VerifyGreetingQuery(tt.secret, query, tt.at); got != tt.want {
+				t.Errorf("VerifyGreetingQuery() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
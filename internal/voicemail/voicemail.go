@@ -0,0 +1,216 @@
+package voicemail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/flyflow-devs/flyflow/internal/config"
+	"github.com/flyflow-devs/flyflow/internal/jobs"
+	"github.com/flyflow-devs/flyflow/internal/logger"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/recordings"
+	"github.com/flyflow-devs/flyflow/internal/webhook"
+	"github.com/sashabaranov/go-openai"
+	"gorm.io/gorm"
+)
+
+const TranscribeJobType = "transcribe_voicemail"
+
+const DefaultGreeting = "No one is available to take your call. Please leave a message after the beep."
+
+const (
+	// DefaultMaxLength is the longest message recorded when the agent doesn't set a limit, in seconds
+	DefaultMaxLength = 120
+
+	// MaxLengthLimit is the longest message that can be recorded, in seconds
+	MaxLengthLimit = 600
+)
+
+// Twilio's recording can take a few seconds to become available after the caller hangs up
+const transcribeDelay = 10 * time.Second
+
+const deepgramURL = "https://api.deepgram.com/v1/listen"
+
+type transcribePayload struct {
+	CallId uint `json:"call_id"`
+}
+
+// Message is a voicemail Twilio finished recording
+type Message struct {
+	CallSid      string
+	From         string
+	RecordingSid string
+	Duration     int
+}
+
+// Greeting returns what's said before recording, a routing rule's greeting takes precedence over the agent's
+func Greeting(agent *models.Agent, rule *models.RoutingRule) string {
+	if rule != nil && rule.Target.Greeting != "" {
+		return rule.Target.Greeting
+	}
+	if agent.Voicemail != nil && agent.Voicemail.Greeting != "" {
+		return agent.Voicemail.Greeting
+	}
+	return DefaultGreeting
+}
+
+// MaxLength returns the longest message recorded for the agent, in seconds
+func MaxLength(agent *models.Agent) int {
+	if agent.Voicemail != nil && agent.Voicemail.MaxLengthSeconds > 0 {
+		return agent.Voicemail.MaxLengthSeconds
+	}
+	return DefaultMaxLength
+}
+
+// Save stores the message as a voicemail call and queues its transcription
+func Save(db *gorm.DB, agent *models.Agent, message Message) (*models.Call, error) {
+	now := time.Now()
+	call := &models.Call{
+		AgentId:      agent.ID,
+		Type:         models.CallTypeVoicemail,
+		Sid:          message.CallSid,
+		ClientNumber: message.From,
+		RecordingSid: message.RecordingSid,
+		Transcript:   []openai.ChatCompletionMessage{},
+		TimeSeconds:  float64(message.Duration),
+		StartedAt:    now.Add(-time.Duration(message.Duration) * time.Second),
+		EndedAt:      now,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// The stream can create a call before failing over to voicemail, that call becomes the voicemail
+		var existing models.Call
+		if err := tx.Where("sid = ?", message.CallSid).First(&existing).Error; err == nil {
+			call.ID = existing.ID
+			call.CreatedAt = existing.CreatedAt
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err := tx.Save(call).Error; err != nil {
+			return err
+		}
+		_, err := jobs.Enqueue(tx, TranscribeJobType, transcribePayload{CallId: call.ID},
+			jobs.RunAt(now.Add(transcribeDelay)),
+			jobs.ForUser(agent.UserId),
+			jobs.ForCall(call.ID),
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+// RecordingURL is where the voicemail's recording is served by the API, empty when the API's URL isn't configured
+func RecordingURL(cfg *config.Config, call *models.Call) string {
+	if cfg.APIBaseURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/v1/call/recording/%d.mp3", cfg.APIBaseURL, call.ID)
+}
+
+// NewTranscribeJobHandler returns the job handler that transcribes voicemails and emits the voicemail_received
+// event. The event is still emitted without a transcript once transcription runs out of retries.
+func NewTranscribeJobHandler(cfg *config.Config, db *gorm.DB) jobs.Handler {
+	return func(ctx context.Context, job *models.Job) error {
+		var payload transcribePayload
+		if err := jobs.DecodePayload(job, &payload); err != nil {
+			return jobs.Permanent(err)
+		}
+
+		var call models.Call
+		if err := db.First(&call, payload.CallId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.S.Warnf("voicemail %d was deleted before it was transcribed", payload.CallId)
+				return nil
+			}
+			return err
+		}
+
+		var agent models.Agent
+		if err := db.First(&agent, call.AgentId).Error; err != nil {
+			return err
+		}
+
+		transcript, err := transcribe(ctx, cfg, &agent, call.RecordingSid)
+		if err != nil {
+			if !jobs.IsFinalAttempt(job) {
+				return err
+			}
+			logger.S.Errorf("giving up transcribing voicemail %v: %v", call.ID, err)
+		} else if transcript != "" {
+			call.Transcript = []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: transcript}}
+			if err := db.Model(&call).Select("transcript").Updates(&call).Error; err != nil {
+				return err
+			}
+		}
+
+		webhook.EmitEvent(db, &agent, "voicemail_received", &call, map[string]interface{}{
+			"from":          call.ClientNumber,
+			"transcript":    transcript,
+			"recording_url": RecordingURL(cfg, &call),
+			"duration":      call.TimeSeconds,
+		})
+
+		if err := recordings.EnqueueArchive(cfg, db, &agent, &call); err != nil {
+			logger.S.Errorf("error queueing archive of voicemail %v: %v", call.ID, err)
+		}
+		return nil
+	}
+}
+
+// transcribe runs the recording through Deepgram's prerecorded transcription
+func transcribe(ctx context.Context, cfg *config.Config, agent *models.Agent, recordingSid string) (string, error) {
+	recording, err := recordings.Download(ctx, cfg, recordingSid)
+	if err != nil {
+		return "", err
+	}
+	defer recording.Body.Close()
+
+	query := url.Values{}
+	query.Set("model", "nova-2")
+	query.Set("smart_format", "true")
+	if agent.Language != "" {
+		query.Set("language", agent.Language)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, deepgramURL+"?"+query.Encode(), recording.Body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Token "+cfg.DeepgramAPIKey)
+	req.Header.Set("Content-Type", "audio/mpeg")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("deepgram returned status code %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Results struct {
+			Channels []struct {
+				Alternatives []struct {
+					Transcript string `json:"transcript"`
+				} `json:"alternatives"`
+			} `json:"channels"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if len(result.Results.Channels) == 0 || len(result.Results.Channels[0].Alternatives) == 0 {
+		return "", nil
+	}
+	return result.Results.Channels[0].Alternatives[0].Transcript, nil
+}
@@ -2,14 +2,29 @@ package webhook
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
-	"github.com/cenkalti/backoff/v4"
+	"github.com/flyflow-devs/flyflow/internal/jobs"
 	"github.com/flyflow-devs/flyflow/internal/logger"
 	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/telemetry"
+	"gorm.io/gorm"
+)
+
+const JobType = "webhook"
+
+const (
+	// Webhooks are retried with backoff for a few hours before they're given up on
+	maxAttempts = 8
+
+	deliveryTimeout = 10 * time.Second
 )
 
 type Event struct {
@@ -18,61 +33,164 @@ type Event struct {
 	Data      interface{}  `json:"data,omitempty"`
 }
 
-func EmitEvent(webhookurl string, name string, call *models.Call, data interface{}) {
-	go func() {
-		// Validate the URL
-		if _, err := url.ParseRequestURI(webhookurl); err != nil {
-			return
-		}
+type jobPayload struct {
+	URL       string      `json:"url"`
+	EventName string      `json:"event_name"`
+	CallId    uint        `json:"call_id,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
 
-		// Create the event payload
-		event := Event{
+	// Event is the whole event, as jobs queued before the call was loaded at delivery carry it
+	Event *Event `json:"event,omitempty"`
+}
+
+// Events are written to the job queue in the background so a call never waits on the database to emit one
+const queueSize = 1024
+
+type queuedEvent struct {
+	db      *gorm.DB
+	payload jobPayload
+	opts    []jobs.Option
+}
+
+var (
+	queue      = make(chan queuedEvent, queueSize)
+	startQueue sync.Once
+	queued     sync.WaitGroup
+)
+
+// EmitEvent queues the event for delivery to the agent's webhook. The event is delivered with the call as it
+// is at delivery.
+func EmitEvent(db *gorm.DB, agent *models.Agent, name string, call *models.Call, data interface{}) {
+	if agent.Webhook == "" {
+		return
+	}
+
+	// Validate the URL
+	if _, err := url.ParseRequestURI(agent.Webhook); err != nil {
+		return
+	}
+
+	event := queuedEvent{
+		db: db,
+		payload: jobPayload{
+			URL:       agent.Webhook,
 			EventName: name,
-			Call:      call,
 			Data:      data,
+		},
+		opts: []jobs.Option{jobs.ForUser(agent.UserId), jobs.MaxAttempts(maxAttempts)},
+	}
+	if call != nil {
+		event.payload.CallId = call.ID
+		event.opts = append(event.opts, jobs.ForCall(call.ID))
+	}
+
+	startQueue.Do(func() {
+		go writeQueue()
+	})
+	queued.Add(1)
+	select {
+	case queue <- event:
+	default:
+		// Waiting on the database beats dropping the event
+		logger.S.Warnf("webhook event queue is full, writing event %s synchronously", name)
+		write(event)
+	}
+}
+
+// Flush waits for the events emitted so far to be written to the job queue
+func Flush() {
+	queued.Wait()
+}
+
+func writeQueue() {
+	for event := range queue {
+		write(event)
+	}
+}
+
+func write(event queuedEvent) {
+	defer queued.Done()
+	if _, err := jobs.Enqueue(event.db, JobType, event.payload, event.opts...); err != nil {
+		logger.S.Errorf("Failed to enqueue webhook event %s: %s", event.payload.EventName, err)
+	}
+}
+
+// deliveryOutcome is delivered, failed for attempts that are retried or rejected for ones that aren't
+func deliveryOutcome(err error) string {
+	switch {
+	case err == nil:
+		return "delivered"
+	case jobs.IsPermanent(err):
+		return "rejected"
+	default:
+		return "failed"
+	}
+}
+
+// NewJobHandler returns the job handler that delivers queued webhook events
+func NewJobHandler(db *gorm.DB) jobs.Handler {
+	client := &http.Client{Timeout: deliveryTimeout}
+
+	return func(ctx context.Context, job *models.Job) (err error) {
+		var payload jobPayload
+		if err := jobs.DecodePayload(job, &payload); err != nil {
+			return jobs.Permanent(err)
 		}
 
-		// Marshal the event payload to JSON
-		payload, err := json.Marshal(event)
+		event := payload.Event
+		if event == nil {
+			event = &Event{EventName: payload.EventName, Data: payload.Data}
+			if event.Call, err = loadCall(ctx, db, payload.CallId); err != nil {
+				return err
+			}
+		}
+		defer func() {
+			telemetry.WebhookDeliveries.WithLabelValues(event.EventName, deliveryOutcome(err)).Inc()
+		}()
+
+		body, err := json.Marshal(event)
 		if err != nil {
-			logger.S.Errorf("Failed to marshal event payload: %s", err)
-			return
+			return jobs.Permanent(fmt.Errorf("failed to marshal event payload: %w", err))
 		}
 
-		// Create a new HTTP request
-		req, err := http.NewRequest(http.MethodPost, webhookurl, bytes.NewBuffer(payload))
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, payload.URL, bytes.NewBuffer(body))
 		if err != nil {
-			logger.S.Errorf("Failed to create HTTP request: %s", err)
-			return
+			return jobs.Permanent(fmt.Errorf("failed to create HTTP request: %w", err))
 		}
 		req.Header.Set("Content-Type", "application/json")
 
-		// Create a backoff configuration
-		backoffConfig := backoff.NewExponentialBackOff()
-		backoffConfig.MaxElapsedTime = 1 * time.Minute
-
-		// Create a backoff client
-		client := &http.Client{}
-
-		// Send the event with backoff retries
-		err = backoff.Retry(func() error {
-			resp, err := client.Do(req)
-			if err != nil {
-				logger.S.Errorf("Failed to send webhook event: %s", err)
-				return err
-			}
-			defer resp.Body.Close()
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send webhook event: %w", err)
+		}
+		defer resp.Body.Close()
 
-			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-				return nil
-			}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
 
-			logger.S.Errorf("Webhook event failed with status code: %d", resp.StatusCode)
-			return backoff.Permanent(err)
-		}, backoffConfig)
+		err = fmt.Errorf("webhook event failed with status code: %d", resp.StatusCode)
 
-		if err != nil {
-			logger.S.Errorf("Webhook event failed after retries: %s", err)
+		// Client errors won't go away on their own, except for timeouts and rate limiting
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+			return jobs.Permanent(err)
 		}
-	}()
-}
\ No newline at end of file
+		return err
+	}
+}
+
+// loadCall returns the call the event is about, nil when there's none or it has since been deleted
+func loadCall(ctx context.Context, db *gorm.DB, callId uint) (*models.Call, error) {
+	if callId == 0 {
+		return nil, nil
+	}
+	var call models.Call
+	err := db.WithContext(ctx).First(&call, callId).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load call %d: %w", callId, err)
+	}
+	return &call, nil
+}
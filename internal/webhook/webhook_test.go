@@ -0,0 +1,61 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flyflow-devs/flyflow/internal/apitest"
+	"github.com/flyflow-devs/flyflow/internal/models"
+	"github.com/flyflow-devs/flyflow/internal/webhook"
+)
+
+func TestEmittedEventsAreDeliveredWithTheCall(t *testing.T) {
+	h := apitest.New(t)
+	user, _ := h.CreateUser(t, "owner@example.com")
+
+	delivered := make(chan webhook.Event, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event webhook.Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Error(err)
+		}
+		delivered <- event
+	}))
+	defer receiver.Close()
+
+	agent := models.Agent{UserId: user.ID, Name: "Support", PhoneNumber: "+15550000000", Webhook: receiver.URL}
+	if err := h.DB.Create(&agent).Error; err != nil {
+		t.Fatal(err)
+	}
+	call := models.Call{AgentId: agent.ID, Status: "in-progress"}
+	if err := h.DB.Create(&call).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	webhook.EmitEvent(h.DB, &agent, "handoff", &call, map[string]string{"reason": "billing"})
+	webhook.Flush()
+
+	// The queued job only refers to the call
+	var job models.Job
+	if err := h.DB.Where("type = ? AND call_id = ?", webhook.JobType, call.ID).First(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := job.Payload["event"]; ok {
+		t.Errorf("job payload %v carries the call", job.Payload)
+	}
+
+	// It's delivered with the call as it is by then
+	if err := h.DB.Model(&call).Update("status", "completed").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := webhook.NewJobHandler(h.DB)(context.Background(), &job); err != nil {
+		t.Fatal(err)
+	}
+	event := <-delivered
+	if event.EventName != "handoff" || event.Call == nil || event.Call.ID != call.ID || event.Call.Status != "completed" {
+		t.Errorf("got event %+v", event)
+	}
+}